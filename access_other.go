@@ -0,0 +1,30 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// canWrite reports whether fpb may write to path, a file or a directory.
+// Without access(2), a file is opened for writing, and a directory is probed
+// by creating and removing a temporary file in it.
+func canWrite(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		f, err := os.CreateTemp(path, ".fpb-*")
+		if err != nil {
+			return false
+		}
+		f.Close()
+		os.Remove(f.Name())
+		return true
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
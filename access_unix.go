@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// canWrite reports whether fpb may write to path, a file or a directory,
+// without opening or creating anything.
+func canWrite(path string) bool {
+	return unix.Access(path, unix.W_OK) == nil
+}
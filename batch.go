@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runBatch runs one FFmpeg command per line read from r, sequentially, each with
+// its own progress bar, continuing past failures. Blank lines and lines starting
+// with "#" are skipped, and a leading "ffmpeg" or "fpb" word is ignored so that
+// existing command lists can be fed in as-is. The common arguments (fpb flags or
+// FFmpeg options given on fpb's own command line) are prepended to every command.
+//
+// Prints a final tally and returns 0 only if every command succeeded.
+func runBatch(r io.Reader, common []string) int {
+	var commands [][]string
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args, err := splitCommandLine(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: line %d: %v\n", lineNo, err)
+			return 1
+		}
+		if len(args) > 0 && (args[0] == "ffmpeg" || args[0] == "fpb") {
+			args = args[1:]
+		}
+		if len(args) > 0 {
+			commands = append(commands, args)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading commands: %v\n", err)
+		return 1
+	}
+
+	var colors *Colors
+	if supportsColor(os.Stderr) {
+		colors = NewColors()
+	}
+
+	succeeded, failed := 0, 0
+	for i, args := range commands {
+		fmt.Fprintf(os.Stderr, "[%d/%d] ffmpeg %s\n", i+1, len(commands), strings.Join(args, " "))
+
+		code := Run(append(append([]string{}, common...), args...))
+		if code == exitInterrupted {
+			return code
+		}
+		if code == 0 {
+			succeeded++
+		} else {
+			failed++
+			if colors != nil {
+				fmt.Fprintf(os.Stderr, "%s%s"+T(msgBatchFailed)+"%s\n", colors.BrightRed, colors.Bold, i+1, len(commands), code, colors.Reset)
+			} else {
+				fmt.Fprintf(os.Stderr, T(msgBatchFailed)+"\n", i+1, len(commands), code)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, T(msgBatchComplete)+"\n", succeeded, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// splitCommandLine splits a command line into arguments the way a POSIX shell
+// would for simple cases: whitespace separates arguments, single quotes keep
+// everything literal, and double quotes allow backslash escapes of " and \.
+// Outside quotes a backslash escapes the next character.
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			current.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+					i++
+				}
+				current.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inArg = true
+		case c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+			inArg = true
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunBatchTally(t *testing.T) {
+	argsLog := fakeFFmpeg(t)
+	dir := t.TempDir()
+	commands := "# Encodes\n" +
+		"\n" +
+		"ffmpeg -i in.mp4 " + filepath.Join(dir, "a.mp4") + "\n" +
+		"fpb -i fail.mp4 " + filepath.Join(dir, "b.mp4") + "\n" +
+		"-i 'in put.mp4' " + filepath.Join(dir, "c.mp4") + "\n"
+
+	var status int
+	stderr := captureStderr(t, func() {
+		status = runBatch(strings.NewReader(commands), []string{"-y"})
+	})
+	if status != 1 {
+		t.Errorf("status %d, want 1 after a failure", status)
+	}
+	for _, want := range []string{
+		"[1/3] ffmpeg -i in.mp4 ",
+		"[2/3] failed with exit code 1",
+		"[3/3] ffmpeg -i in put.mp4 ",
+		"in.mp4: 100.0%",
+		"Batch complete: 2 succeeded, 1 failed",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("output:\n%s\nwant %q", stderr, want)
+		}
+	}
+
+	// The common arguments go to every command
+	runs, err := os.ReadFile(argsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(runs)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("FFmpeg ran %d times, want 3:\n%s", len(lines), runs)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "-y -i ") {
+			t.Errorf("FFmpeg ran with %q, want the common -y", line)
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"-i in.mp4 out.mp4", []string{"-i", "in.mp4", "out.mp4"}},
+		{"  -i\t'my file.mp4'  out.mp4 ", []string{"-i", "my file.mp4", "out.mp4"}},
+		{`-vf "drawtext=text=\"hi\"" out\ file.mp4`, []string{"-vf", `drawtext=text="hi"`, "out file.mp4"}},
+		{`-metadata title='' out.mp4`, []string{"-metadata", "title=", "out.mp4"}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.line)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, %v; want %q", tt.line, got, err, tt.want)
+		}
+	}
+	for _, line := range []string{"-i 'in.mp4", `-i "in.mp4`} {
+		if _, err := splitCommandLine(line); err == nil {
+			t.Errorf("splitCommandLine(%q) accepted", line)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is how often the checkpoint file is rewritten.
+const checkpointInterval = 5 * time.Second
+
+// checkpoint is the progress state recorded in the --fpb-checkpoint file,
+// so a monitor started later can show where a long encode stands.
+type checkpoint struct {
+	Desc           string    `json:"desc"`
+	Percent        float64   `json:"percent"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	OutTimeUs      int64     `json:"out_time_us"`
+	Finished       bool      `json:"finished"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// checkpointWriter periodically writes the latest checkpoint to a file.
+// Each write replaces the file atomically, so readers never see a partial
+// checkpoint. Write errors are ignored; the checkpoint is a monitoring aid.
+type checkpointWriter struct {
+	path string // Checkpoint file
+
+	mu     sync.Mutex // Guards latest and dirty
+	latest checkpoint // Most recent state
+	dirty  bool       // Whether latest hasn't been written yet
+
+	loop *periodic // Writes new state every checkpointInterval
+}
+
+// newCheckpointWriter starts writing checkpoints to path.
+func newCheckpointWriter(path string) *checkpointWriter {
+	cw := &checkpointWriter{path: path}
+	cw.loop = startPeriodic(checkpointInterval, cw.writeLatest)
+	return cw
+}
+
+// Update records the latest state, to be written with the next checkpoint.
+func (cw *checkpointWriter) Update(c checkpoint) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.latest = c
+	cw.dirty = true
+}
+
+// Close stops writing checkpoints after writing the last state, if it is new.
+func (cw *checkpointWriter) Close() {
+	cw.loop.Stop()
+}
+
+// writeLatest writes the latest state if it hasn't been written yet.
+func (cw *checkpointWriter) writeLatest() {
+	cw.mu.Lock()
+	c, dirty := cw.latest, cw.dirty
+	cw.dirty = false
+	cw.mu.Unlock()
+
+	if dirty {
+		cw.write(c)
+	}
+}
+
+// write replaces the checkpoint file with c.
+func (cw *checkpointWriter) write(c checkpoint) error {
+	c.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cw.path, append(data, '\n'))
+}
+
+// writeFileAtomic replaces the file at path with data, through a temporary file
+// in the same directory that is renamed over it, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fpb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
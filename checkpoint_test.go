@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "progress.json")
+	cw := newCheckpointWriter(path)
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetCheckpointWriter(cw)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	cw.Close() // Writes the final state without waiting for the interval
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatalf("checkpoint %q: %v", data, err)
+	}
+	if c.Desc != "in.mp4" || c.Percent != 100 || c.OutTimeUs != 4000000 || !c.Finished || c.UpdatedAt.IsZero() {
+		t.Errorf("checkpoint %+v, want in.mp4 finished at 4s", c)
+	}
+
+	// The temporary files are renamed over the checkpoint or removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the checkpoint", len(entries))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("checkpoint mode %v, want 0644", info.Mode())
+	}
+}
+
+func TestCheckpointUnwritable(t *testing.T) {
+	cw := &checkpointWriter{path: filepath.Join(t.TempDir(), "missing", "progress.json")}
+	if err := cw.write(checkpoint{Desc: "in.mp4"}); err == nil {
+		t.Error("write into a missing directory succeeded")
+	}
+}
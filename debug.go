@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// debugLog receives fpb's internal decisions with --fpb-debug or --fpb-debug-file
+// (nil = disabled). It is separate from FFmpeg's own log.
+var debugLog io.Writer
+
+// debugf writes one timestamped line to the debug log, if enabled.
+func debugf(format string, args ...any) {
+	if debugLog == nil {
+		return
+	}
+	fmt.Fprintf(debugLog, "%s fpb: "+format+"\n", append([]any{time.Now().Format("15:04:05.000")}, args...)...)
+}
+
+// startDebugLog enables the debug log requested by opts, appending to the debug
+// file if one was given so that batch runs keep every command's log.
+// The returned function disables the log again and closes the file.
+func startDebugLog(opts *Options) (stop func(), err error) {
+	switch {
+	case opts.DebugFile != "":
+		f, err := os.OpenFile(opts.DebugFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		debugLog = f
+		return func() {
+			debugLog = nil
+			f.Close()
+		}, nil
+	case opts.Debug:
+		debugLog = os.Stderr
+		return func() { debugLog = nil }, nil
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugLog(t *testing.T) {
+	fakeFFmpeg(t)
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "fpb.log")
+	out := filepath.Join(dir, "out.mp4")
+	for i := 0; i < 2; i++ {
+		var status int
+		captureStderr(t, func() {
+			status = Run([]string{"--fpb-debug-file=" + logFile, "-i", "in.mp4", out})
+		})
+		if status != 0 {
+			t.Fatalf("run %d: status %d", i, status)
+		}
+	}
+	if debugLog != nil {
+		t.Error("the debug log is still enabled after Run")
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, want := range []string{
+		"fpb: stdin is not a terminal, never overwriting\n",
+		"fpb: injected -n\n",
+		`fpb: running ["ffmpeg" "-progress" "pipe:2" "-n" "-i" "in.mp4" "` + out + `"]`,
+		`fpb: duration 4000000us from "  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s"`,
+		"fpb: frame rate 25/1 from ",
+		`fpb: bar "in.mp4": total 100 frames, count unit auto, terminal width `,
+	} {
+		if strings.Count(log, want) != 2 {
+			t.Errorf("debug log:\n%s\nwant %q once per run", log, want)
+		}
+	}
+}
+
+func TestDebugStderr(t *testing.T) {
+	opts := NewOptions()
+	opts.Debug = true
+	stderr := captureStderr(t, func() {
+		stop, err := startDebugLog(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		debugf("frame rate %d/%d", 30000, 1001)
+		stop()
+		debugf("not logged")
+	})
+	if !strings.HasSuffix(stderr, " fpb: frame rate 30000/1001\n") || strings.Count(stderr, "\n") != 1 {
+		t.Errorf("stderr %q, want one debug line", stderr)
+	}
+
+	opts.DebugFile = filepath.Join(t.TempDir(), "missing", "fpb.log")
+	if _, err := startDebugLog(opts); err == nil {
+		t.Error("startDebugLog succeeded for a file in a missing directory")
+	}
+}
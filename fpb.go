@@ -21,16 +21,22 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 	"golang.org/x/term"
@@ -41,26 +47,68 @@ import (
 type Colors struct {
 	Reset         string // Resets all formatting
 	Bold          string // Bold text
+	Reverse       string // Inverse video (used for text drawn over the filled bar)
 	Red           string // Standard red color
 	Green         string // Standard green color (used for progress bar)
 	Yellow        string // Standard yellow color (used for percentage)
 	Blue          string // Standard blue color (used for ETA)
 	BrightRed     string // Bright red color (used for errors)
+	BrightGreen   string // Bright green color (used for the completed bar)
 	BrightYellow  string // Bright yellow color (used for prompts)
+	Gray          string // Dim gray color (used for the unfilled part of the bar)
 }
 
-// NewColors creates a new Colors instance with ANSI color codes.
+// TrackColors are the colors the unfilled part of the bar can be drawn in
+// (see --fpb-track-color). "none" leaves it in the terminal's default color.
+var TrackColors = []string{"gray", "white", "cyan", "blue", "none"}
+
+// track returns the color code of the unfilled part of the bar for one of
+// TrackColors, or "" for none. Unknown names, such as "", give gray.
+func (c *Colors) track(name string) string {
+	switch name {
+	case "none":
+		return ""
+	case "white":
+		return "\033[37m"
+	case "cyan":
+		return "\033[36m"
+	case "blue":
+		return c.Blue
+	}
+	return c.Gray
+}
+
+// NewColors creates a new Colors instance with ANSI color codes for the active theme.
 // Returns a struct containing all the color codes needed for formatting.
 func NewColors() *Colors {
+	if activeTheme == ThemeLight {
+		// Bright and yellow tones wash out on light backgrounds
+		return &Colors{
+			Reset:        "\033[0m",
+			Bold:         "\033[1m",
+			Reverse:      "\033[7m",
+			Red:          "\033[31m",
+			Green:        "\033[32m",
+			Yellow:       "\033[35m",
+			Blue:         "\033[34m",
+			BrightRed:    "\033[31m",
+			BrightGreen:  "\033[32m",
+			BrightYellow: "\033[35m",
+			Gray:         "\033[90m",
+		}
+	}
 	return &Colors{
 		Reset:        "\033[0m",
 		Bold:         "\033[1m",
+		Reverse:      "\033[7m",
 		Red:          "\033[31m",
 		Green:        "\033[32m",
 		Yellow:       "\033[33m",
 		Blue:         "\033[34m",
 		BrightRed:    "\033[91m",
+		BrightGreen:  "\033[92m",
 		BrightYellow: "\033[93m",
+		Gray:         "\033[90m",
 	}
 }
 
@@ -78,11 +126,61 @@ type ProgressBar struct {
 	file        io.Writer     // Output destination (typically stderr)
 	lastUpdate  time.Time     // Last time the progress bar was updated
 	updateDelay time.Duration // Minimum delay between updates (50ms)
+	fraction    float64       // Precise completed fraction (0-1), used instead of current/total when set
+	hasFraction bool          // Whether fraction holds a precise value
+	mode        string        // Display mode (ModeBar, ModeLine, ModeJSON or ModeNone)
+	fpsWidth    int           // Widest fps field rendered so far
+	etaWidth    int           // Widest ETA field rendered so far
+	inlinePercent bool        // Whether to draw the percentage centered inside the bar
+	percentPrecision int      // Decimals shown in the percentage
+	decimalSep    string      // Decimal separator of displayed numbers
+	finishNewline bool        // Whether Finish ends the bar line with a newline
+	targetFPS     int         // Source frame rate the fps segment is compared against (0 = unknown)
+	countUnit     string      // How the current/total segment is shown (one of the Unit* constants)
+	unitsPerSecond float64    // Progress units per second of media (fps in frame mode, 1 in seconds mode)
+	tmuxTitle     bool        // Whether each update also sets the tmux pane title to the progress
+	titleSet      bool        // Whether the tmux pane title currently shows the progress
+	hideCursor    bool        // Whether the cursor is hidden while the bar is drawn
+	cursorHidden  bool        // Whether the cursor is currently hidden
+	finishedAt    time.Time   // When Finish was called (zero while in progress); freezes the final stats
+	lastWidth     int         // Display width of the final bar, for clearing it on Redraw
+	clearStyle    string      // How the previous bar is erased (ClearCR or ClearANSI)
+	renderedLines int         // Lines of the block last written in ClearANSI style, to be cleared next
+	header        string      // Line drawn above the bar in ModeBar ("" = none)
+	headerShown   bool        // Whether the bar last written has the header line above it
+	quiet         bool        // Whether updates are suppressed and Finish prints only a done line
+	startDelay    time.Duration // How long after startTime the bar is first drawn in ModeBar
+	speedColors   bool        // Whether the bar fill color reflects the processing speed
+	finalColors   bool        // Whether the final bar turns the success or failure color
+	finalColor    string      // Color of the whole fill once finished ("" = the normal fill colors)
+	maxBarWidth   int         // Widest the bar itself may be drawn (0 = fill the terminal)
+	etaGauge      bool        // Whether a gauge of the remaining work follows the ETA
+	dualFPS       bool        // Whether the fps segment shows the live rate before the average
+	liveFPS       float64     // Current processing rate reported by FFmpeg ("fps=")
+	hasLiveFPS    bool        // Whether liveFPS is known
+	openBracket   string      // Drawn left of the bar ("" = none)
+	closeBracket  string      // Drawn right of the bar ("" = none)
+	trackColor    string      // Color code of the unfilled part of the colored bar ("" = default color)
+	plain         string      // FFmpeg-style stats line drawn instead of the bar in ModeBar ("" = draw the bar)
+	gpu           func() (int, bool) // Source of the GPU utilization segment (nil = not shown)
+	segments      int         // Media segments written by a segmenting muxer (0 = not shown)
+	speed         float64     // Processing speed relative to real time
+	hasSpeed      bool        // Whether speed is known
+	sizeFraction  float64     // Output size relative to the expected size, drawn as a second fill
+	width         int         // Width to fit the line to (0 = the terminal's width)
+	fixedStats    bool        // Whether fixedRate and fixedRemaining replace the measured rate and ETA
+	fixedRate     float64     // Rate shown for a snapshot (see RenderLine)
+	fixedRemaining time.Duration // ETA shown for a snapshot (see RenderLine)
+	hasSize       bool        // Whether sizeFraction is known
+	
+	maxBytesPerSec int       // Terminal output budget in bytes per second (0 = unlimited)
+	byteBudget     float64   // Bytes that may currently be written without exceeding the budget
+	lastBudget     time.Time // Last time the byte budget was refilled
 }
 
 // NewProgressBar creates a new progress bar instance.
 // Parameters:
-//   - desc: Description or filename being processed
+//   - desc: Description or filename being processed (control characters are escaped)
 //   - total: Total number of units to process
 //   - unit: Unit type (e.g., "frames", "seconds")
 //   - useColors: Whether to enable color output
@@ -91,16 +189,22 @@ func NewProgressBar(desc string, total int, unit string, useColors bool, file io
 	pb := &ProgressBar{
 		total:       total,
 		current:     0,
-		desc:        desc,
+		desc:        sanitizeText(desc),
 		unit:        unit,
 		startTime:   time.Now(),
 		useColors:   useColors,
 		file:        file,
 		updateDelay: 50 * time.Millisecond,
+		mode:        ModeBar,
+		finishNewline: true,
+		countUnit:     UnitAuto,
+		percentPrecision: 1,
+		decimalSep:    ".",
 	}
 	
 	if useColors {
 		pb.colors = NewColors()
+		pb.trackColor = pb.colors.Gray
 	}
 	
 	return pb
@@ -110,513 +214,2971 @@ func NewProgressBar(desc string, total int, unit string, useColors bool, file io
 // Updates are throttled to avoid excessive terminal output (max 20 FPS).
 func (pb *ProgressBar) Update(current int) {
 	pb.current = current
-	
+	pb.hasFraction = false
+	pb.refresh()
+}
+
+// UpdateFraction sets the current progress value along with a precise completed
+// fraction (0-1) and re-renders the progress bar. The fraction drives the percentage,
+// bar fill, and ETA, avoiding the stepping caused by coarse integer progress values.
+func (pb *ProgressBar) UpdateFraction(current int, fraction float64) {
+	pb.current = current
+	pb.fraction = fraction
+	pb.hasFraction = true
+	pb.refresh()
+}
+
+// refresh re-renders the progress bar, subject to the update throttle and byte budget.
+func (pb *ProgressBar) refresh() {
 	now := time.Now()
-	if now.Sub(pb.lastUpdate) < pb.updateDelay {
+	if pb.quiet || pb.mode == ModeNone || now.Sub(pb.lastUpdate) < pb.updateDelay || pb.delayed(now) {
 		return
 	}
-	pb.lastUpdate = now
 	
-	pb.render()
+	line := pb.render()
+	if !pb.allowWrite(len(line), now) {
+		return
+	}
+	pb.lastUpdate = now
+	pb.write(line)
 }
 
-// Finish completes the progress bar by setting it to 100% and adding a newline.
-// This should be called when processing is complete.
-func (pb *ProgressBar) Finish() {
-	pb.current = pb.total
-	pb.render()
-	fmt.Fprint(pb.file, "\n")
+// SetMode selects how progress is displayed (ModeBar, ModeLine, ModeJSON or ModeNone).
+// The line-oriented modes print one complete line per update, so their updates
+// are throttled to once per second to keep logs readable.
+func (pb *ProgressBar) SetMode(mode string) {
+	pb.mode = mode
+	if mode != ModeBar {
+		pb.updateDelay = time.Second
+	}
 }
 
-// render displays the progress bar with current statistics.
-// Calculates percentage, ETA, and FPS, then formats and outputs the complete progress line.
-// Automatically adapts to terminal width and handles color formatting.
-func (pb *ProgressBar) render() {
-	termWidth, _ := getTerminalSize()
-	
-	percentage := float64(pb.current) / float64(pb.total) * 100
-	if pb.total == 0 {
-		percentage = 0
-	}
-	
-	elapsed := time.Since(pb.startTime)
-	var remaining time.Duration
-	if pb.current > 0 && pb.total > 0 {
-		remaining = time.Duration(float64(elapsed) * (float64(pb.total) - float64(pb.current)) / float64(pb.current))
+// SetTargetFPS sets the source frame rate; the fps segment turns green while
+// processing at or above it (real time or faster) and red below it.
+func (pb *ProgressBar) SetTargetFPS(fps int) {
+	pb.targetFPS = fps
+}
+
+// SetCountUnit selects how the current/total segment is displayed (UnitAuto,
+// UnitTime, UnitTimecode or UnitFrames), independently of how progress is calculated.
+// unitsPerSecond converts progress values to media time for UnitTime and UnitTimecode.
+func (pb *ProgressBar) SetCountUnit(unit string, unitsPerSecond float64) {
+	pb.countUnit = unit
+	pb.unitsPerSecond = unitsPerSecond
+}
+
+// SetTmuxTitle makes every update also set the tmux pane title (#T, shown in
+// tmux's default status bar) to the current progress. Finish, Fail, Clear and
+// ResetTitle clear it again.
+func (pb *ProgressBar) SetTmuxTitle(enabled bool) {
+	pb.tmuxTitle = enabled
+}
+
+// ResetTitle clears the tmux pane title if the progress bar set it.
+// It is safe to call any number of times.
+func (pb *ProgressBar) ResetTitle() {
+	if pb.titleSet {
+		fmt.Fprint(pb.file, tmuxTitleReset)
+		pb.titleSet = false
 	}
-	
-	rate := float64(pb.current) / elapsed.Seconds()
-	
-	var rightInfo string
-	if pb.useColors && pb.colors != nil {
-		rightInfo = fmt.Sprintf(" %s%.1f%%%s • %d/%d • %s%.0ffps%s • ETA %s%s%s",
-			pb.colors.Yellow, percentage, pb.colors.Reset,
-			pb.current, pb.total,
-			pb.colors.Red, rate, pb.colors.Reset,
-			pb.colors.Blue, pb.formatDurationSimple(remaining), pb.colors.Reset)
-	} else {
-		rightInfo = fmt.Sprintf(" %.1f%% • %d/%d • %.0ffps • ETA %s",
-			percentage, pb.current, pb.total, rate, pb.formatDurationSimple(remaining))
+}
+
+// SetHideCursor hides the terminal cursor from the first render until Finish,
+// Clear or ShowCursor, so it doesn't blink over the animated bar.
+// Only enable it when writing to a terminal.
+func (pb *ProgressBar) SetHideCursor(enabled bool) {
+	pb.hideCursor = enabled
+}
+
+// ShowCursor shows the cursor again if the progress bar hid it.
+// It is safe to call any number of times.
+func (pb *ProgressBar) ShowCursor() {
+	if pb.cursorHidden {
+		fmt.Fprint(pb.file, "\033[?25h")
+		pb.cursorHidden = false
 	}
-	
-	leftSide := pb.handleFilename(pb.desc)
-	rightInfoPlainLength := len(pb.stripANSI(rightInfo))
-	spaceForBar := termWidth - len(leftSide) - 1 - rightInfoPlainLength
-	
-	if spaceForBar < 5 || termWidth < 20 {
-		termWidth = 80
-		spaceForBar = 80 - len(leftSide) - 1 - rightInfoPlainLength
-		if spaceForBar < 5 {
-			spaceForBar = 5
-		}
+}
+
+// SetInlinePercent draws the percentage centered inside the bar instead of to its right.
+// Bars narrower than minInlinePercentWidth keep the percentage on the side.
+func (pb *ProgressBar) SetInlinePercent(inline bool) {
+	pb.inlinePercent = inline
+}
+
+// SetPercentPrecision sets how many decimals (0-2) the percentage is shown with.
+func (pb *ProgressBar) SetPercentPrecision(n int) {
+	pb.percentPrecision = n
+}
+
+// SetDecimalSeparator sets the decimal separator of displayed numbers, "." or ",".
+func (pb *ProgressBar) SetDecimalSeparator(sep string) {
+	pb.decimalSep = sep
+}
+
+// formatPercent formats a percentage with the configured precision, padded to
+// the width of "100%" at that precision so the bar edge doesn't jitter.
+func (pb *ProgressBar) formatPercent(percentage float64) string {
+	width := 3
+	if pb.percentPrecision > 0 {
+		width += 1 + pb.percentPrecision
 	}
-	
-	filled := int(float64(spaceForBar) * percentage / 100)
-	
-	var bar string
-	if pb.useColors && pb.colors != nil {
-		bar = pb.buildRichBar(filled, spaceForBar)
-	} else {
-		bar = pb.buildSimpleBar(filled, spaceForBar)
+	return pb.localize(fmt.Sprintf("%*.*f%%", width, pb.percentPrecision, percentage))
+}
+
+// localize replaces the decimal point of a formatted number with the
+// configured decimal separator.
+func (pb *ProgressBar) localize(number string) string {
+	if pb.decimalSep == "" || pb.decimalSep == "." {
+		return number
 	}
-	
-	output := fmt.Sprintf("%s %s%s", leftSide, bar, rightInfo)
-	
-	fmt.Fprint(pb.file, "\r\033[K")
-	fmt.Fprint(pb.file, output)
+	return strings.Replace(number, ".", pb.decimalSep, 1)
 }
 
-// stripANSI removes ANSI escape codes and non-ASCII characters from a string.
-// Used to calculate the actual display width of text containing color codes.
-func (pb *ProgressBar) stripANSI(str string) string {
-	ansiRe := regexp.MustCompile(`\x1b\[[0-9;]*[mGKHfABCDEFGJSTuhlp]`)
-	stripped := ansiRe.ReplaceAllString(str, "")
-	
-	unicodeRe := regexp.MustCompile(`[^\x00-\x7F]`)
-	result := unicodeRe.ReplaceAllString(stripped, " ")
-	
-	return result
+// SetMaxBytesPerSec limits the terminal output of the progress bar to n bytes per second.
+// Renders that would exceed the budget are skipped, coalescing updates on slow links.
+// A value of 0 disables the limit.
+func (pb *ProgressBar) SetMaxBytesPerSec(n int) {
+	pb.maxBytesPerSec = n
+	pb.byteBudget = float64(n)
+	pb.lastBudget = time.Now()
 }
 
-// buildRichBar creates a colored progress bar using Unicode characters.
-// Filled portions are green, with a special character at the progress edge.
-func (pb *ProgressBar) buildRichBar(filled, total int) string {
-	if total <= 0 {
-		return ""
+// allowWrite reports whether n more bytes fit in the output byte budget.
+// The budget refills continuously at maxBytesPerSec and holds at most one
+// second's worth of output (or one line, if a single line is larger).
+func (pb *ProgressBar) allowWrite(n int, now time.Time) bool {
+	if pb.maxBytesPerSec <= 0 {
+		return true
 	}
 	
-	var bar strings.Builder
+	rate := float64(pb.maxBytesPerSec)
+	pb.byteBudget += now.Sub(pb.lastBudget).Seconds() * rate
+	pb.lastBudget = now
 	
-	for i := 0; i < total; i++ {
-		if i < filled {
-			bar.WriteString(pb.colors.Green + "━" + pb.colors.Reset)
-		} else if i == filled && filled < total {
-			bar.WriteString(pb.colors.Green + "╸" + pb.colors.Reset)
-		} else {
-			bar.WriteString("━")
-		}
+	limit := rate
+	if float64(n) > limit {
+		limit = float64(n)
+	}
+	if pb.byteBudget > limit {
+		pb.byteBudget = limit
 	}
 	
-	return bar.String()
+	if pb.byteBudget < float64(n) {
+		return false
+	}
+	pb.byteBudget -= float64(n)
+	return true
 }
 
-// buildSimpleBar creates a plain progress bar without colors.
-// Used when color support is not available or disabled.
-func (pb *ProgressBar) buildSimpleBar(filled, total int) string {
-	if total <= 0 {
-		return ""
+// Finish completes the progress bar by setting it to 100% and adding a newline.
+// This should be called when processing is complete.
+func (pb *ProgressBar) Finish() {
+	if pb.total <= 0 {
+		// Without a total, the count reached is the total
+		pb.total = pb.current
 	}
-	
-	var bar strings.Builder
-	
-	for i := 0; i < total; i++ {
-		if i < filled {
-			bar.WriteString("━")
-		} else if i == filled && filled < total {
-			bar.WriteString("╸")
-		} else {
-			bar.WriteString("━")
-		}
+	pb.current = pb.total
+	pb.hasFraction = false
+	pb.finishedAt = time.Now()
+	if pb.finalColors && pb.colors != nil {
+		pb.finalColor = pb.colors.BrightGreen
+	}
+	if pb.mode == ModeNone {
+		return
+	}
+	if pb.quiet {
+		fmt.Fprintf(pb.file, T(msgDone)+"\n", pb.desc, pb.finishedAt.Sub(pb.startTime).Seconds())
+		return
+	}
+	if pb.delayed(pb.finishedAt) {
+		// Finished before the bar was ever drawn
+		return
+	}
+	line := pb.render()
+	pb.lastWidth = pb.displayWidth(line)
+	pb.write(line)
+	pb.ShowCursor()
+	pb.ResetTitle()
+	if pb.mode == ModeBar && pb.finishNewline && !pb.clearsByLine() {
+		fmt.Fprint(pb.file, "\n")
 	}
-	
-	return bar.String()
 }
 
-// handleFilename truncates long filenames to fit in the progress display.
-// Filenames longer than 30 characters are truncated with "..." suffix.
-func (pb *ProgressBar) handleFilename(filename string) string {
-	if len(filename) > 30 {
-		filename = filename[:27] + "..."
+// Fail marks the encode as failed. With SetFinalColors the bar is left on screen
+// in the failure color: an in-progress bar is completed at its current value,
+// and a bar already completed by Finish is redrawn. Does nothing otherwise.
+func (pb *ProgressBar) Fail() {
+	if !pb.finalColors || pb.colors == nil || pb.mode != ModeBar || pb.quiet {
+		return
+	}
+	pb.finalColor = pb.colors.BrightRed
+	if !pb.finishedAt.IsZero() {
+		pb.Redraw()
+		return
+	}
+	pb.finishedAt = time.Now()
+	line := pb.render()
+	pb.lastWidth = pb.displayWidth(line)
+	pb.write(line)
+	pb.ShowCursor()
+	pb.ResetTitle()
+	if pb.finishNewline && !pb.clearsByLine() {
+		fmt.Fprint(pb.file, "\n")
 	}
-	return filename
 }
 
-// formatDurationSimple formats a duration as MM:SS for display.
-// Used for showing estimated time remaining (ETA).
-func (pb *ProgressBar) formatDurationSimple(d time.Duration) string {
-	if d < 0 {
-		return "00:00"
+// Redraw re-renders the final bar after Finish at the current terminal width,
+// so the line left on screen is reflowed after a resize. The terminal may have
+// rewrapped the old line over several rows, so all of them are cleared first.
+// Does nothing before Finish or outside ModeBar.
+func (pb *ProgressBar) Redraw() {
+	if pb.finishedAt.IsZero() || pb.mode != ModeBar {
+		return
 	}
 	
-	totalSeconds := int(d.Seconds())
-	minutes := totalSeconds / 60
-	seconds := totalSeconds % 60
+	termWidth := pb.terminalWidth()
+	rows := 1
+	if termWidth > 0 && pb.lastWidth > termWidth {
+		rows = (pb.lastWidth + termWidth - 1) / termWidth
+	}
+	up := rows - 1
+	if pb.finishNewline || pb.clearsByLine() {
+		up++
+	}
+	if pb.headerShown {
+		up++
+	}
+	if up > 0 {
+		fmt.Fprintf(pb.file, "\033[%dA", up)
+	}
+	fmt.Fprint(pb.file, "\r\033[J")
+	pb.renderedLines = 0
+	pb.headerShown = false
 	
-	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+	line := pb.render()
+	pb.lastWidth = pb.displayWidth(line)
+	pb.write(line)
+	if pb.finishNewline && !pb.clearsByLine() {
+		fmt.Fprint(pb.file, "\n")
+	}
 }
 
-// ColoredProgressNotifier parses FFmpeg output and manages the progress display.
-// It handles both progress information extraction and user interaction forwarding.
-// 
-// Key responsibilities:
-// - Parse FFmpeg stderr output for duration, progress, FPS, and source file info
-// - Display interactive prompts (like overwrite confirmations) to the user
-// - Forward user input to FFmpeg's stdin when needed
-// - Buffer stderr content for error display if FFmpeg fails
-// - Manage the progress bar display and updates
-type ColoredProgressNotifier struct {
-	// Regex patterns for parsing FFmpeg output
-	durationRx *regexp.Regexp // Matches "Duration: HH:MM:SS.ss" 
-	progressRx *regexp.Regexp // Matches "time=HH:MM:SS.ss"
-	sourceRx   *regexp.Regexp // Matches source filename
-	fpsRx      *regexp.Regexp // Matches frame rate information
-	
-	// State management
-	lines         []string         // Collected output lines
-	lineAcc       strings.Builder  // Current line being built
-	duration      int              // Total duration in seconds
-	source        string           // Source filename
-	started       bool             // Whether processing has started
-	pbar          *ProgressBar     // Progress bar instance
-	fps           int              // Frames per second
-	
-	// Output and interaction
-	file          io.Writer        // Output destination (stderr)
-	useColors     bool             // Whether colors are enabled
-	colors        *Colors          // Color codes
-	stdinWriter   io.WriteCloser   // FFmpeg's stdin for user input
-	stderrBuffer  strings.Builder  // Buffer for error output
-	waitingForInput bool           // Whether waiting for user input
+// displayWidth returns the number of terminal columns a rendered bar line occupies.
+// Only the bar line is measured when a header line is drawn above it.
+func (pb *ProgressBar) displayWidth(line string) int {
+	line = line[strings.LastIndex(line, "\n")+1:]
+	return textWidth(pb.stripANSI(strings.TrimPrefix(line, "\r")))
 }
 
-// NewColoredProgressNotifier creates a new progress notifier instance.
-// Parameters:
-//   - file: Output writer for progress display (typically os.Stderr)
-//   - useColors: Whether to enable colored output
-//   - stdinWriter: FFmpeg's stdin pipe for forwarding user input
-func NewColoredProgressNotifier(file io.Writer, useColors bool, stdinWriter io.WriteCloser) *ColoredProgressNotifier {
-	cpn := &ColoredProgressNotifier{
-		durationRx:      regexp.MustCompile(`Duration: (\d{2}):(\d{2}):(\d{2})\.\d{2}`),
-		progressRx:      regexp.MustCompile(`time=(\d{2}):(\d{2}):(\d{2})\.\d{2}`),
-		sourceRx:        regexp.MustCompile(`from '(.*)':`),
-		fpsRx:           regexp.MustCompile(`(\d{2}\.\d{2}|\d{2}) fps`),
-		lines:           make([]string, 0),
-		duration:        0,
-		source:          "",
-		started:         false,
-		pbar:            nil,
-		fps:             0,
-		file:            file,
-		useColors:       useColors && supportsColor(file),
-		stdinWriter:     stdinWriter,
-		waitingForInput: false,
-	}
-	
-	if cpn.useColors {
-		cpn.colors = NewColors()
-	}
-	
-	return cpn
+// SetFinishNewline controls whether Finish moves to a new line after the final bar.
+// Callers that manage the cursor themselves (fixed rows, multiple bars) can
+// disable it to leave the cursor at the end of the bar line. Enabled by default.
+func (pb *ProgressBar) SetFinishNewline(enabled bool) {
+	pb.finishNewline = enabled
 }
 
-// getTerminalSize returns the current terminal dimensions.
-// Falls back to 80x24 if terminal size cannot be determined.
-func getTerminalSize() (width, height int) {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		return 80, 24
+// padStable left-pads a field to the widest width it has had so far.
+// Fields without a known maximum (like fps) grow once and then stay put,
+// keeping the bar length constant from one update to the next.
+func (pb *ProgressBar) padStable(field string, width *int) string {
+	if len(field) > *width {
+		*width = len(field)
 	}
-	return width, height
+	return fmt.Sprintf("%*s", *width, field)
 }
 
-// supportsColor determines whether the output supports ANSI color codes.
-// Returns false for Windows and non-terminal outputs.
-func supportsColor(file io.Writer) bool {
-	if runtime.GOOS == "windows" {
-		return false
+// Println prints text on its own line above the bar, then draws the bar again
+// below it. Outside ModeBar the text is printed between the progress lines.
+func (pb *ProgressBar) Println(text string) {
+	if pb.mode != ModeBar || pb.quiet {
+		fmt.Fprintln(pb.file, text)
+		return
 	}
-	
-	if f, ok := file.(*os.File); ok {
-		return isTerminal(f)
+	pb.erase()
+	fmt.Fprintln(pb.file, text)
+	if pb.delayed(time.Now()) {
+		return
 	}
-	
-	return false
+	pb.write(pb.render())
 }
 
-// isTerminal checks if the given file is connected to a terminal.
-// This is used to determine color support capability.
-func isTerminal(f *os.File) bool {
-	stat, err := f.Stat()
-	if err != nil {
-		return false
+// Clear erases the progress bar line and leaves the cursor at its start,
+// without printing a final bar or newline.
+func (pb *ProgressBar) Clear() {
+	if pb.mode == ModeNone {
+		return
 	}
-	return (stat.Mode() & os.ModeCharDevice) != 0
-}
-
-// seconds converts HH:MM:SS time components to total seconds.
-// Used for parsing FFmpeg duration and progress timestamps.
-func seconds(hours, minutes, secs string) int {
-	h, _ := strconv.Atoi(hours)
-	m, _ := strconv.Atoi(minutes)
-	s, _ := strconv.Atoi(secs)
-	return (h*60+m)*60 + s
+	pb.erase()
+	pb.ShowCursor()
+	pb.ResetTitle()
 }
 
-// ProcessChar processes each character from FFmpeg's stderr output.
-// It handles both progress parsing and interactive prompt detection.
-// 
-// This method:
-// - Buffers all stderr content for potential error display
-// - Parses progress information when complete lines are received
-// - Detects interactive prompts (like "[y/N]") and displays them
-// - Initiates user input forwarding when prompts are detected
-func (cpn *ColoredProgressNotifier) ProcessChar(char byte) {
-	// Always add to stderr buffer for potential error display
-	cpn.stderrBuffer.WriteByte(char)
-	
-	if char == '\r' || char == '\n' {
-		line := cpn.newline()
-		if cpn.duration == 0 {
-			cpn.duration = cpn.getDuration(line)
-		}
-		if cpn.source == "" {
-			cpn.source = cpn.getSource(line)
-		}
-		if cpn.fps == 0 {
-			cpn.fps = cpn.getFPS(line)
-		}
-		cpn.progress(line)
+// erase erases the last bar written, including its header line, if any.
+func (pb *ProgressBar) erase() {
+	if pb.clearsByLine() {
+		fmt.Fprint(pb.file, strings.Repeat("\033[1A\033[2K", pb.renderedLines))
+		pb.renderedLines = 0
 	} else {
-		cpn.lineAcc.WriteByte(char)
-		
-		// Detect interactive prompts and forward them to user
-		if strings.HasSuffix(cpn.lineAcc.String(), "[y/N] ") {
-			prompt := cpn.lineAcc.String()
-			if cpn.useColors && cpn.colors != nil {
-				coloredPrompt := fmt.Sprintf("%s%s%s%s", cpn.colors.BrightYellow, cpn.colors.Bold, prompt, cpn.colors.Reset)
-				fmt.Fprint(cpn.file, coloredPrompt)
-			} else {
-				fmt.Fprint(cpn.file, prompt)
-			}
-			
-			// Enable input forwarding for user response
-			cpn.waitingForInput = true
-			go cpn.forwardUserInput()
-			
-			cpn.newline()
+		fmt.Fprint(pb.file, "\r\033[K")
+		if pb.headerShown {
+			fmt.Fprint(pb.file, "\033[1A\033[K")
 		}
 	}
+	pb.headerShown = false
 }
 
-// newline finalizes the current line being built and returns it.
-// Stores the line in the lines array and resets the line accumulator.
-func (cpn *ColoredProgressNotifier) newline() string {
-	line := cpn.lineAcc.String()
-	cpn.lines = append(cpn.lines, line)
-	cpn.lineAcc.Reset()
-	return line
+// SetHeader sets a line drawn above the bar in ModeBar, such as the overall
+// progress of a batch. An empty header removes it.
+func (pb *ProgressBar) SetHeader(header string) {
+	pb.header = header
 }
 
-// getDuration extracts total duration from FFmpeg output lines.
-// Parses lines like "Duration: 00:01:30.45" and returns total seconds.
-func (cpn *ColoredProgressNotifier) getDuration(line string) int {
-	matches := cpn.durationRx.FindStringSubmatch(line)
-	if len(matches) > 3 {
-		return seconds(matches[1], matches[2], matches[3])
-	}
-	return 0
+// SetStartDelay delays drawing the bar until the progress has run for d, so
+// jobs finishing sooner leave no bar at all. Only applies to ModeBar.
+func (pb *ProgressBar) SetStartDelay(d time.Duration) {
+	pb.startDelay = d
 }
 
-// getSource extracts the source filename from FFmpeg output lines.
-// Parses lines like "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'file.mp4':"
-// Returns just the base filename for display.
-func (cpn *ColoredProgressNotifier) getSource(line string) string {
-	matches := cpn.sourceRx.FindStringSubmatch(line)
-	if len(matches) > 1 {
-		return filepath.Base(matches[1])
-	}
-	return ""
+// delayed reports whether the bar must not be drawn yet at now (see SetStartDelay).
+func (pb *ProgressBar) delayed(now time.Time) bool {
+	return pb.mode == ModeBar && now.Sub(pb.startTime) < pb.startDelay
 }
 
-// getFPS extracts frame rate information from FFmpeg output lines.
-// Parses lines containing FPS information and returns frames per second as integer.
-func (cpn *ColoredProgressNotifier) getFPS(line string) int {
-	matches := cpn.fpsRx.FindStringSubmatch(line)
-	if len(matches) > 1 {
-		fps, err := strconv.ParseFloat(matches[1], 64)
+// SetTotal changes the total number of units, e.g. when the duration becomes
+// known after the progress started. A bar without a total becomes determinate.
+func (pb *ProgressBar) SetTotal(total int) {
+	pb.total = total
+}
+
+// SetDesc changes the description shown left of the bar, e.g. when a concat
+// moves on to its next file. The total and percentage are unaffected.
+func (pb *ProgressBar) SetDesc(desc string) {
+	pb.desc = sanitizeText(desc)
+}
+
+// SetMaxBarWidth caps the width of the bar itself, so it doesn't stretch across
+// ultra-wide terminals; the statistics then follow the bar, leaving the rest of
+// the line empty. A value of 0 lets the bar fill the terminal.
+func (pb *ProgressBar) SetMaxBarWidth(n int) {
+	pb.maxBarWidth = n
+}
+
+// SetETAGauge adds a small gauge of the remaining work after the ETA (see etaGauge).
+// It is left out when the bar would get narrower than minGaugeBarWidth.
+func (pb *ProgressBar) SetETAGauge(enabled bool) {
+	pb.etaGauge = enabled
+}
+
+// SetDualFPS shows the live rate set by SetLiveFPS before the average rate
+// of the whole run, e.g. "118/104fps", instead of the live rate alone.
+func (pb *ProgressBar) SetDualFPS(enabled bool) {
+	pb.dualFPS = enabled
+}
+
+// SetLiveFPS records the current processing rate reported by FFmpeg (e.g. 118
+// for "fps=118"), which the fps segment shows in frame mode instead of the
+// rate measured by the bar. ok is false while the rate is unknown.
+func (pb *ProgressBar) SetLiveFPS(fps float64, ok bool) {
+	pb.liveFPS = fps
+	pb.hasLiveFPS = ok
+}
+
+// shownRate returns the processing rate to show given the average rate
+// measured by the bar: FFmpeg's own live rate when it is known, otherwise the
+// frames processed per second. In seconds mode the average would be seconds
+// per second, so without the live rate there is no rate to show and it returns 0.
+func (pb *ProgressBar) shownRate(average float64) float64 {
+	switch {
+	case pb.hasLiveFPS:
+		return pb.liveFPS
+	case pb.unit == "frames":
+		return average
+	}
+	return 0
+}
+
+// fpsText formats the fps segment given the average rate: the shown rate,
+// e.g. "118fps", or with SetDualFPS the live and average rates, e.g. "118/104fps".
+// It is empty in seconds mode without the live rate (audio), which leaves the
+// fps segment out.
+func (pb *ProgressBar) fpsText(average float64) string {
+	switch {
+	case pb.hasLiveFPS && pb.dualFPS && pb.unit == "frames":
+		return fmt.Sprintf("%.0f/%.0ffps", pb.liveFPS, average)
+	case pb.hasLiveFPS || pb.unit == "frames":
+		return fmt.Sprintf("%.0ffps", pb.shownRate(average))
+	}
+	return ""
+}
+
+// SetPlain sets the FFmpeg-style stats line drawn in place of the bar in
+// ModeBar, e.g. "frame=  240 fps= 48 ... speed=1.92x" (see --fpb-plain).
+// An empty line draws the bar.
+func (pb *ProgressBar) SetPlain(line string) {
+	pb.plain = line
+}
+
+// SetTrackColor selects the color of the unfilled part of the colored bar,
+// one of TrackColors.
+func (pb *ProgressBar) SetTrackColor(name string) {
+	if pb.colors != nil {
+		pb.trackColor = pb.colors.track(name)
+	}
+}
+
+// track draws cells of the unfilled part of the colored bar in the track color.
+func (pb *ProgressBar) track(cells int) string {
+	if pb.trackColor == "" || cells <= 0 {
+		return strings.Repeat("━", cells)
+	}
+	return pb.trackColor + strings.Repeat("━", cells) + pb.colors.Reset
+}
+
+// SetBrackets draws the bar between the two characters of brackets, e.g. "[]".
+// An empty string draws no brackets.
+func (pb *ProgressBar) SetBrackets(brackets string) {
+	pb.openBracket, pb.closeBracket = "", ""
+	if r := []rune(brackets); len(r) == 2 {
+		pb.openBracket, pb.closeBracket = string(r[0]), string(r[1])
+	}
+}
+
+// SetGPU adds a "GPU NN%" segment after the fps, with the utilization reported
+// by util whenever it is known.
+func (pb *ProgressBar) SetGPU(util func() (int, bool)) {
+	pb.gpu = util
+}
+
+// SetSegments sets the number of media segments written so far, shown after
+// the fps when segmented output (HLS, DASH, -f segment) is being written.
+func (pb *ProgressBar) SetSegments(n int) {
+	pb.segments = n
+}
+
+// SetSizeProgress sets the output size relative to its expected final size, drawn
+// as a second, blue fill in the colored bar (see --fpb-split-bar).
+func (pb *ProgressBar) SetSizeProgress(fraction float64, ok bool) {
+	pb.sizeFraction = fraction
+	pb.hasSize = ok
+}
+
+// SetSpeedColors colors the filled part of the bar by processing speed
+// (see fillColor) instead of always green.
+func (pb *ProgressBar) SetSpeedColors(enabled bool) {
+	pb.speedColors = enabled
+}
+
+// SetFinalColors turns the whole fill bright green when the bar is completed
+// by Finish, and red when it is left on screen by Fail.
+func (pb *ProgressBar) SetFinalColors(enabled bool) {
+	pb.finalColors = enabled
+}
+
+// SetSpeed records the processing speed relative to real time (e.g. 1.92 for
+// "speed=1.92x") for SetSpeedColors. ok is false while the speed is unknown.
+func (pb *ProgressBar) SetSpeed(speed float64, ok bool) {
+	pb.speed = speed
+	pb.hasSpeed = ok
+}
+
+// SetQuiet suppresses the animated bar for jobs too short for it to be useful;
+// Finish then prints only a brief done line, e.g. "movie.mp4: done in 0.4s".
+func (pb *ProgressBar) SetQuiet(quiet bool) {
+	pb.quiet = quiet
+}
+
+// SetClearStyle selects how the previous bar is erased before drawing the next one.
+// ClearCR (the default) returns to the start of the line with "\r\033[K".
+// ClearANSI ends every bar with a newline and erases it by moving the cursor
+// up and clearing each line ("\033[1A\033[2K"), which some terminals and log
+// viewers handle better, and which also erases status blocks of several lines.
+func (pb *ProgressBar) SetClearStyle(style string) {
+	pb.clearStyle = style
+}
+
+// clearsByLine reports whether bars are erased line by line (ClearANSI in ModeBar).
+func (pb *ProgressBar) clearsByLine() bool {
+	return pb.mode == ModeBar && pb.clearStyle == ClearANSI
+}
+
+// stats calculates the percentage complete, processing rate, and estimated time remaining.
+func (pb *ProgressBar) stats() (percentage, rate float64, remaining time.Duration) {
+	percentage = float64(pb.current) / float64(pb.total) * 100
+	if pb.total == 0 {
+		percentage = 0
+	}
+	if pb.hasFraction {
+		percentage = pb.fraction * 100
+	}
+	
+	elapsed := time.Since(pb.startTime)
+	if !pb.finishedAt.IsZero() {
+		elapsed = pb.finishedAt.Sub(pb.startTime)
+	}
+	if percentage > 0 {
+		remaining = time.Duration(float64(elapsed) * (100 - percentage) / percentage)
+	}
+	
+	if elapsed > 0 {
+		rate = float64(pb.current) / elapsed.Seconds()
+	}
+	if pb.fixedStats {
+		rate, remaining = pb.fixedRate, pb.fixedRemaining
+	}
+	return percentage, rate, remaining
+}
+
+// render builds the progress output for the current display mode.
+func (pb *ProgressBar) render() string {
+	switch pb.mode {
+	case ModeLine:
+		return pb.renderLine()
+	case ModeJSON:
+		return pb.renderJSON()
+	default:
+		return pb.renderBar()
+	}
+}
+
+// renderLine builds a plain, newline-terminated status line for non-terminal output.
+// Example: "movie.mp4: 42.0% 300/720 frames 25fps ETA 00:12"
+func (pb *ProgressBar) renderLine() string {
+	percentage, rate, remaining := pb.stats()
+	count := fmt.Sprintf("%d/%d %s", pb.current, pb.total, pb.unit)
+	if pb.countUnit == UnitTime || pb.countUnit == UnitTimecode || pb.total <= 0 {
+		count = pb.countText()
+	}
+	fps := pb.fpsText(rate)
+	if pb.segments > 0 {
+		fps = joinFields(" ", fps, fmt.Sprintf(T(msgSegments), pb.segments))
+	}
+	if util, ok := pb.gpuUtilization(); ok {
+		fps = joinFields(" ", fps, fmt.Sprintf("GPU %d%%", util))
+	}
+	if pb.indeterminate() {
+		return pb.desc + ": " + joinFields(" ", count, fps) + "\n"
+	}
+	return pb.desc + ": " + joinFields(" ", strings.TrimSpace(pb.formatPercent(percentage)), count, fps,
+		T(msgETA)+" "+pb.formatDurationSimple(remaining)) + "\n"
+}
+
+// joinFields joins the non-empty fields with sep.
+func joinFields(sep string, fields ...string) string {
+	kept := fields[:0:0]
+	for _, f := range fields {
+		if f != "" {
+			kept = append(kept, f)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// countText formats the current/total segment, e.g. " 300/720" or "00:12 / 00:30".
+// With UnitTime the progress values are converted to elapsed media time using
+// unitsPerSecond (the frame rate in frame mode, 1 in seconds mode); otherwise the
+// raw values are shown, with the current value padded to the width of the total.
+// UnitTimecode shows frame counts as timecodes, e.g. "00:00:58:12 / 00:02:08:00",
+// falling back to UnitTime when the frame rate is unknown.
+func (pb *ProgressBar) countText() string {
+	if pb.total <= 0 {
+		// Without a total only the count so far is known
+		if pb.unit == "frames" {
+			return fmt.Sprintf("%d %s", pb.current, pb.unit)
+		}
+		return pb.formatDurationSimple(time.Duration(pb.current) * time.Second)
+	}
+	if pb.countUnit == UnitTimecode && pb.unit == "frames" && pb.unitsPerSecond > 0 {
+		base := int(math.Round(pb.unitsPerSecond))
+		return fmt.Sprintf("%s / %s", pb.formatTimecode(pb.current, base), pb.formatTimecode(pb.total, base))
+	}
+	if (pb.countUnit == UnitTime || pb.countUnit == UnitTimecode) && pb.unitsPerSecond > 0 {
+		current := time.Duration(float64(pb.current) / pb.unitsPerSecond * float64(time.Second))
+		total := time.Duration(float64(pb.total) / pb.unitsPerSecond * float64(time.Second))
+		return fmt.Sprintf("%s / %s", pb.formatDurationSimple(current), pb.formatDurationSimple(total))
+	}
+	
+	countWidth := len(strconv.Itoa(pb.total))
+	return fmt.Sprintf("%*d/%d", countWidth, pb.current, pb.total)
+}
+
+// statusLine builds the single human-readable line of the --fpb-status-file file,
+// e.g. "42% | 118fps | ETA 03:12".
+func (pb *ProgressBar) statusLine() string {
+	percentage, rate, remaining := pb.stats()
+	if pb.indeterminate() {
+		return joinFields(" | ", pb.countText(), pb.fpsText(rate))
+	}
+	return joinFields(" | ", fmt.Sprintf("%.0f%%", percentage), pb.fpsText(rate), T(msgETA)+" "+pb.formatDurationSimple(remaining))
+}
+
+// progressJSON is the record written for each update in ModeJSON.
+type progressJSON struct {
+	Desc       string  `json:"desc"`
+	Percent    float64 `json:"percent"`
+	Current    int     `json:"current"`
+	Total      int     `json:"total"`
+	Unit       string  `json:"unit"`
+	FPS        float64 `json:"fps"`
+	ETASeconds int     `json:"eta_seconds"`
+	Segments   int     `json:"segments,omitempty"`
+}
+
+// renderJSON builds a single-line JSON progress record for consumption by other tools.
+func (pb *ProgressBar) renderJSON() string {
+	percentage, rate, remaining := pb.stats()
+	data, _ := json.Marshal(progressJSON{
+		Desc:       pb.desc,
+		Percent:    float64(int(percentage*10)) / 10,
+		Current:    pb.current,
+		Total:      pb.total,
+		Unit:       pb.unit,
+		FPS:        float64(int(pb.shownRate(rate)*10)) / 10,
+		ETASeconds: int(remaining.Seconds()),
+		Segments:   pb.segments,
+	})
+	return string(data) + "\n"
+}
+
+// renderBar builds the progress bar line with current statistics.
+// Calculates percentage, ETA, and FPS, then formats the complete progress line,
+// including the escape sequence that clears the previous one.
+// Automatically adapts to terminal width and handles color formatting.
+func (pb *ProgressBar) renderBar() string {
+	termWidth := pb.terminalWidth()
+	if pb.plain != "" {
+		output := truncateWidth(pb.plain, termWidth)
+		if pb.header != "" {
+			output = pb.header + "\n\033[K" + output
+		}
+		return "\r\033[K" + output
+	}
+	
+	percentage, rate, remaining := pb.stats()
+	
+	// Pad fields to a stable width so the bar edge doesn't jitter as values grow
+	pct := pb.formatPercent(percentage)
+	count := pb.countText()
+	fps := pb.fpsText(rate)
+	if fps != "" {
+		fps = pb.padStable(fps, &pb.fpsWidth)
+	}
+	eta := pb.padStable(pb.formatDurationSimple(remaining), &pb.etaWidth)
+	
+	leftSide := pb.handleFilename(pb.desc)
+	
+	// The remaining time gauge follows the ETA while the bar stays wide enough
+	if pb.etaGauge && !pb.indeterminate() {
+		withGauge := eta + " " + etaGauge(percentage)
+		rightInfo := pb.rightInfo(pct, count, fps, withGauge, rate, pb.inlinePercent)
+		if pb.freeSpace(termWidth, leftSide, rightInfo) >= minGaugeBarWidth {
+			eta = withGauge
+		}
+	}
+	
+	// The percentage moves inside the bar when requested and the bar is wide enough
+	inline := pb.inlinePercent
+	rightInfo := pb.rightInfo(pct, count, fps, eta, rate, inline)
+	spaceForBar := pb.barSpace(termWidth, leftSide, rightInfo)
+	if inline && spaceForBar < minInlinePercentWidth {
+		inline = false
+		rightInfo = pb.rightInfo(pct, count, fps, eta, rate, inline)
+		spaceForBar = pb.barSpace(termWidth, leftSide, rightInfo)
+	}
+	
+	filled := int(float64(spaceForBar) * percentage / 100)
+	
+	label := ""
+	if inline {
+		label = strings.TrimSpace(pct)
+	}
+	
+	var bar string
+	switch {
+	case pb.indeterminate():
+		bar = pb.buildPulseBar(spaceForBar)
+	case pb.useColors && pb.colors != nil:
+		bar = pb.buildRichBar(filled, spaceForBar, label)
+	default:
+		bar = pb.buildSimpleBar(filled, spaceForBar, label)
+	}
+	
+	output := fmt.Sprintf("%s %s%s%s%s", leftSide, pb.openBracket, bar, pb.closeBracket, rightInfo)
+	if pb.header != "" {
+		output = pb.header + "\n\033[K" + output
+	}
+	
+	return "\r\033[K" + output
+}
+
+// indeterminate reports whether the amount of work is unknown (no duration or
+// frame count), so only the count so far and the rate can be shown.
+func (pb *ProgressBar) indeterminate() bool {
+	return pb.total <= 0 && !pb.hasFraction
+}
+
+// terminalWidth returns the width the bar line is fitted to.
+func (pb *ProgressBar) terminalWidth() int {
+	if pb.width > 0 {
+		return pb.width
+	}
+	width, _ := getTerminalSize(pb.file)
+	return width
+}
+
+// minInlinePercentWidth is the narrowest bar that gets the percentage drawn inside it.
+const minInlinePercentWidth = 20
+
+// etaGaugeWidth is the number of cells of the remaining time gauge.
+const etaGaugeWidth = 5
+
+// minGaugeBarWidth is the narrowest bar that the remaining time gauge is shown with.
+const minGaugeBarWidth = 10
+
+// etaGauge draws the remaining fraction of the work as a small gauge,
+// e.g. "[▓▓▓░░]" with 60% left.
+func etaGauge(percentage float64) string {
+	left := int(math.Round((100 - percentage) / 100 * etaGaugeWidth))
+	left = min(max(left, 0), etaGaugeWidth)
+	return "[" + strings.Repeat("▓", left) + strings.Repeat("░", etaGaugeWidth-left) + "]"
+}
+
+// rightInfo builds the statistics shown to the right of the bar.
+// The percentage is left out when it is drawn inside the bar instead,
+// and the fps segment when fps is empty (seconds mode).
+func (pb *ProgressBar) rightInfo(pct, count, fps, eta string, rate float64, inline bool) string {
+	if pb.segments > 0 {
+		fps = joinFields(" • ", fps, fmt.Sprintf(T(msgSegments), pb.segments))
+	}
+	if util, ok := pb.gpuUtilization(); ok {
+		fps = joinFields(" • ", fps, fmt.Sprintf("GPU %3d%%", util))
+	}
+	colored := pb.useColors && pb.colors != nil
+	if colored && fps != "" {
+		fps = pb.fpsColor(rate) + fps + pb.colors.Reset
+	}
+	if pb.indeterminate() {
+		// Without a total there is no percentage or ETA to show
+		return " " + joinFields(" • ", count, fps)
+	}
+	if inline {
+		pct = ""
+	}
+	if colored {
+		if pct != "" {
+			pct = pb.colors.Yellow + pct + pb.colors.Reset
+		}
+		eta = pb.colors.Blue + eta + pb.colors.Reset
+	}
+	return " " + joinFields(" • ", pct, count, fps, T(msgETA)+" "+eta)
+}
+
+// gpuUtilization returns the GPU utilization to show, if SetGPU provided a source.
+func (pb *ProgressBar) gpuUtilization() (int, bool) {
+	if pb.gpu == nil {
+		return 0, false
+	}
+	return pb.gpu()
+}
+
+// fpsColor picks the color of the fps segment. When the source frame rate is
+// known, it shows whether encoding keeps up with real time: green at or above
+// the source rate, red below it. Otherwise the fps segment is always red.
+func (pb *ProgressBar) fpsColor(rate float64) string {
+	if pb.targetFPS > 0 && pb.shownRate(rate) >= float64(pb.targetFPS) {
+		return pb.colors.Green
+	}
+	return pb.colors.Red
+}
+
+// barSpace calculates how many cells are left for the bar itself, falling back
+// to an 80 column layout when the terminal is too narrow.
+func (pb *ProgressBar) barSpace(termWidth int, leftSide, rightInfo string) int {
+	spaceForBar := pb.freeSpace(termWidth, leftSide, rightInfo)
+	
+	if spaceForBar < 5 || termWidth < 20 {
+		spaceForBar = pb.freeSpace(80, leftSide, rightInfo)
+		if spaceForBar < 5 {
+			spaceForBar = 5
+		}
+	}
+	if pb.maxBarWidth > 0 && spaceForBar > pb.maxBarWidth {
+		spaceForBar = pb.maxBarWidth
+	}
+	return spaceForBar
+}
+
+// freeSpace returns how many of termWidth cells the description, statistics
+// and brackets leave for the bar, which may be too few or negative.
+func (pb *ProgressBar) freeSpace(termWidth int, leftSide, rightInfo string) int {
+	rightInfoPlainLength := textWidth(pb.stripANSI(rightInfo))
+	leftSideLength := textWidth(leftSide)
+	bracketsLength := textWidth(pb.openBracket + pb.closeBracket)
+	return termWidth - leftSideLength - 1 - bracketsLength - rightInfoPlainLength
+}
+
+// write outputs a rendered progress line, followed by the tmux pane title update when enabled.
+// The first write also hides the cursor when SetHideCursor is enabled.
+// In ClearANSI style the line is written as a block ending in a newline, preceded by
+// the sequences erasing the previous block.
+func (pb *ProgressBar) write(line string) {
+	hasHeader := pb.mode == ModeBar && strings.Contains(line, "\n")
+	if pb.clearsByLine() {
+		block := strings.TrimPrefix(line, "\r\033[K") + "\n"
+		line = strings.Repeat("\033[1A\033[2K", pb.renderedLines) + block
+		pb.renderedLines = strings.Count(block, "\n")
+	} else if pb.headerShown {
+		// Go back up to the header line to redraw it
+		line = "\033[1A" + line
+	}
+	pb.headerShown = hasHeader
+	if pb.hideCursor && !pb.cursorHidden {
+		line = "\033[?25l" + line
+		pb.cursorHidden = true
+	}
+	if pb.tmuxTitle && pb.finishedAt.IsZero() {
+		percentage, _, _ := pb.stats()
+		line += tmuxTitle(pb.desc, percentage)
+		pb.titleSet = true
+	}
+	fmt.Fprint(pb.file, line)
+}
+
+// Pattern used by stripANSI, compiled once since rendering happens many times per second.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[mGKHfABCDEFGJSTuhlp]`)
+
+// stripANSI removes ANSI escape codes from a string.
+// Used with textWidth to calculate the display width of text containing color codes.
+func (pb *ProgressBar) stripANSI(str string) string {
+	return ansiRe.ReplaceAllString(str, "")
+}
+
+// buildRichBar creates a colored progress bar using Unicode characters.
+// Filled portions are green, with a special character at the progress edge.
+// A non-empty label is drawn centered over the bar, in inverse video where it
+// covers the filled portion so it stays readable on both sides of the edge.
+func (pb *ProgressBar) buildRichBar(filled, total int, label string) string {
+	if total <= 0 {
+		return ""
+	}
+	
+	var bar strings.Builder
+	labelStart := (total - len(label)) / 2
+	inLabel := func(i int) bool { return i >= labelStart && i < labelStart+len(label) }
+	fill := pb.fillColor()
+	
+	// With a split bar the size fill is drawn in blue, and the shorter of the
+	// time and size fills is drawn over the longer one
+	fillEnd, colorAt := filled, func(int) string { return fill }
+	if sized, ok := pb.sizeFilled(total); ok && pb.finalColor == "" {
+		short, shortColor, long, longColor := filled, fill, sized, pb.colors.Blue
+		if sized < filled {
+			short, shortColor, long, longColor = sized, pb.colors.Blue, filled, fill
+		}
+		fillEnd = long
+		colorAt = func(i int) string {
+			if i < short {
+				return shortColor
+			}
+			return longColor
+		}
+	}
+	
+	// Cells are written in runs sharing one color code, keeping wide bars short
+	for i := 0; i < total; {
+		end := i + 1
+		switch {
+		case inLabel(i):
+			char := string(label[i-labelStart])
+			if i < fillEnd {
+				bar.WriteString(pb.colors.Reverse + colorAt(i) + char + pb.colors.Reset)
+			} else {
+				bar.WriteString(pb.colors.Bold + char + pb.colors.Reset)
+			}
+		case i < fillEnd:
+			for end < fillEnd && !inLabel(end) && colorAt(end) == colorAt(i) {
+				end++
+			}
+			bar.WriteString(colorAt(i) + strings.Repeat("━", end-i) + pb.colors.Reset)
+		case i == fillEnd:
+			bar.WriteString(colorAt(i) + "╸" + pb.colors.Reset)
+		default:
+			for end < total && !inLabel(end) {
+				end++
+			}
+			bar.WriteString(pb.track(end - i))
+		}
+		i = end
+	}
+	
+	return bar.String()
+}
+
+// sizeFilled returns how many of the total bar cells the size fill covers,
+// if SetSizeProgress provided the size progress.
+func (pb *ProgressBar) sizeFilled(total int) (int, bool) {
+	if !pb.hasSize {
+		return 0, false
+	}
+	return int(float64(total) * math.Min(pb.sizeFraction, 1)), true
+}
+
+// pulseInterval is how long the pulse of an indeterminate bar takes to move one cell.
+const pulseInterval = 100 * time.Millisecond
+
+// buildPulseBar creates the bar shown while the total is unknown: a short
+// segment bouncing between the ends of the bar, showing that work goes on.
+func (pb *ProgressBar) buildPulseBar(total int) string {
+	if total <= 0 {
+		return ""
+	}
+	width := min(max(total/8, 3), total)
+	span := total - width
+	pos := 0
+	if span > 0 {
+		pos = int(time.Since(pb.startTime)/pulseInterval) % (2 * span)
+		if pos > span {
+			pos = 2*span - pos
+		}
+	}
+	
+	pulse := strings.Repeat("━", width)
+	if pb.useColors && pb.colors != nil {
+		return pb.track(pos) + pb.fillColor() + pulse + pb.colors.Reset + pb.track(span-pos)
+	}
+	return strings.Repeat(" ", pos) + pulse + strings.Repeat(" ", span-pos)
+}
+
+// fillColor returns the color of the filled part of the bar: green, or with
+// SetSpeedColors the color of the processing speed: green at real time or
+// faster, yellow from half real time, and red below that. The final color
+// set by Finish or Fail takes precedence.
+func (pb *ProgressBar) fillColor() string {
+	if pb.finalColor != "" {
+		return pb.finalColor
+	}
+	if !pb.speedColors || !pb.hasSpeed {
+		return pb.colors.Green
+	}
+	switch {
+	case pb.speed >= 1:
+		return pb.colors.Green
+	case pb.speed >= 0.5:
+		return pb.colors.Yellow
+	default:
+		return pb.colors.Red
+	}
+}
+
+// buildSimpleBar creates a plain progress bar without colors.
+// Used when color support is not available or disabled.
+// A non-empty label is drawn centered over the bar.
+func (pb *ProgressBar) buildSimpleBar(filled, total int, label string) string {
+	if total <= 0 {
+		return ""
+	}
+	
+	var bar strings.Builder
+	labelStart := (total - len(label)) / 2
+	
+	for i := 0; i < total; i++ {
+		if i >= labelStart && i < labelStart+len(label) {
+			bar.WriteByte(label[i-labelStart])
+		} else if i < filled {
+			bar.WriteString("━")
+		} else if i == filled && filled < total {
+			bar.WriteString("╸")
+		} else {
+			bar.WriteString("━")
+		}
+	}
+	
+	return bar.String()
+}
+
+// handleFilename truncates long filenames to fit in the progress display.
+// Filenames wider than 30 columns are truncated with "..." suffix, on a character boundary.
+func (pb *ProgressBar) handleFilename(filename string) string {
+	if textWidth(filename) > 30 {
+		filename = truncateWidth(filename, 27) + "..."
+	}
+	return filename
+}
+
+// formatDurationSimple formats a duration as MM:SS for display.
+// Used for showing estimated time remaining (ETA).
+func (pb *ProgressBar) formatDurationSimple(d time.Duration) string {
+	if d < 0 {
+		return "00:00"
+	}
+	
+	totalSeconds := int(d.Seconds())
+	minutes := totalSeconds / 60
+	seconds := totalSeconds % 60
+	
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
+
+// formatTimecode formats a frame count as a non-drop-frame SMPTE timecode
+// (HH:MM:SS:FF) at the given whole-number frame rate, e.g. 30 for 29.97 fps.
+func (pb *ProgressBar) formatTimecode(frames, base int) string {
+	if frames < 0 {
+		frames = 0
+	}
+	
+	secs := frames / base
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", secs/3600, secs/60%60, secs%60, frames%base)
+}
+
+// ColoredProgressNotifier parses FFmpeg output and manages the progress display.
+// It handles both progress information extraction and user interaction forwarding.
+// 
+// Key responsibilities:
+// - Parse FFmpeg stderr output for duration, progress, FPS, and source file info
+// - Display interactive prompts (like overwrite confirmations) to the user
+// - Forward user input to FFmpeg's stdin when needed
+// - Buffer stderr content for error display if FFmpeg fails
+// - Manage the progress bar display and updates
+type ColoredProgressNotifier struct {
+	// Regex patterns for parsing FFmpeg output
+	durationRx *regexp.Regexp // Matches "Duration: HH:MM:SS.ss" 
+	sourceRx   *regexp.Regexp // Matches source filename
+	outputRx   *regexp.Regexp // Matches output filename
+	fpsRx      *regexp.Regexp // Matches frame rate information
+	progressKeyRx *regexp.Regexp // Matches "key=value" lines from the -progress stream
+	frameCountRx  *regexp.Regexp // Matches frame count tags like "NUMBER_OF_FRAMES: 15000"
+	segmentRx     *regexp.Regexp // Matches "Opening 'out003.ts' for writing" from segmenting muxers
+	readingRx     *regexp.Regexp // Matches "Opening 'b.mp4' for reading" from playlist and concat demuxers
+	promptRx      *regexp.Regexp // Matches an unfinished line asking for one of a few choices
+	lsizeRx       *regexp.Regexp // Matches the final output size, "Lsize=   12345KiB" ("kB" before FFmpeg 6.1)
+	
+	// State management
+	mu            sync.Mutex       // Guards all state below; ProcessChar runs on the reader goroutine
+	lineAcc       strings.Builder  // Current line being built
+	duration      int              // Total duration in seconds
+	durationUs    int64            // Total duration in microseconds
+	preciseTime   bool             // Whether out_time_us from -progress drives the progress
+	outTimeUsSeen bool             // Whether the -progress stream includes out_time_us
+	source        string           // Source filename
+	output        string           // Output filename
+	started       bool             // Whether processing has started
+	pbar          *ProgressBar     // Progress bar instance
+	fps           int              // Frames per second (whole part of frameRate)
+	frameRate     frameRate        // Exact frame rate used for frame totals
+	stats         statsLine        // Last parsed stats line
+	frameCount    int              // Frames in the input's video stream, from its tags (0 = unknown)
+	inVideoStream bool             // Whether the stream dump is currently listing an input video stream
+	segments      int              // Media segments opened so far by a segment/HLS/DASH muxer
+	reading       string           // Base filename of the input file opened last, for playlists and concats
+	dtsWarnings   int              // Non-monotonic DTS warnings seen, a sign of audio/video desync
+	bench         benchmark        // Resource usage reported with -benchmark
+	finalSize     int64            // Output size in bytes from the last stats line's Lsize= (-1 = not reported)
+	expectedSize  int64            // Expected output size in bytes for the split bar (0 = single bar)
+	sizeLimit     int64            // Output size limit from -fs in bytes (0 = none)
+	diskFull      bool             // Whether FFmpeg reported "No space left on device" and was stopped
+	inputs        []inputInfo      // Every input with its duration, for --fpb-input-durations
+	outSize       int64            // Output size so far in bytes, from the -progress total_size key
+	hasOutSize    bool             // Whether outSize is known
+	bitrate       float64          // Output bitrate in kbit/s, from the -progress bitrate key
+	hasBitrate    bool             // Whether bitrate is known
+	statsCSV      *csvWriter       // Receives a row per update for the stats CSV file (nil = disabled)
+	
+	// Header fields already found; each is scanned for only until its first match
+	durationFound bool
+	sourceFound   bool
+	outputFound   bool
+	fpsFound      bool
+	frameCountFound bool
+	
+	// Output and interaction
+	file          io.Writer        // Output destination (stderr)
+	useColors     bool             // Whether colors are enabled
+	colors        *Colors          // Color codes
+	stdinWriter   io.WriteCloser   // FFmpeg's stdin for user input
+	input         *bufio.Reader    // User input source for prompt answers, reading inputSource
+	inputSource   *stoppableReader // Stops reading the user's input when inputDone is closed
+	inputDone     chan struct{}    // Closed by StopInput to abandon a pending prompt answer
+	stopInput     sync.Once        // Closes inputDone once
+	stderrBuffer  bytes.Buffer     // Buffer for error output
+	finished      bool             // Whether the progress bar has already been finished
+	waitingForInput bool           // Whether waiting for user input
+	promptsEnabled  bool           // Whether interactive prompt detection is armed
+	finishNewline   bool           // Whether the progress bar ends with a newline when finished
+	mode          string           // Resolved display mode (ModeBar, ModeLine, ModeJSON or ModeNone)
+	opts          *Options         // fpb's own settings
+	pusher        *metricsPusher   // Receives progress metrics for the Pushgateway (nil = disabled)
+	spinnerShown  bool             // Whether the spinner has drawn on the current line
+	checkpoint    *checkpointWriter // Receives progress state for the checkpoint file (nil = disabled)
+	status        *statusWriter    // Receives progress lines for the status file (nil = disabled)
+	sdNotify      *sdNotifier      // Receives progress lines for systemd (nil = not in a notify service)
+	jobs          *jobBoard        // Shares the progress with the other jobs of a batch (nil = disabled)
+	lastUs        int64            // Last output timestamp in microseconds, after timeOffsetUs
+	rawUs         int64            // Last output timestamp as reported by FFmpeg
+	timeOffsetUs  int64            // Added to FFmpeg's timestamps for the loops that restarted them
+	loops         int              // Extra times the input is looped with -stream_loop (0 = none or unknown)
+	unitWait      time.Duration    // How long the bar waits for the frame rate before counting seconds
+	unitDeadline  time.Time        // When the wait for the frame rate ends (zero until the first progress)
+	gpu           *gpuMonitor      // GPU utilization poller for the bar (nil = disabled)
+}
+
+// NewColoredProgressNotifier creates a new progress notifier instance.
+// Parameters:
+//   - file: Output writer for progress display (typically os.Stderr)
+//   - useColors: Whether to enable colored output
+//   - stdinWriter: FFmpeg's stdin pipe for forwarding user input
+//   - opts: fpb's own settings (nil for defaults)
+func NewColoredProgressNotifier(file io.Writer, useColors bool, stdinWriter io.WriteCloser, opts *Options) *ColoredProgressNotifier {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	
+	cpn := &ColoredProgressNotifier{
+		durationRx:      regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2})\.(\d{2})`),
+		sourceRx:        regexp.MustCompile(`from '(.*)':`),
+		outputRx:        regexp.MustCompile(`Output #\d+, .*, to '(.*)':`),
+		fpsRx:           regexp.MustCompile(`(\d+/\d+|\d+(?:\.\d+)?) fps`),
+		progressKeyRx:   regexp.MustCompile(`^(frame|fps|stream_\d+_\d+_q|bitrate|total_size|out_time_us|out_time_ms|out_time|dup_frames|drop_frames|speed|progress)=\s*(\S*)$`),
+		frameCountRx:    regexp.MustCompile(`^\s*(?:NUMBER_OF_FRAMES(?:-\S+)?|nb_frames)\s*:\s*(\d+)\s*$`),
+		segmentRx:       regexp.MustCompile(`Opening '(.*)' for writing`),
+		readingRx:       regexp.MustCompile(`Opening '(.*)' for reading`),
+		// An unindented line, not a "[mp4 @ 0x...]" log message, ending outside quotes in a
+		// short list of choices without spaces, e.g. "Overwrite? [y/N] " or "Select a stream (0-2): "
+		promptRx:        regexp.MustCompile(`^[^\s\['](?:[^']|'[^']*')*[\[(][^\s\[\]()]{0,20}[/|-][^\s\[\]()]{0,20}[\])]:? $`),
+		lsizeRx:         regexp.MustCompile(`Lsize=\s*(\d+)(?:kB|KiB)`),
+		duration:        0,
+		source:          "",
+		output:          "",
+		started:         false,
+		pbar:            nil,
+		fps:             0,
+		file:            file,
+		useColors:       useColors && supportsColor(file),
+		stdinWriter:     stdinWriter,
+		inputDone:       make(chan struct{}),
+		waitingForInput: false,
+		promptsEnabled:  true,
+		finishNewline:   true,
+		finalSize:       -1,
+		unitWait:        unitWaitTimeout,
+		opts:            opts,
+	}
+	
+	if cpn.useColors {
+		cpn.colors = NewColors()
+	}
+	cpn.SetInput(os.Stdin)
+	
+	cpn.mode = resolveMode(opts.Mode, file)
+	
+	return cpn
+}
+
+// unitWaitTimeout is how long the first progress waits for the frame rate, which
+// decides between counting frames and seconds, before the bar counts seconds.
+// The unit then still switches to frames if the frame rate is found later.
+const unitWaitTimeout = time.Second
+
+// getTerminalSize returns the dimensions of the terminal file writes to.
+// When file isn't a terminal, or its size can't be queried on the platform,
+// the COLUMNS and LINES environment variables are used, and then 80x24.
+func getTerminalSize(file io.Writer) (width, height int) {
+	if f, ok := file.(*os.File); ok {
+		if width, height, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width, height
+		}
+	}
+	return envSize("COLUMNS", 80), envSize("LINES", 24)
+}
+
+// envSize returns the positive number in the environment variable name, or def.
+func envSize(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// resolveMode turns the requested display mode into a concrete one.
+// In ModeAuto the animated bar is used only when the output is a terminal;
+// otherwise (e.g. when fpb's stderr is captured by another tool) plain status
+// lines are printed so nested wrappers don't receive garbled in-place updates.
+func resolveMode(mode string, file io.Writer) string {
+	if mode != ModeAuto {
+		return mode
+	}
+	if isTerminalStream(file) {
+		return ModeBar
+	}
+	return ModeLine
+}
+
+// colorOS is the operating system that color support is decided for. Like
+// isTerminalStream, it is a variable so that tests can force the color
+// decisions without a terminal or another system at hand.
+var colorOS = runtime.GOOS
+
+// supportsColor determines whether the output supports ANSI color codes.
+// Returns false for Windows and non-terminal outputs.
+func supportsColor(file io.Writer) bool {
+	if colorOS == "windows" {
+		return false
+	}
+	return isTerminalStream(file)
+}
+
+// isTerminal checks if the given file is connected to a terminal.
+// This is used to determine color support capability. Other character
+// devices, such as /dev/null, are not terminals.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// isTerminalStream reports whether stream is an *os.File connected to a terminal.
+// Every terminal and color decision goes through it, and tests replace it to
+// make any stream, such as a buffer standing in for stderr, count as one or not.
+var isTerminalStream = func(stream any) bool {
+	f, ok := stream.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// findProgressTarget returns the destination of the last -progress option in args,
+// or "" if the user didn't ask FFmpeg for -progress output.
+func findProgressTarget(args []string) string {
+	target := ""
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-progress" {
+			target = args[i+1]
+		}
+	}
+	return target
+}
+
+// isStdoutTarget reports whether an FFmpeg output URL refers to its stdout.
+func isStdoutTarget(target string) bool {
+	return target == "-" || target == "pipe:" || target == "pipe:1"
+}
+
+// findOverwriteFlag returns the last of FFmpeg's "-y" (always overwrite) or
+// "-n" (never overwrite) flags present in args, or "" if neither was given.
+// With either flag FFmpeg never asks before overwriting an output file.
+func findOverwriteFlag(args []string) string {
+	flag := ""
+	for _, arg := range args {
+		if arg == "-y" || arg == "-n" {
+			flag = arg
+		}
+	}
+	return flag
+}
+
+// outputSizeLimit returns the output file size limit set with "-fs", in bytes.
+func outputSizeLimit(args []string) (int64, bool) {
+	var limit int64
+	found := false
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-fs" {
+			if n, ok := parseSize(args[i+1]); ok && n > 0 {
+				limit, found = n, true
+			}
+		}
+	}
+	return limit, found
+}
+
+// parseSize parses a byte count the way FFmpeg parses -fs: a number with an
+// optional SI prefix ("500k", "10M"), "i" for binary multiples ("10Mi") and
+// "B" for bytes-to-bits ("1MB" = 8000000), as av_strtod does.
+func parseSize(s string) (int64, bool) {
+	num, end := scanDecimal(s)
+	if end == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	rest := s[end:]
+	if rest != "" {
+		if exp := strings.IndexByte("kMGTP", rest[0]); exp >= 0 || rest[0] == 'K' {
+			if rest[0] == 'K' {
+				exp = 0
+			}
+			if len(rest) > 1 && rest[1] == 'i' {
+				value *= math.Pow(1024, float64(exp+1))
+				rest = rest[2:]
+			} else {
+				value *= math.Pow(1000, float64(exp+1))
+				rest = rest[1:]
+			}
+		}
+	}
+	if rest == "B" {
+		value *= 8
+		rest = ""
+	}
+	if rest != "" {
+		return 0, false
+	}
+	return int64(value), true
+}
+
+// outputFrameRate returns the frame rate forced on the output with "-r" (or "-r:v"),
+// e.g. "-r 30" or "-r 30000/1001". Only options after the last input count, since
+// "-r" before "-i" sets the input rate instead. The frame= counter counts output
+// frames, so this rate, not the input's, gives the frame totals.
+func outputFrameRate(args []string) (frameRate, bool) {
+	start := 0
+	for i, arg := range args {
+		if arg == "-i" {
+			start = i + 2
+		}
+	}
+	
+	var rate frameRate
+	found := false
+	for i := start; i+1 < len(args); i++ {
+		if args[i] == "-r" || args[i] == "-r:v" {
+			if fr, ok := parseFrameRate(args[i+1]); ok {
+				rate, found = fr, true
+			}
+		}
+	}
+	return rate, found
+}
+
+// ffmpegInfoFlags are FFmpeg options that print information and exit without
+// processing any media, mapped to whether they accept an (optional) argument,
+// as in "-h encoder=libx264" or "-sources pulse".
+var ffmpegInfoFlags = map[string]bool{
+	"-h": true, "-?": true, "-help": true, "--help": true,
+	"-version": false, "-buildconf": false, "-L": false,
+	"-formats": false, "-muxers": false, "-demuxers": false, "-devices": false,
+	"-codecs": false, "-decoders": false, "-encoders": false, "-bsfs": false,
+	"-protocols": false, "-filters": false, "-pix_fmts": false, "-layouts": false,
+	"-sample_fmts": false, "-dispositions": false, "-colors": false, "-hwaccels": false,
+	"-sources": true, "-sinks": true,
+}
+
+// isInfoCommand reports whether args only ask FFmpeg for information, such as
+// "-version" or "-h encoder=libx264", so there is no progress to display.
+// "-hide_banner" may accompany the informational options.
+func isInfoCommand(args []string) bool {
+	found := false
+	for i := 0; i < len(args); i++ {
+		hasArg, ok := ffmpegInfoFlags[args[i]]
+		switch {
+		case ok:
+			found = true
+			if hasArg && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+		case args[i] != "-hide_banner":
+			return false
+		}
+	}
+	return found
+}
+
+// protocolRx matches the protocol prefix FFmpeg looks for in a URL, such as
+// "pipe:", "tcp:" or "file:". A single letter is a Windows drive ("C:\out.mp4").
+var protocolRx = regexp.MustCompile(`^[A-Za-z0-9+.-]{2,}:`)
+
+// outputPath guesses the output file from FFmpeg's arguments: FFmpeg expects
+// the output last, so it is the last argument unless that is an option or the
+// value of -i. The "file:" protocol prefix is removed. Returns "" when no
+// regular output file can be identified (e.g. pipes, URLs and other protocols,
+// the null muxer's "-", or the tee muxer's "[f=mp4]a.mp4|[f=flv]rtmp://..." list).
+func outputPath(args []string) string {
+	if len(args) < 2 {
+		return ""
+	}
+	
+	path := args[len(args)-1]
+	if strings.HasPrefix(path, "-") || args[len(args)-2] == "-i" {
+		return ""
+	}
+	if strings.HasPrefix(path, "[") || usesTeeMuxer(args) {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "file:"); ok {
+		path = rest
+	} else if protocolRx.MatchString(path) {
+		return ""
+	}
+	if path == "" || path == os.DevNull {
+		return ""
+	}
+	return path
+}
+
+// usesTeeMuxer reports whether args select the tee muxer ("-f tee"), whose
+// output argument lists several outputs rather than naming a file.
+func usesTeeMuxer(args []string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-f" && args[i+1] == "tee" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOutputWritable verifies that FFmpeg will be able to create its output file,
+// so an unwritable target fails early with a clear message instead of a late,
+// cryptic FFmpeg error (possibly after an overwrite prompt). With neverOverwrite
+// (FFmpeg's -n) an existing output is never opened, so it may be read-only.
+func checkOutputWritable(path string, neverOverwrite bool) error {
+	if path == "" {
+		return nil
+	}
+	
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("cannot write %s: directory %s does not exist", path, dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot write %s: %s is not a directory", path, dir)
+	}
+	
+	// An existing output must itself be writable to be overwritten
+	if _, err := os.Stat(path); err == nil {
+		if !neverOverwrite && !canWrite(path) {
+			return fmt.Errorf("cannot write %s: file is not writable", path)
+		}
+		return nil
+	}
+	
+	if !canWrite(dir) {
+		return fmt.Errorf("cannot write %s: directory %s is not writable", path, dir)
+	}
+	return nil
+}
+
+// seconds converts HH:MM:SS time components to total seconds.
+// Used for parsing FFmpeg duration and progress timestamps; the hours
+// may have any number of digits (FFmpeg prints three past 99 hours).
+func seconds(hours, minutes, secs string) int {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(secs)
+	return (h*60+m)*60 + s
+}
+
+// ProcessChar processes each character from FFmpeg's stderr output.
+// It handles both progress parsing and interactive prompt detection.
+// 
+// This method:
+// - Buffers all stderr content for potential error display
+// - Parses progress information when complete lines are received
+// - Detects interactive prompts (like "[y/N]" or "(0-2):") and displays them
+// - Initiates user input forwarding when prompts are detected
+func (cpn *ColoredProgressNotifier) ProcessChar(char byte) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.processChar(char)
+}
+
+// Write processes a chunk of FFmpeg's stderr output, implementing io.Writer.
+// It is equivalent to calling ProcessChar for every byte, but takes the lock
+// once per chunk, which is considerably faster for verbose FFmpeg output.
+func (cpn *ColoredProgressNotifier) Write(p []byte) (int, error) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	for _, char := range p {
+		cpn.processChar(char)
+	}
+	return len(p), nil
+}
+
+// processChar implements ProcessChar; the caller must hold cpn.mu.
+func (cpn *ColoredProgressNotifier) processChar(char byte) {
+	// Always add to stderr buffer for potential error display
+	cpn.stderrBuffer.WriteByte(char)
+	
+	if char == '\r' || char == '\n' {
+		line := cpn.newline()
+		if cpn.progressKey(line) {
+			// Machine-readable progress is not useful in the error dump
+			cpn.stderrBuffer.Truncate(cpn.stderrBuffer.Len() - len(line) - 1)
+			return
+		}
+		// Each header field is scanned for only until it is first found
+		if !cpn.durationFound {
+			cpn.duration, cpn.durationUs, cpn.durationFound = cpn.getDuration(line)
+			if cpn.durationFound {
+				debugf("duration %dus from %q", cpn.durationUs, line)
+			}
+			if cpn.durationFound && cpn.loops > 0 {
+				// The input is played loops more times
+				cpn.durationUs *= int64(cpn.loops + 1)
+				cpn.duration = int(cpn.durationUs / 1000000)
+			}
+		}
+		if !cpn.sourceFound {
+			cpn.source, cpn.sourceFound = cpn.getSource(line)
+		}
+		if !cpn.outputFound {
+			cpn.output, cpn.outputFound = cpn.getOutput(line)
+		}
+		if !cpn.outputFound {
+			cpn.trackInput(line)
+		}
+		if !cpn.fpsFound && !isStatsLine(line) {
+			cpn.frameRate, cpn.fpsFound = cpn.getFPS(line)
+			cpn.fps = int(cpn.frameRate.Float())
+			if cpn.fpsFound {
+				debugf("frame rate %d/%d from %q", cpn.frameRate.Num, cpn.frameRate.Den, line)
+			}
+		}
+		if !cpn.frameCountFound && !cpn.outputFound {
+			cpn.frameCount, cpn.frameCountFound = cpn.getFrameCount(line)
+			if cpn.frameCountFound {
+				debugf("frame count %d from %q", cpn.frameCount, line)
+			}
+		}
+		if !cpn.diskFull && strings.Contains(line, "No space left on device") {
+			cpn.stopForDiskFull()
+		}
+		if isDTSWarning(line) {
+			cpn.dtsWarnings++
+		}
+		if strings.HasPrefix(line, "bench: ") {
+			cpn.bench.parse(line)
+		}
+		if m := cpn.lsizeRx.FindStringSubmatch(line); m != nil {
+			kib, _ := strconv.ParseInt(m[1], 10, 64)
+			cpn.finalSize = kib * 1024
+		}
+		if cpn.opts.ShowStats && isStatsLine(line) {
+			cpn.printAbove(line)
+		}
+		if cpn.outputFound && cpn.isSegment(line) {
+			cpn.segments++
+			debugf("segment %d from %q", cpn.segments, line)
+		}
+		if name, ok := cpn.getReading(line); ok && name != cpn.reading {
+			cpn.reading = name
+			debugf("reading %s", name)
+			if cpn.pbar != nil {
+				cpn.pbar.SetDesc(cpn.description())
+			}
+		}
+		if !cpn.preciseTime {
+			cpn.progress(line)
+		}
+	} else {
+		cpn.lineAcc.WriteByte(char)
+		
+		// Detect interactive prompts and forward them to user.
+		// Prompts end in a list of choices, so the line is only checked on that boundary.
+		if cpn.promptsEnabled && char == ' ' && cpn.promptBoundary() && cpn.promptRx.MatchString(cpn.lineAcc.String()) {
+			prompt := sanitizeText(cpn.lineAcc.String())
+			if cpn.spinnerShown {
+				// Replace the spinner rather than appending to it
+				prompt = "\r\033[K" + prompt
+				cpn.spinnerShown = false
+			}
+			if cpn.useColors && cpn.colors != nil {
+				coloredPrompt := fmt.Sprintf("%s%s%s%s", cpn.colors.BrightYellow, cpn.colors.Bold, prompt, cpn.colors.Reset)
+				fmt.Fprint(cpn.file, coloredPrompt)
+			} else {
+				fmt.Fprint(cpn.file, prompt)
+			}
+			
+			// Enable input forwarding for user response
+			cpn.waitingForInput = true
+			go cpn.forwardUserInput()
+			
+			cpn.newline()
+		}
+	}
+}
+
+// stopForDiskFull stops FFmpeg gracefully, as if "q" was pressed, once it reports
+// that the disk is full: the encode can't succeed, and stopping right away keeps
+// the output readable up to this point. The bar is taken down for the message
+// printed when FFmpeg has exited (see DiskFull).
+func (cpn *ColoredProgressNotifier) stopForDiskFull() {
+	cpn.diskFull = true
+	debugf("disk full, stopping ffmpeg")
+	if cpn.stdinWriter != nil {
+		cpn.stdinWriter.Write([]byte("q"))
+	}
+	if cpn.pbar != nil && !cpn.finished {
+		if cpn.mode == ModeBar {
+			cpn.pbar.Clear()
+		}
+		cpn.finished = true
+	}
+}
+
+// isDTSWarning reports whether line is one of FFmpeg's warnings about
+// non-monotonic timestamps, e.g. "Non-monotonous DTS in output stream 0:1" or
+// "Application provided invalid, non monotonically increasing dts to muxer".
+func isDTSWarning(line string) bool {
+	return strings.Contains(line, "Non-monotonous DTS") || strings.Contains(line, "non monotonically increasing dts")
+}
+
+// isStatsLine reports whether line is one of FFmpeg's periodic -stats lines,
+// e.g. "frame=  240 fps= 48 ..." or, for audio, "size=  1024kB time=...".
+func isStatsLine(line string) bool {
+	return strings.HasPrefix(line, "frame=") || strings.HasPrefix(line, "size=")
+}
+
+// printAbove prints a line of FFmpeg's output above the progress bar, for
+// --fpb-show-stats. It is left out of ModeJSON, whose output must stay JSON.
+func (cpn *ColoredProgressNotifier) printAbove(line string) {
+	if cpn.mode == ModeJSON {
+		return
+	}
+	if cpn.pbar != nil {
+		cpn.pbar.Println(line)
+		return
+	}
+	if cpn.spinnerShown {
+		fmt.Fprint(cpn.file, "\r\033[K")
+		cpn.spinnerShown = false
+	}
+	fmt.Fprintln(cpn.file, line)
+}
+
+// promptBoundary reports whether the line being built ends in "] " or ") ",
+// optionally with a colon before the space ("]: ", "): "), the only places
+// where an interactive prompt can end.
+func (cpn *ColoredProgressNotifier) promptBoundary() bool {
+	acc := strings.TrimSuffix(cpn.lineAcc.String(), " ")
+	acc = strings.TrimSuffix(acc, ":")
+	return strings.HasSuffix(acc, "]") || strings.HasSuffix(acc, ")")
+}
+
+// newline finalizes the current line being built and returns it,
+// resetting the line accumulator.
+func (cpn *ColoredProgressNotifier) newline() string {
+	line := cpn.lineAcc.String()
+	cpn.lineAcc.Reset()
+	return line
+}
+
+// progressKey handles "key=value" lines emitted by FFmpeg's -progress option.
+// The "progress=end" key marks the end of processing, so the bar is completed
+// right away instead of waiting for the stderr pipe to close.
+// Returns true if the line belonged to the -progress stream.
+func (cpn *ColoredProgressNotifier) progressKey(line string) bool {
+	// All -progress keys start with a lowercase letter; skip the regex for anything else
+	if line == "" || line[0] < 'a' || line[0] > 'z' {
+		return false
+	}
+	
+	matches := cpn.progressKeyRx.FindStringSubmatch(line)
+	if len(matches) < 3 {
+		return false
+	}
+	
+	switch matches[1] {
+	case "out_time":
+		// FFmpeg sends out_time_us first; out_time is only needed without it
+		if cpn.outTimeUsSeen {
+			break
+		}
+		us, ok := parseOutTime(matches[2])
+		switch {
+		case ok && cpn.durationUs > 0:
+			cpn.preciseTime = true
+			cpn.updateProgress(us, true)
+		case ok && !cpn.preciseTime:
+			cpn.updateProgress(us, false)
+		}
+	case "out_time_us":
+		us, err := strconv.ParseInt(matches[2], 10, 64)
+		cpn.outTimeUsSeen = cpn.outTimeUsSeen || err == nil
+		if err == nil && us >= 0 && cpn.durationUs > 0 {
+			cpn.preciseTime = true
+			cpn.updateProgress(us, true)
+		}
+	case "frame":
+		if n, err := strconv.Atoi(matches[2]); err == nil {
+			cpn.stats.Frame, cpn.stats.HasFrame = n, true
+		}
+	case "fps":
+		if f, err := strconv.ParseFloat(matches[2], 64); err == nil {
+			cpn.stats.FPS, cpn.stats.HasFPS = f, true
+		}
+	case "speed":
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(matches[2], "x"), 64); err == nil {
+			cpn.stats.Speed, cpn.stats.HasSpeed = f, true
+		}
+	case "bitrate":
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(matches[2], "kbits/s"), 64); err == nil {
+			cpn.bitrate, cpn.hasBitrate = f, true
+		}
+	case "total_size":
+		size, err := strconv.ParseInt(matches[2], 10, 64)
 		if err == nil {
-			return int(fps)
+			cpn.outSize, cpn.hasOutSize = size, true
+		}
+		if err == nil && cpn.expectedSize > 0 && cpn.pbar != nil {
+			cpn.pbar.SetSizeProgress(float64(size)/float64(cpn.expectedSize), true)
+		}
+	case "progress":
+		if matches[2] == "end" {
+			cpn.finish()
+		}
+	}
+	return true
+}
+
+// finish completes the progress bar once, whichever of "progress=end" or Close comes first.
+// With --fpb-clear-on-exit the bar is erased instead, leaving a clean terminal line.
+func (cpn *ColoredProgressNotifier) finish() {
+	if cpn.pbar == nil && !cpn.unitDeadline.IsZero() && !cpn.finished {
+		// Finished while waiting for the frame rate: draw the bar after all
+		cpn.unitDeadline = time.Now()
+		cpn.updateProgress(cpn.rawUs, false)
+	}
+	if cpn.pbar != nil && !cpn.finished {
+		if cpn.opts.ClearOnExit && cpn.mode == ModeBar {
+			cpn.pbar.Clear()
+		} else {
+			cpn.pbar.Finish()
+		}
+		cpn.finished = true
+		cpn.reportProgress()
+	}
+}
+
+// getDuration extracts total duration from FFmpeg output lines.
+// Parses lines like "Duration: 00:01:30.45" and returns total seconds,
+// along with the full duration (including hundredths) in microseconds.
+// The boolean result reports whether the line contained a duration.
+func (cpn *ColoredProgressNotifier) getDuration(line string) (int, int64, bool) {
+	matches := cpn.durationRx.FindStringSubmatch(line)
+	if len(matches) > 4 {
+		secs := seconds(matches[1], matches[2], matches[3])
+		hundredths, _ := strconv.Atoi(matches[4])
+		return secs, int64(secs)*1000000 + int64(hundredths)*10000, true
+	}
+	return 0, 0, false
+}
+
+// inputInfo is an input file and its duration, as listed in FFmpeg's header.
+type inputInfo struct {
+	name       string // Base filename
+	durationUs int64  // Duration in microseconds (0 = unknown)
+}
+
+// trackInput records every "Input #N ... from 'file':" line and the Duration
+// line that follows it, where the header-field scan stops at the first input.
+func (cpn *ColoredProgressNotifier) trackInput(line string) {
+	if name, ok := cpn.getSource(line); ok {
+		cpn.inputs = append(cpn.inputs, inputInfo{name: name})
+		return
+	}
+	if n := len(cpn.inputs); n > 0 && cpn.inputs[n-1].durationUs == 0 {
+		if _, us, ok := cpn.getDuration(line); ok {
+			cpn.inputs[n-1].durationUs = us
+		}
+	}
+}
+
+// inputDurations describes the inputs by name and duration, e.g.
+// "in:02:08 overlay:00:30", or returns "" when there is only one input.
+func (cpn *ColoredProgressNotifier) inputDurations() string {
+	if len(cpn.inputs) < 2 {
+		return ""
+	}
+	parts := make([]string, len(cpn.inputs))
+	for i, in := range cpn.inputs {
+		name := strings.TrimSuffix(in.name, filepath.Ext(in.name))
+		if in.durationUs > 0 {
+			parts[i] = name + ":" + formatClock(time.Duration(in.durationUs)*time.Microsecond)
+		} else {
+			parts[i] = name
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// getFrameCount extracts the number of frames of the input's video stream from
+// stream tags like "NUMBER_OF_FRAMES: 15000" (written by mkvmerge), which some
+// containers provide even when they have no usable duration. Tags of audio and
+// other streams are ignored, so it tracks which stream the dump is listing.
+// The boolean result reports whether the line contained a video frame count.
+func (cpn *ColoredProgressNotifier) getFrameCount(line string) (int, bool) {
+	if strings.Contains(line, "Stream #") {
+		cpn.inVideoStream = strings.Contains(line, ": Video: ")
+		return 0, false
+	}
+	if !cpn.inVideoStream {
+		return 0, false
+	}
+	
+	matches := cpn.frameCountRx.FindStringSubmatch(line)
+	if len(matches) > 1 {
+		frames, err := strconv.Atoi(matches[1])
+		if err == nil && frames > 0 {
+			return frames, true
+		}
+	}
+	return 0, false
+}
+
+// getSource extracts the source filename from FFmpeg output lines.
+// Parses lines like "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'file.mp4':"
+// Returns just the base filename for display, and whether the line contained one.
+func (cpn *ColoredProgressNotifier) getSource(line string) (string, bool) {
+	matches := cpn.sourceRx.FindStringSubmatch(line)
+	if len(matches) > 1 {
+		return filepath.Base(matches[1]), true
+	}
+	return "", false
+}
+
+// getOutput extracts the output filename from FFmpeg output lines.
+// Parses lines like "Output #0, mp4, to 'file.mp4':"
+// Returns just the base filename for display, and whether the line contained one.
+// Segment filename patterns are shown with a "*" for the number ("out*.ts").
+func (cpn *ColoredProgressNotifier) getOutput(line string) (string, bool) {
+	matches := cpn.outputRx.FindStringSubmatch(line)
+	if len(matches) > 1 {
+		return segmentNumberRx.ReplaceAllString(filepath.Base(matches[1]), "*"), true
+	}
+	return "", false
+}
+
+// segmentNumberRx matches the segment number in segment filename patterns,
+// e.g. "%03d" in "out%03d.ts".
+var segmentNumberRx = regexp.MustCompile(`%0?\d*d`)
+
+// isSegment reports whether line announces a new media segment, as the segment,
+// HLS and DASH muxers log "Opening 'out003.ts' for writing" for each one.
+// Playlists and manifests are reopened on every segment, so they don't count.
+func (cpn *ColoredProgressNotifier) isSegment(line string) bool {
+	matches := cpn.segmentRx.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return false
+	}
+	switch filepath.Ext(strings.TrimSuffix(matches[1], ".tmp")) {
+	case ".m3u8", ".mpd", ".csv", ".ffconcat", ".ffcat", ".m3u":
+		return false
+	}
+	return true
+}
+
+// getReading extracts the file a playlist or concat demuxer moves on to from
+// lines like "Opening 'b.mp4' for reading", returning its base filename.
+// Playlists reopened for refreshing aren't an input being processed.
+func (cpn *ColoredProgressNotifier) getReading(line string) (string, bool) {
+	matches := cpn.readingRx.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return "", false
+	}
+	switch filepath.Ext(matches[1]) {
+	case ".m3u8", ".mpd", ".ffconcat", ".ffcat", ".m3u":
+		return "", false
+	}
+	return filepath.Base(matches[1]), true
+}
+
+// getFPS extracts frame rate information from FFmpeg output lines.
+// Parses lines containing FPS information (e.g. "29.97 fps" or "30000/1001 fps")
+// and returns the exact frame rate, and whether the line contained one.
+func (cpn *ColoredProgressNotifier) getFPS(line string) (frameRate, bool) {
+	matches := cpn.fpsRx.FindStringSubmatch(line)
+	if len(matches) > 1 {
+		return parseFrameRate(matches[1])
+	}
+	return frameRate{}, false
+}
+
+// progress parses progress information from FFmpeg output and updates the progress bar.
+// Handles lines like "time=00:00:30.45" and converts them to progress updates.
+// Switches between time-based and frame-based progress depending on available FPS info.
+// The stats fields are parsed with the hand-written scanner when --fpb-fast-parse is set.
+func (cpn *ColoredProgressNotifier) progress(line string) {
+	var st statsLine
+	if cpn.opts.FastParse {
+		st = scanStats(line)
+	} else {
+		st = parseStatsRegex(line)
+	}
+	if st.HasTime {
+		cpn.stats = st
+		cpn.updateProgress(st.TimeUs, false)
+	} else if st.HasFrame && cpn.duration == 0 {
+		// Some raw streams report "time=N/A": the frame count is all there is
+		cpn.stats = st
+		cpn.updateProgress(cpn.rawUs, false)
+	}
+}
+
+// updateProgress moves the progress bar to the given output timestamp in microseconds.
+// When precise is true, the percentage is computed from the timestamp against the
+// duration in microseconds rather than from whole seconds or frames.
+func (cpn *ColoredProgressNotifier) updateProgress(us int64, precise bool) {
+	if cpn.diskFull {
+		return
+	}
+	us = cpn.continuousTime(us)
+	total := cpn.duration
+	current := int(us / 1000000)
+	unit := "seconds"
+	
+	switch {
+	case total == 0 && cpn.stats.HasFrame:
+		// No usable duration: count frames against the frame count from the
+		// container tags, if any (otherwise the bar is indeterminate)
+		unit = "frames"
+		current = cpn.stats.Frame
+		total = cpn.frameCount
+	case cpn.fps > 0 && !cpn.opts.TimeMode:
+		unit = "frames"
+		current = int(cpn.frameRate.Frames(us))
+		if total > 0 {
+			total = int(cpn.frameRate.Frames(cpn.durationUs))
+		}
+	}
+	
+	unitsPerSecond := 1.0
+	if unit == "frames" {
+		unitsPerSecond = cpn.frameRate.Float()
+	}
+	
+	// Early stats lines may come before the frame rate is known (and read "fps=N/A"):
+	// hold the bar back a little rather than settle on seconds right away
+	if cpn.pbar == nil && unit == "seconds" && !cpn.fpsFound && !cpn.opts.TimeMode && !cpn.finished {
+		if cpn.unitDeadline.IsZero() {
+			cpn.unitDeadline = time.Now().Add(cpn.unitWait)
+		}
+		if time.Now().Before(cpn.unitDeadline) {
+			cpn.lastUs = us
+			return
+		}
+	}
+	
+	if cpn.pbar == nil {
+		desc := cpn.description()
+		cpn.pbar = NewProgressBar(desc, total, unit, cpn.useColors, cpn.file)
+		cpn.pbar.SetMode(cpn.mode)
+		cpn.pbar.SetMaxBytesPerSec(cpn.opts.MaxBytesPerSec)
+		cpn.pbar.SetInlinePercent(cpn.opts.InlinePercent)
+		cpn.pbar.SetFinishNewline(cpn.finishNewline)
+		cpn.pbar.SetTargetFPS(cpn.fps)
+		cpn.pbar.SetCountUnit(cpn.opts.CountUnit, unitsPerSecond)
+		cpn.pbar.SetTmuxTitle(cpn.opts.Tmux && inTmux())
+		cpn.pbar.SetClearStyle(cpn.opts.ClearStyle)
+		cpn.pbar.SetSpeedColors(cpn.opts.SpeedColors)
+		cpn.pbar.SetDualFPS(cpn.opts.DualFPS)
+		cpn.pbar.SetFinalColors(cpn.opts.FinalColors)
+		cpn.pbar.SetMaxBarWidth(cpn.opts.MaxBarWidth)
+		cpn.pbar.SetBrackets(cpn.opts.Brackets)
+		cpn.pbar.SetTrackColor(cpn.opts.TrackColor)
+		cpn.pbar.SetETAGauge(cpn.opts.ETAGauge)
+		cpn.pbar.SetPercentPrecision(cpn.opts.PercentPrecision)
+		cpn.pbar.SetDecimalSeparator(cpn.opts.DecimalSeparator)
+		cpn.pbar.SetStartDelay(cpn.opts.StartDelay)
+		if cpn.gpu != nil {
+			cpn.pbar.SetGPU(cpn.gpu.Utilization)
+		}
+		cpn.pbar.SetQuiet(cpn.mode == ModeBar && cpn.durationUs > 0 &&
+			time.Duration(cpn.durationUs)*time.Microsecond < cpn.opts.MinDuration)
+		termWidth, _ := getTerminalSize(cpn.file)
+		debugf("bar %q: total %d %s, count unit %s, terminal width %d, quiet %t",
+			desc, total, unit, cpn.opts.CountUnit, termWidth, cpn.pbar.quiet)
+		cpn.pbar.SetHideCursor(cpn.mode == ModeBar && isTerminalStream(cpn.file))
+	} else if total > 0 && (total != cpn.pbar.total || unit != cpn.pbar.unit) {
+		// The duration or the frame rate became known after the progress started
+		cpn.pbar.SetTotal(total)
+		cpn.pbar.unit = unit
+		cpn.pbar.SetCountUnit(cpn.opts.CountUnit, unitsPerSecond)
+		cpn.pbar.SetTargetFPS(cpn.fps)
+		debugf("bar total %d %s", total, unit)
+	}
+	
+	cpn.pbar.SetSpeed(cpn.stats.Speed, cpn.stats.HasSpeed)
+	cpn.pbar.SetLiveFPS(cpn.stats.FPS, cpn.stats.HasFPS)
+	cpn.pbar.SetSegments(cpn.segments)
+	if cpn.opts.Plain {
+		cpn.pbar.SetPlain(cpn.plainStats())
+	}
+	if precise && cpn.durationUs > 0 {
+		cpn.pbar.UpdateFraction(current, math.Max(float64(us)/float64(cpn.durationUs), cpn.sizeLimitFraction()))
+	} else if f := cpn.sizeLimitFraction(); f > 0 && (total == 0 || f > float64(current)/float64(total)) {
+		cpn.pbar.UpdateFraction(current, f)
+	} else {
+		cpn.pbar.Update(current)
+	}
+	cpn.lastUs = us
+	cpn.reportProgress()
+}
+
+// plainStats formats the latest stats the way FFmpeg prints its own stats line,
+// for --fpb-plain, e.g.
+// "frame=  240 fps= 48 size=    1024kB time=00:00:10.00 bitrate= 838.9kbits/s speed=1.92x".
+// Like FFmpeg, unknown values read N/A and audio-only encodes have no frame or fps.
+func (cpn *ColoredProgressNotifier) plainStats() string {
+	var b strings.Builder
+	if cpn.stats.HasFrame {
+		fmt.Fprintf(&b, "frame=%5d fps=%3.0f ", cpn.stats.Frame, cpn.stats.FPS)
+	}
+	if cpn.hasOutSize {
+		fmt.Fprintf(&b, "size=%8dkB ", cpn.outSize/1024)
+	} else {
+		b.WriteString("size=N/A ")
+	}
+	fmt.Fprintf(&b, "time=%s ", ffmpegTime(time.Duration(max(cpn.rawUs, 0))*time.Microsecond))
+	if cpn.hasBitrate {
+		fmt.Fprintf(&b, "bitrate=%6.1fkbits/s ", cpn.bitrate)
+	} else {
+		b.WriteString("bitrate=N/A ")
+	}
+	if cpn.stats.HasSpeed {
+		fmt.Fprintf(&b, "speed=%4.3gx", cpn.stats.Speed)
+	} else {
+		b.WriteString("speed=N/A")
+	}
+	return b.String()
+}
+
+// timeResetThreshold is how far FFmpeg's output time must go back to be taken
+// as a loop or segment restarting it, rather than jitter.
+const timeResetThreshold = int64(time.Second / time.Microsecond)
+
+// continuousTime turns an output timestamp reported by FFmpeg into one that
+// never goes back. When the time restarts and the input is looped a known
+// number of times, the time reached so far is added to the following ones;
+// otherwise the bar just holds its position until the time catches up.
+func (cpn *ColoredProgressNotifier) continuousTime(us int64) int64 {
+	if us+timeResetThreshold < cpn.rawUs {
+		if cpn.loops > 0 {
+			cpn.timeOffsetUs += cpn.rawUs
 		}
+		debugf("output time went back from %dus to %dus, offset %dus", cpn.rawUs, us, cpn.timeOffsetUs)
 	}
-	return 0
+	cpn.rawUs = us
+	return max(us+cpn.timeOffsetUs, cpn.lastUs)
 }
 
-// progress parses progress information from FFmpeg output and updates the progress bar.
-// Handles lines like "time=00:00:30.45" and converts them to progress updates.
-// Switches between time-based and frame-based progress depending on available FPS info.
-func (cpn *ColoredProgressNotifier) progress(line string) {
-	matches := cpn.progressRx.FindStringSubmatch(line)
-	if len(matches) > 3 {
-		total := cpn.duration
-		current := seconds(matches[1], matches[2], matches[3])
-		unit := "seconds"
-		
-		if cpn.fps > 0 {
-			unit = "frames"
-			current *= cpn.fps
-			if total > 0 {
-				total *= cpn.fps
-			}
+// sizeLimitFraction returns how close the output is to its -fs size limit (0-1),
+// or 0 without a limit. FFmpeg stops at the limit, possibly long before the end
+// of the input, so the progress is whichever of time and size is further along.
+func (cpn *ColoredProgressNotifier) sizeLimitFraction() float64 {
+	if cpn.sizeLimit <= 0 {
+		return 0
+	}
+	return math.Min(float64(cpn.outSize)/float64(cpn.sizeLimit), 1)
+}
+
+// reportProgress hands the current progress to the Pushgateway pusher, the
+// checkpoint writer, the status file writer and the stats CSV file, if enabled.
+func (cpn *ColoredProgressNotifier) reportProgress() {
+	if cpn.pbar == nil {
+		return
+	}
+	percentage, _, _ := cpn.pbar.stats()
+	if cpn.pusher != nil {
+		cpn.pusher.Update(metricsSample{Percent: percentage, FPS: cpn.stats.FPS, Speed: cpn.stats.Speed})
+	}
+	if cpn.checkpoint != nil {
+		cpn.checkpoint.Update(checkpoint{
+			Desc:           cpn.pbar.desc,
+			Percent:        percentage,
+			ElapsedSeconds: time.Since(cpn.pbar.startTime).Seconds(),
+			OutTimeUs:      cpn.lastUs,
+			Finished:       cpn.finished,
+		})
+	}
+	if cpn.status != nil {
+		cpn.status.Update(cpn.pbar.statusLine())
+	}
+	if cpn.sdNotify != nil {
+		cpn.sdNotify.Update(cpn.pbar.desc + ": " + cpn.pbar.statusLine())
+	}
+	if cpn.jobs != nil {
+		cpn.jobs.Update(percentage)
+		if cpn.opts.JobOverall {
+			cpn.pbar.SetHeader(fmt.Sprintf(T(msgJobOverall), cpn.jobs.index, cpn.jobs.total, cpn.jobs.Overall()))
 		}
-		
-		if cpn.pbar == nil {
-			desc := cpn.source
-			if desc == "" {
-				desc = "Processing"
-			}
-			cpn.pbar = NewProgressBar(desc, total, unit, cpn.useColors, cpn.file)
+	}
+	if cpn.statsCSV != nil {
+		cpn.statsCSV.Append(statsRow{
+			Time:       time.Now(),
+			Percent:    percentage,
+			Frame:      cpn.stats.Frame,
+			HasFrame:   cpn.stats.HasFrame,
+			FPS:        cpn.stats.FPS,
+			HasFPS:     cpn.stats.HasFPS,
+			Speed:      cpn.stats.Speed,
+			HasSpeed:   cpn.stats.HasSpeed,
+			Bitrate:    cpn.bitrate,
+			HasBitrate: cpn.hasBitrate,
+			Size:       cpn.outSize,
+			HasSize:    cpn.hasOutSize,
+		})
+	}
+}
+
+// description returns the label shown to the left of the progress bar.
+// Prefers the source filename, falling back to the output filename when the
+// input line wasn't recognized (e.g. with -hide_banner or unusual demuxers).
+// Playlists and concats show the file currently being read instead.
+// With --fpb-input-durations, several inputs are listed with their durations.
+func (cpn *ColoredProgressNotifier) description() string {
+	if cpn.opts.InputDurations {
+		if desc := cpn.inputDurations(); desc != "" {
+			return desc
 		}
-		
-		cpn.pbar.Update(current)
 	}
+	if cpn.reading != "" {
+		return cpn.reading
+	}
+	if cpn.source != "" {
+		return cpn.source
+	}
+	if cpn.output != "" {
+		return cpn.output
+	}
+	if cpn.opts.Placeholder != "" {
+		return cpn.opts.Placeholder
+	}
+	return T(msgProcessing)
 }
 
 // forwardUserInput reads user input and forwards it to FFmpeg's stdin.
 // This function runs in a goroutine when interactive prompts are detected.
-// It reads a complete line (including newline) and sends it to FFmpeg.
+// It reads a complete line and sends it to FFmpeg terminated by "\n", even if
+// the user's terminal sent "\r\n". If input ends (Ctrl+D) before anything was
+// typed, an empty answer is sent so FFmpeg's prompt is declined (or its default
+// taken) instead of left hanging.
+//
+// StopInput ends the read, so the goroutine doesn't outlive an interrupted or
+// finished run, nor take input typed after it (except on Windows, see waitReadable).
 func (cpn *ColoredProgressNotifier) forwardUserInput() {
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
-	if err != nil {
+	defer recoverPanic()
+	cpn.mu.Lock()
+	input := cpn.input
+	cpn.mu.Unlock()
+	
+	line, _ := input.ReadString('\n')
+	select {
+	case <-cpn.inputDone:
+		// Stopped while waiting, or answered too late for FFmpeg
 		return
+	default:
 	}
+	line = strings.TrimRight(line, "\r\n") + "\n"
 	
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
 	cpn.stdinWriter.Write([]byte(line))
 	cpn.waitingForInput = false
 }
 
+// StopInput abandons a pending prompt answer, so an interrupted or finished run
+// neither waits for the user nor forwards a late answer to FFmpeg.
+func (cpn *ColoredProgressNotifier) StopInput() {
+	cpn.stopInput.Do(func() {
+		close(cpn.inputDone)
+		cpn.mu.Lock()
+		defer cpn.mu.Unlock()
+		cpn.inputSource.stop()
+	})
+}
+
+// SetInput sets where answers to FFmpeg's prompts are read from (os.Stdin by default).
+func (cpn *ColoredProgressNotifier) SetInput(r io.Reader) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.inputSource = &stoppableReader{r: r, done: cpn.inputDone}
+	cpn.input = bufio.NewReader(cpn.inputSource)
+}
+
+// SetMetricsPusher makes every progress update also feed the given Pushgateway pusher.
+func (cpn *ColoredProgressNotifier) SetMetricsPusher(mp *metricsPusher) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.pusher = mp
+}
+
+// SetCheckpointWriter makes every progress update also feed the given checkpoint writer.
+func (cpn *ColoredProgressNotifier) SetCheckpointWriter(cw *checkpointWriter) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.checkpoint = cw
+}
+
+// SetSDNotifier makes every progress update also feed the given systemd notifier.
+func (cpn *ColoredProgressNotifier) SetSDNotifier(sn *sdNotifier) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.sdNotify = sn
+}
+
+// SetStatusWriter makes every progress update also feed the given status file writer.
+func (cpn *ColoredProgressNotifier) SetStatusWriter(sw *statusWriter) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.status = sw
+}
+
+// SetJobBoard makes every progress update also share the percentage with the
+// other jobs of a batch, and with --fpb-job-overall draw the overall progress
+// above the bar.
+func (cpn *ColoredProgressNotifier) SetJobBoard(jb *jobBoard) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.jobs = jb
+}
+
+// SetStatsCSV makes every progress update also append a row to the given CSV writer.
+func (cpn *ColoredProgressNotifier) SetStatsCSV(cw *csvWriter) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.statsCSV = cw
+}
+
+// SetGPUMonitor shows the utilization polled by g alongside the encode statistics.
+func (cpn *ColoredProgressNotifier) SetGPUMonitor(g *gpuMonitor) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.gpu = g
+}
+
+// RefreshIdle is called every idle and redraws the bar unless it was drawn
+// during the last half of that, so the ETA (and the pulse of an indeterminate
+// bar) keep moving while FFmpeg is slow to report progress. Nothing is drawn
+// before the bar appears, after it is finished, while a prompt awaits an
+// answer, or once FFmpeg's output has ended or the run was interrupted (see StopInput).
+func (cpn *ColoredProgressNotifier) RefreshIdle(idle time.Duration) {
+	select {
+	case <-cpn.inputDone:
+		return
+	default:
+	}
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.pbar == nil || cpn.finished || cpn.waitingForInput || time.Since(cpn.pbar.lastUpdate) < idle/2 {
+		return
+	}
+	cpn.pbar.refresh()
+}
+
+// SetUnitWait sets how long the first progress waits for the frame rate
+// (see unitWaitTimeout). A saved log replayed at once has nothing to wait for.
+func (cpn *ColoredProgressNotifier) SetUnitWait(d time.Duration) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.unitWait = d
+}
+
+// SetPromptDetection enables or disables interactive prompt detection.
+// It can be disabled when FFmpeg was told how to answer (-y or -n) and won't prompt.
+func (cpn *ColoredProgressNotifier) SetPromptDetection(enabled bool) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.promptsEnabled = enabled
+}
+
+// SetFinishNewline controls whether the progress bar ends with a newline when finished.
+// See ProgressBar.SetFinishNewline.
+func (cpn *ColoredProgressNotifier) SetFinishNewline(enabled bool) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.finishNewline = enabled
+	if cpn.pbar != nil {
+		cpn.pbar.SetFinishNewline(enabled)
+	}
+}
+
+// ProgressWriter returns a writer that parses FFmpeg's -progress output arriving
+// on its own stream (e.g. stdout with "-progress pipe:1"), separately from stderr.
+// Only "key=value" progress lines are handled; they are never added to the
+// stderr buffer or the line history.
+func (cpn *ColoredProgressNotifier) ProgressWriter() io.Writer {
+	return &progressStream{cpn: cpn}
+}
+
+// progressStream is the io.Writer returned by ProgressWriter.
+type progressStream struct {
+	cpn  *ColoredProgressNotifier
+	line []byte // Current line being built
+}
+
+// Write parses complete progress lines, keeping any partial line for the next call.
+func (ps *progressStream) Write(p []byte) (int, error) {
+	ps.cpn.mu.Lock()
+	defer ps.cpn.mu.Unlock()
+	for _, char := range p {
+		if char == '\r' || char == '\n' {
+			ps.cpn.progressKey(string(ps.line))
+			ps.line = ps.line[:0]
+		} else {
+			ps.line = append(ps.line, char)
+		}
+	}
+	return len(p), nil
+}
+
+// WaitingForInput reports whether a prompt is currently awaiting the user's answer.
+func (cpn *ColoredProgressNotifier) WaitingForInput() bool {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.waitingForInput
+}
+
+// Duration returns the total input duration parsed from FFmpeg's header.
+// Returns zero until a "Duration:" line has been seen.
+func (cpn *ColoredProgressNotifier) Duration() time.Duration {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return time.Duration(cpn.durationUs) * time.Microsecond
+}
+
+// SetDuration sets the total duration in microseconds, taking precedence over
+// the "Duration:" line of FFmpeg's output. Used when fpb knows the duration
+// better than FFmpeg reports it, e.g. for concat protocol inputs.
+func (cpn *ColoredProgressNotifier) SetDuration(us int64) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.durationUs = us
+	cpn.duration = int(us / 1000000)
+	cpn.durationFound = true
+}
+
+// SetLoops sets how many extra times the input is looped with -stream_loop,
+// which multiplies the duration and lets restarting timestamps add up.
+func (cpn *ColoredProgressNotifier) SetLoops(loops int) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.loops = loops
+}
+
+// SetExpectedSize sets the expected output size in bytes, e.g. the input size
+// for a remux, enabling the size fill of the split bar.
+func (cpn *ColoredProgressNotifier) SetExpectedSize(size int64) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.expectedSize = size
+}
+
+// DTSWarning returns the warning to print after the encode when FFmpeg warned
+// about non-monotonic timestamps, e.g. "⚠ 37 DTS warnings ...", colored yellow.
+// Reports false when there were none, or in ModeJSON, whose output must stay JSON.
+func (cpn *ColoredProgressNotifier) DTSWarning() (string, bool) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.dtsWarnings == 0 || cpn.mode == ModeJSON {
+		return "", false
+	}
+	warning := fmt.Sprintf(T(msgDTSWarnings), cpn.dtsWarnings)
+	if cpn.useColors && cpn.colors != nil {
+		warning = cpn.colors.Yellow + warning + cpn.colors.Reset
+	}
+	return warning, true
+}
+
+// Benchmark returns the resource usage FFmpeg reported when run with -benchmark.
+func (cpn *ColoredProgressNotifier) Benchmark() benchmark {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.bench
+}
+
+// FinalSize returns the output size in bytes that FFmpeg reported in its last
+// stats line (Lsize=), or -1 if it didn't, e.g. for the null muxer.
+func (cpn *ColoredProgressNotifier) FinalSize() int64 {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.finalSize
+}
+
+// DiskFull reports whether FFmpeg ran out of disk space and was stopped.
+func (cpn *ColoredProgressNotifier) DiskFull() bool {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.diskFull
+}
+
+// SetSizeLimit sets the output size limit given with -fs, in bytes.
+// See sizeLimitFraction.
+func (cpn *ColoredProgressNotifier) SetSizeLimit(limit int64) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.sizeLimit = limit
+}
+
+// SetFrameRate sets the frame rate used for frame counts, taking precedence over
+// the rate parsed from the input stream information. Used when the output frame
+// rate is forced with "-r", since the frame= counter counts output frames.
+func (cpn *ColoredProgressNotifier) SetFrameRate(fr frameRate) {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.frameRate = fr
+	cpn.fps = int(fr.Float())
+	cpn.fpsFound = true
+}
+
+// FPS returns the frame rate parsed from the input stream information.
+// Returns zero until a stream with a frame rate has been seen.
+func (cpn *ColoredProgressNotifier) FPS() int {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.fps
+}
+
+// Source returns the base name of the input file parsed from FFmpeg's header.
+func (cpn *ColoredProgressNotifier) Source() string {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.source
+}
+
+// Output returns the base name of the output file parsed from FFmpeg's header.
+func (cpn *ColoredProgressNotifier) Output() string {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return cpn.output
+}
+
 // GetStderrContent returns all collected stderr content.
 // This is used to display error messages when FFmpeg exits with an error code.
 func (cpn *ColoredProgressNotifier) GetStderrContent() string {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
 	return cpn.stderrBuffer.String()
 }
 
+// ShowCursor shows the cursor again if the progress bar hid it, and clears the
+// tmux pane title it set. Call it on every exit path that doesn't go through
+// Close, such as errors and Ctrl+C.
+func (cpn *ColoredProgressNotifier) ShowCursor() {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.pbar != nil {
+		cpn.pbar.ShowCursor()
+		cpn.pbar.ResetTitle()
+	}
+}
+
+// Resize reflows the final progress bar to the new terminal width once processing
+// has finished (see ProgressBar.Redraw). In-progress bars pick up the new width
+// on their next update anyway. Does nothing with --fpb-clear-on-exit.
+func (cpn *ColoredProgressNotifier) Resize() {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.pbar != nil && cpn.finished && !cpn.opts.ClearOnExit {
+		cpn.pbar.Redraw()
+	}
+}
+
+// Fail leaves the progress bar on screen in the failure color when FFmpeg
+// fails (see ProgressBar.Fail). Erased bars stay erased.
+func (cpn *ColoredProgressNotifier) Fail() {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.pbar == nil || cpn.diskFull || cpn.opts.ClearOnExit && cpn.finished {
+		return
+	}
+	cpn.pbar.Fail()
+	cpn.finished = true
+}
+
 // Close finalizes the progress display by completing the progress bar.
 func (cpn *ColoredProgressNotifier) Close() {
-	if cpn.pbar != nil {
-		cpn.pbar.Finish()
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	cpn.finish()
+}
+
+// hasOutput reports whether FFmpeg's arguments end in an output, as opposed to
+// e.g. "ffmpeg -i in.mp4", which only describes the input. "-" (stdout) is an output.
+func hasOutput(args []string) bool {
+	if len(args) < 2 {
+		return false
 	}
+	last := args[len(args)-1]
+	return last == "-" || !strings.HasPrefix(last, "-") && args[len(args)-2] != "-i"
 }
 
 // main is the entry point for the fpb (FFmpeg Progress Bar) application.
+// It runs fpb with the command-line arguments and exits with its status.
+func main() {
+	defer recoverPanic()
+	os.Exit(Run(os.Args[1:]))
+}
+
+// terminalReset resets colors and shows the cursor again.
+const terminalReset = "\033[0m\033[?25h"
+
+// recoverPanic must be deferred at the top of main and of every goroutine.
+// On a panic it restores the terminal, so a crash mid-render doesn't leave
+// colors active or the cursor hidden, then reports the panic with its stack
+// trace and exits with status 2, as an unrecovered panic would.
+func recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if isTerminalStream(os.Stderr) {
+		fmt.Fprint(os.Stderr, terminalReset+"\n")
+	}
+	fmt.Fprintf(os.Stderr, "panic: %v\n\n%s", r, debug.Stack())
+	os.Exit(2)
+}
+
+// exitInterrupted is the exit status used when fpb is stopped with Ctrl+C.
+const exitInterrupted = 128 + int(syscall.SIGINT)
+
+// exitTimeout is how long FFmpeg may keep running after closing its output
+// before it is considered hung and killed.
+const exitTimeout = 10 * time.Second
+
+// exitDiskFull is the exit status used when FFmpeg was stopped because the disk
+// is full. It is ENOSPC's number on Linux and macOS.
+const exitDiskFull = 28
+
+// Run executes a single fpb invocation and returns its exit status.
 // 
 // This function:
 // 1. Validates command-line arguments
-// 2. Sets up signal handling for graceful shutdown
-// 3. Creates pipes for FFmpeg communication (stdin/stderr)
-// 4. Starts FFmpeg as a subprocess
-// 5. Parses FFmpeg output in real-time to display progress
-// 6. Handles user interaction for prompts (like file overwrite)
-// 7. Displays error output only when FFmpeg fails
-// 8. Exits with the same code as FFmpeg
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <ffmpeg-args>\n", os.Args[0])
-		os.Exit(1)
+// 2. Handles fpb's own modes (--fpb-sample, --fpb-keep-going, passthrough)
+// 3. Sets up signal handling for graceful shutdown
+// 4. Runs FFmpeg with the real process, stdin and stderr through run
+func Run(args []string) int {
+	opts, ffmpegArgs, err := parseArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	
+	stopDebug, err := startDebugLog(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer stopDebug()
+	
+	if opts.Version {
+		printVersion(os.Stdout)
+		return 0
+	}
+	
+	if opts.ThemeAuto && supportsColor(os.Stderr) {
+		activeTheme = detectTheme()
+		debugf("theme %s (from terminal background)", activeTheme)
+	}
+	
+	if opts.Sample != "" {
+		return runSample(opts.Sample, opts)
+	}
+	if opts.Simulate > 0 {
+		return runSimulate(opts.Simulate, opts)
+	}
+	if opts.Replay {
+		return runReplay(os.Stdin, opts.ReplayPaced, opts)
+	}
+	
+	if opts.KeepGoing {
+		return runBatch(os.Stdin, withoutFlag(args, "keep-going"))
+	}
+	
+	if len(ffmpegArgs) < 1 {
+		if len(args) > 0 {
+			// Only fpb options were given: running FFmpeg without arguments would just print its banner
+			fmt.Fprintf(os.Stderr, "Error: no ffmpeg arguments given after the fpb options\n\n")
+		}
+		printUsage(os.Stderr, os.Args[0])
+		return 1
+	}
+	
+	// Informational commands (-version, -h, -formats...) have no progress to show,
+	// so FFmpeg's output is passed through untouched
+	if isInfoCommand(ffmpegArgs) {
+		debugf("informational command, passing output through")
+		return runPassthrough(ffmpegArgs)
 	}
 	
 	// Set up signal handling for graceful shutdown (Ctrl+C)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	
+	newCommand := newExecProcess
+	if opts.PTY {
+		newCommand = newPTYProcess
+	}
+	return run(opts, ffmpegArgs, runEnv{
+		newCommand: newCommand,
+		stdin:      os.Stdin,
+		stderr:     os.Stderr,
+		signals:    sigChan,
+	})
+}
+
+// ffmpegProcess is the part of *exec.Cmd that run needs, so the FFmpeg
+// process can be replaced by one that replays a scripted output.
+type ffmpegProcess interface {
+	StderrPipe() (io.ReadCloser, error)
+	StdoutPipe() (io.ReadCloser, error)
+	StdinPipe() (io.WriteCloser, error)
+	Start() error
+	Wait() error // Errors with an ExitCode() method report FFmpeg's exit status
+	Kill() error
+}
+
+// commandRunner creates the (not yet started) process for a command line.
+type commandRunner func(name string, args ...string) ffmpegProcess
+
+// execProcess runs a real command through os/exec.
+type execProcess struct {
+	*exec.Cmd
+}
+
+// newExecProcess is the commandRunner used outside of tests.
+func newExecProcess(name string, args ...string) ffmpegProcess {
+	return execProcess{exec.Command(name, args...)}
+}
+
+// Kill stops the process immediately.
+func (p execProcess) Kill() error {
+	return p.Process.Kill()
+}
+
+// runEnv holds what run talks to: the FFmpeg process, the user's stdin,
+// fpb's stderr, and the signals that interrupt the encode.
+type runEnv struct {
+	newCommand commandRunner
+	stdin      io.Reader
+	stderr     io.Writer
+	signals    <-chan os.Signal
+}
+
+// run runs FFmpeg with the given arguments and returns its exit status.
+//
+// This function:
+// 1. Creates pipes for FFmpeg communication (stdin/stderr)
+// 2. Starts FFmpeg as a subprocess
+// 3. Parses FFmpeg output in real-time to display progress
+// 4. Handles user interaction for prompts (like file overwrite)
+// 5. Stops FFmpeg when a signal arrives on env.signals
+// 6. Displays error output only when FFmpeg fails
+// 7. Returns the same exit code as FFmpeg
+func run(opts *Options, ffmpegArgs []string, env runEnv) int {
+	// Without -y/-n FFmpeg asks before overwriting. --fpb-overwrite=yes|no gives
+	// FFmpeg the flag, and with a non-terminal stdin nobody can answer, so never
+	// overwrite instead of hanging on the prompt. Prompts are only watched for
+	// when FFmpeg ends up without either flag.
+	overwriteFlag := findOverwriteFlag(ffmpegArgs)
+	policyFlag := ""
+	switch opts.Overwrite {
+	case OverwriteYes:
+		policyFlag = "-y"
+	case OverwriteNo:
+		policyFlag = "-n"
+	}
+	if policyFlag != "" && overwriteFlag != "" && overwriteFlag != policyFlag {
+		fmt.Fprintf(env.stderr, "Error: --fpb-overwrite=%s conflicts with FFmpeg's %s\n", opts.Overwrite, overwriteFlag)
+		return 1
+	}
+	if overwriteFlag == "" && policyFlag == "" && !isTerminalStream(env.stdin) {
+		policyFlag = "-n"
+		debugf("stdin is not a terminal, never overwriting")
+	}
+	if overwriteFlag == "" && policyFlag != "" {
+		overwriteFlag = policyFlag
+		ffmpegArgs = append([]string{overwriteFlag}, ffmpegArgs...)
+		debugf("injected %s", overwriteFlag)
+	}
+	
+	// Fail early if the output file can't be written
+	if err := checkOutputWritable(outputPath(ffmpegArgs), overwriteFlag == "-n"); err != nil {
+		fmt.Fprintf(env.stderr, "Error: %v\n", err)
+		return 1
+	}
 	
-	// Prepare FFmpeg command with user arguments
-	args := append([]string{"ffmpeg"}, os.Args[1:]...)
-	cmd := exec.Command(args[0], args[1:]...)
+	// Prepare FFmpeg command with user arguments, asking FFmpeg to also report
+	// machine-readable progress on stderr so completion is detected exactly.
+	// Commands without an output have no progress, so nothing is added to them.
+	// FFmpeg writes -progress only to the last destination given, so when the
+	// user asked for it themselves nothing is added: their stream is read from
+	// stdout (pipe:1) or stderr (pipe:2), and any other destination is left to
+	// them, with the progress taken from the stats lines instead.
+	progressTarget := findProgressTarget(ffmpegArgs)
+	progressOnStdout := isStdoutTarget(progressTarget)
+	cmdArgs := []string{"ffmpeg"}
+	if progressTarget == "" && hasOutput(ffmpegArgs) {
+		cmdArgs = append(cmdArgs, "-progress", "pipe:2")
+	} else if progressTarget != "" {
+		debugf("using the user's -progress %s", progressTarget)
+	}
+	cmdArgs = append(cmdArgs, ffmpegArgs...)
+	cmd := env.newCommand(cmdArgs[0], cmdArgs[1:]...)
+	debugf("running %q", cmdArgs)
 	
 	// Create stderr pipe for progress parsing
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating stderr pipe: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(env.stderr, "Error creating stderr pipe: %v\n", err)
+		return 1
+	}
+	
+	// Create stdout pipe when FFmpeg reports -progress there
+	var stdout io.Reader
+	if progressOnStdout {
+		stdout, err = cmd.StdoutPipe()
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Error creating stdout pipe: %v\n", err)
+			return 1
+		}
 	}
 	
 	// Create stdin pipe for user interaction forwarding
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating stdin pipe: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(env.stderr, "Error creating stdin pipe: %v\n", err)
+		return 1
 	}
 	
 	// Initialize progress notifier with color detection
-	useColors := supportsColor(os.Stderr)
-	notifier := NewColoredProgressNotifier(os.Stderr, useColors, stdin)
-	defer notifier.Close()
+	useColors := supportsColor(env.stderr)
+	notifier := NewColoredProgressNotifier(env.stderr, useColors, stdin, opts)
+	notifier.SetInput(env.stdin)
+	notifier.SetPromptDetection(overwriteFlag == "")
+	debugf("colors %t, mode %s, prompt detection %t", useColors, notifier.mode, overwriteFlag == "")
+	if opts.IdleRefresh > 0 && notifier.mode == ModeBar && isTerminalStream(env.stderr) {
+		// Stopped last, once StopInput has ended the redraws
+		loop := startPeriodic(opts.IdleRefresh, func() { notifier.RefreshIdle(opts.IdleRefresh) })
+		defer loop.Stop()
+	}
+	defer notifier.ShowCursor()
+	defer notifier.StopInput()
+	
+	if fr, ok := outputFrameRate(ffmpegArgs); ok {
+		notifier.SetFrameRate(fr)
+		debugf("frame rate %d/%d from output -r", fr.Num, fr.Den)
+	}
+	
+	// FFmpeg may report only the first file's duration for concat protocol inputs
+	if files := concatInputs(ffmpegArgs); files != nil {
+		if us, ok := concatDuration(files); ok {
+			notifier.SetDuration(us)
+			debugf("duration %dus summed over %d concat inputs", us, len(files))
+		}
+	}
+	if loops, ok := streamLoops(ffmpegArgs); ok {
+		notifier.SetLoops(loops)
+		debugf("input looped %d more times with -stream_loop", loops)
+	}
+	if limit, ok := outputSizeLimit(ffmpegArgs); ok {
+		notifier.SetSizeLimit(limit)
+		debugf("output size limit %d bytes from -fs", limit)
+	}
+	if opts.SplitBar {
+		if size, ok := inputSize(ffmpegArgs); ok {
+			notifier.SetExpectedSize(size)
+			debugf("expected output size %d bytes from the inputs", size)
+		}
+	}
+	if opts.RefreshOnResize && isTerminalStream(env.stderr) {
+		defer notifyResize(notifier.Resize)()
+	}
+	if opts.PushGateway != "" {
+		pusher := newMetricsPusher(opts.PushGateway, opts.PushJob, outputPath(ffmpegArgs))
+		defer pusher.Close()
+		notifier.SetMetricsPusher(pusher)
+	}
+	if opts.GPU {
+		if g := startGPUMonitor(); g != nil {
+			defer g.Stop()
+			notifier.SetGPUMonitor(g)
+		} else {
+			debugf("nvidia-smi not found, GPU utilization not shown")
+		}
+	}
+	if opts.Checkpoint != "" {
+		cw := newCheckpointWriter(opts.Checkpoint)
+		defer cw.Close()
+		notifier.SetCheckpointWriter(cw)
+	}
+	if opts.StatsFile != "" {
+		cw, err := newCSVWriter(opts.StatsFile)
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer cw.Close()
+		notifier.SetStatsCSV(cw)
+	}
+	if opts.StatusFile != "" {
+		sw := newStatusWriter(opts.StatusFile)
+		defer sw.Close()
+		notifier.SetStatusWriter(sw)
+	}
+	if sn := newSDNotifier(); sn != nil {
+		defer sn.Close()
+		notifier.SetSDNotifier(sn)
+	}
+	if opts.JobsDir != "" && opts.Job > 0 {
+		jb, err := newJobBoard(opts.JobsDir, opts.Job, opts.JobTotal)
+		if err != nil {
+			fmt.Fprintf(env.stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer jb.Close()
+		notifier.SetJobBoard(jb)
+	}
 	
 	// Start FFmpeg process
 	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting ffmpeg: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(env.stderr, "Error starting ffmpeg: %v\n", err)
+		return 1
+	}
+	
+	// A pty's size follows fpb's terminal
+	if p, ok := cmd.(interface{ Resize(width, height int) }); ok && isTerminalStream(env.stderr) {
+		resize := func() { p.Resize(getTerminalSize(env.stderr)) }
+		resize()
+		defer notifyResize(resize)()
 	}
 	
-	// Start goroutine to process FFmpeg stderr output
-	done := make(chan error, 1)
+	// Animate the spinner, if enabled, until the progress bar appears
+	stopSpinner := notifier.StartSpinner(opts.Spinner)
+	defer stopSpinner()
+	
+	// Start goroutines to process FFmpeg stderr output (and stdout progress, if used)
+	done := make(chan error, 2)
 	go func() {
-		reader := bufio.NewReader(stderr)
-		for {
-			b, err := reader.ReadByte()
+		defer recoverPanic()
+		_, err := io.Copy(notifier, stderr)
+		done <- err
+	}()
+	if stdout != nil {
+		go func() {
+			defer recoverPanic()
+			_, err := io.Copy(notifier.ProgressWriter(), stdout)
+			done <- err
+		}()
+	}
+	streams := 1
+	if stdout != nil {
+		streams = 2
+	}
+	
+	// Handle Ctrl+C gracefully; the caller waits for the killed process
+	interrupt := func() {
+		stopSpinner()
+		notifier.StopInput()
+		if useColors {
+			colors := NewColors()
+			fmt.Fprintf(env.stderr, "%s%s%s%s\n", colors.BrightRed, colors.Bold, T(msgExiting), colors.Reset)
+		} else {
+			fmt.Fprintf(env.stderr, "%s\n", T(msgExiting))
+		}
+		cmd.Kill()
+	}
+	
+	// Wait for either interrupt signal or FFmpeg completion (all streams closed)
+	for ; streams > 0; streams-- {
+		select {
+		case <-env.signals:
+			interrupt()
+			cmd.Wait()
+			return exitInterrupted
+		case err := <-done:
 			if err != nil {
-				if err == io.EOF {
-					done <- nil
-					return
-				}
-				done <- err
-				return
+				fmt.Fprintf(env.stderr, "Error reading ffmpeg output: %v\n", err)
+				return 1
 			}
-			notifier.ProcessChar(b)
 		}
-	}()
+	}
+	
+	stopSpinner()
 	
-	// Wait for either interrupt signal or FFmpeg completion
+	// With its output closed, no prompt of FFmpeg can be shown or answered anymore.
+	// Close its stdin so a late read gets EOF instead of blocking forever.
+	notifier.StopInput()
+	stdin.Close()
+	
+	// Wait for FFmpeg to complete, killing it if it doesn't exit soon after
+	// closing its output, and handle exit code
+	waited := make(chan error, 1)
+	go func() {
+		waited <- cmd.Wait()
+	}()
 	select {
-	case <-sigChan:
-		// Handle Ctrl+C gracefully
+	case err = <-waited:
+	case <-env.signals:
+		interrupt()
+		<-waited
+		return exitInterrupted
+	case <-time.After(exitTimeout):
+		debugf("ffmpeg still running %s after closing its output, killing it", exitTimeout)
+		cmd.Kill()
+		<-waited
+		fmt.Fprintf(env.stderr, "Error: ffmpeg did not exit %s after closing its output\n", exitTimeout)
+		return 1
+	}
+	if notifier.DiskFull() {
+		message := T(msgDiskFull)
 		if useColors {
 			colors := NewColors()
-			fmt.Fprintf(os.Stderr, "%s%sExiting.%s\n", colors.BrightRed, colors.Bold, colors.Reset)
-		} else {
-			fmt.Fprintf(os.Stderr, "Exiting.\n")
+			message = colors.BrightRed + colors.Bold + message + colors.Reset
 		}
-		cmd.Process.Kill()
-		os.Exit(128 + int(syscall.SIGINT))
-	case err := <-done:
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading ffmpeg output: %v\n", err)
-			os.Exit(1)
+		fmt.Fprintln(env.stderr, message)
+		return exitDiskFull
+	}
+	if err != nil {
+		var exitError interface{ ExitCode() int }
+		if errors.As(err, &exitError) {
+			// FFmpeg failed - explain common mistakes, or display collected stderr content
+			notifier.Fail()
+			stderrContent := notifier.GetStderrContent()
+			if diagnosis, ok := diagnoseFailure(stderrContent); ok {
+				if useColors {
+					colors := NewColors()
+					diagnosis = colors.BrightRed + colors.Bold + diagnosis + colors.Reset
+				}
+				fmt.Fprintln(env.stderr, diagnosis)
+			} else if stderrContent != "" {
+				if useColors {
+					stderrContent = highlightErrors(stderrContent, opts.errorKeywords(), NewColors())
+				}
+				fmt.Fprint(env.stderr, stderrContent)
+			}
+			return exitError.ExitCode()
 		}
+		fmt.Fprintf(env.stderr, "Error waiting for ffmpeg: %v\n", err)
+		return 1
 	}
 	
-	// Wait for FFmpeg to complete and handle exit code
-	if err := cmd.Wait(); err != nil {
+	// FFmpeg succeeded - complete the bar (stderr content remains hidden)
+	notifier.Close()
+	if !opts.FinalSummary {
+		return 0
+	}
+	if warning, ok := notifier.DTSWarning(); ok {
+		fmt.Fprintln(env.stderr, warning)
+	}
+	if opts.Summary {
+		if summary := outputSummary(summaryTarget(ffmpegArgs), notifier.FinalSize(), notifier.Benchmark().details()...); summary != "" {
+			fmt.Fprintln(env.stderr, summary)
+		}
+	}
+	return 0
+}
+
+// runPassthrough runs FFmpeg with fpb's own stdin, stdout and stderr, without
+// parsing its output or drawing a progress bar, and returns FFmpeg's exit status.
+func runPassthrough(args []string) int {
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
-			// FFmpeg failed - display collected stderr content
-			stderrContent := notifier.GetStderrContent()
-			if stderrContent != "" {
-				fmt.Fprint(os.Stderr, stderrContent)
-			}
-			os.Exit(exitError.ExitCode())
+			return exitError.ExitCode()
 		}
-		fmt.Fprintf(os.Stderr, "Error waiting for ffmpeg: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error starting ffmpeg: %v\n", err)
+		return 1
 	}
-	// FFmpeg succeeded - exit normally (stderr content remains hidden)
-}
\ No newline at end of file
+	return 0
+}
@@ -0,0 +1,2430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// fakeEncode is the stderr of a short, successful FFmpeg encode.
+const fakeEncode = "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+	"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+	"  Stream #0:0(und): Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n" +
+	"Output #0, mp4, to 'out.mp4':\n" +
+	"frame=   50 fps= 25 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x\r" +
+	"frame=  100 fps= 25 q=-1.0 Lsize=     512KiB time=00:00:04.00 bitrate=1048.6kbits/s speed=1x\n"
+
+// feed passes s to the notifier one character at a time, as main does.
+func feed(cpn *ColoredProgressNotifier, s string) {
+	for i := 0; i < len(s); i++ {
+		cpn.ProcessChar(s[i])
+	}
+}
+
+// fakeFFmpeg puts an "ffmpeg" script first in PATH that succeeds with
+// fakeEncode on stderr, or fails when an argument contains "fail". Returns
+// the file where each run's arguments are logged, one line per run.
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	encode := filepath.Join(dir, "encode.log")
+	if err := os.WriteFile(encode, []byte(fakeEncode), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	argsLog := filepath.Join(dir, "args.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$*\" >> '" + argsLog + "'\n" +
+		"for arg; do case $arg in *fail*) echo \"$arg: No such file or directory\" >&2; exit 1;; esac; done\n" +
+		"cat '" + encode + "' >&2\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsLog
+}
+
+// captureStderr runs f with os.Stderr redirected to a file and returns what
+// was written to it.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	return capture(t, &os.Stderr, f)
+}
+
+// captureStdout is like captureStderr for os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	return capture(t, &os.Stdout, f)
+}
+
+// capture runs f with *stream redirected to a file and returns what was
+// written to it.
+func capture(t *testing.T, stream **os.File, f func()) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	saved := *stream
+	*stream = file
+	defer func() { *stream = saved }()
+
+	f()
+	out, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// exitStatus is the error a fakeProcess returns from Wait for a non-zero status.
+type exitStatus int
+
+func (e exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitStatus) ExitCode() int { return int(e) }
+
+// fakeProcess is an ffmpegProcess that writes a scripted stderr and exits with
+// a given status, recording the arguments it was run with and its stdin.
+type fakeProcess struct {
+	stderr io.Reader // What FFmpeg writes to stderr
+	stdout io.Reader // What FFmpeg writes to stdout (nil = nothing)
+	exit   int       // Exit status returned by Wait
+
+	mu     sync.Mutex
+	args   []string     // Arguments after "ffmpeg"
+	stdin  bytes.Buffer // Everything written to FFmpeg's stdin
+	killed bool
+}
+
+// newFakeProcess returns a process writing stderr and exiting with status 0.
+func newFakeProcess(stderr string) *fakeProcess {
+	return &fakeProcess{stderr: strings.NewReader(stderr)}
+}
+
+// runner returns a commandRunner that always runs p.
+func (p *fakeProcess) runner() commandRunner {
+	return func(name string, args ...string) ffmpegProcess {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.args = args
+		return p
+	}
+}
+
+func (p *fakeProcess) StderrPipe() (io.ReadCloser, error) { return io.NopCloser(p.stderr), nil }
+func (p *fakeProcess) StdoutPipe() (io.ReadCloser, error) {
+	if p.stdout == nil {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return io.NopCloser(p.stdout), nil
+}
+func (p *fakeProcess) StdinPipe() (io.WriteCloser, error) { return nopWriteCloser{fakeStdin{p}}, nil }
+func (p *fakeProcess) Start() error                       { return nil }
+
+func (p *fakeProcess) Wait() error {
+	if p.exit != 0 {
+		return exitStatus(p.exit)
+	}
+	return nil
+}
+
+func (p *fakeProcess) Kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.killed = true
+	if c, ok := p.stderr.(io.Closer); ok {
+		c.Close()
+	}
+	return nil
+}
+
+// Args returns the arguments the process was run with.
+func (p *fakeProcess) Args() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.args)
+}
+
+// Stdin returns what was written to the process's stdin so far.
+func (p *fakeProcess) Stdin() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stdin.String()
+}
+
+// fakeStdin guards the writes to a fakeProcess's stdin.
+type fakeStdin struct{ p *fakeProcess }
+
+func (w fakeStdin) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	return w.p.stdin.Write(b)
+}
+
+// runFake runs fpb with the given FFmpeg arguments against p, returning the
+// exit status and what fpb wrote to stderr.
+func runFake(t *testing.T, p *fakeProcess, opts *Options, stdin io.Reader, args ...string) (int, string) {
+	t.Helper()
+	var stderr bytes.Buffer
+	status := run(opts, args, runEnv{
+		newCommand: p.runner(),
+		stdin:      stdin,
+		stderr:     &stderr,
+	})
+	return status, stderr.String()
+}
+
+func TestGetters(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	if cpn.Duration() != 0 || cpn.FPS() != 0 || cpn.Source() != "" || cpn.Output() != "" {
+		t.Error("getters aren't zero before any output")
+	}
+
+	feed(cpn, fakeEncode)
+	if got := cpn.Duration(); got != 4*time.Second {
+		t.Errorf("Duration() = %v, want 4s", got)
+	}
+	if got := cpn.FPS(); got != 25 {
+		t.Errorf("FPS() = %d, want 25", got)
+	}
+	if got := cpn.Source(); got != "in.mp4" {
+		t.Errorf("Source() = %q, want in.mp4", got)
+	}
+	if got := cpn.Output(); got != "out.mp4" {
+		t.Errorf("Output() = %q, want out.mp4", got)
+	}
+}
+
+// TestConcurrentGetters feeds output on one goroutine while another reads the
+// parsed state; run with -race.
+func TestConcurrentGetters(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			feed(cpn, fakeEncode)
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		cpn.Duration()
+		cpn.FPS()
+		cpn.Source()
+		cpn.Output()
+		cpn.WaitingForInput()
+		cpn.GetStderrContent()
+	}
+	if cpn.Duration() != 4*time.Second || cpn.Source() != "in.mp4" {
+		t.Errorf("parsed %v from %q", cpn.Duration(), cpn.Source())
+	}
+}
+
+func TestProgressEnd(t *testing.T) {
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "\r")+1])
+	if cpn.finished {
+		t.Fatal("finished before progress=end")
+	}
+
+	// The -progress stream on stderr ends the bar before FFmpeg exits
+	feed(cpn, "frame=100\nout_time=00:00:04.000000\nprogress=end\n")
+	if !cpn.finished {
+		t.Error("not finished by progress=end")
+	}
+	if !strings.Contains(stderr.String(), "100.0%") {
+		t.Errorf("output:\n%s\nwant the bar at 100%%", stderr.String())
+	}
+	for _, key := range []string{"out_time", "progress=end"} {
+		if strings.Contains(stderr.String(), key) || strings.Contains(cpn.GetStderrContent(), key) {
+			t.Errorf("%s shown or kept as FFmpeg output", key)
+		}
+	}
+}
+
+func TestHasOutput(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, true},
+		{[]string{"-i", "in.mp4", "-f", "null", "-"}, true},
+		{[]string{"-i", "in.mp4"}, false},
+		{[]string{"-version"}, false},
+		{[]string{"-i", "in.mp4", "-hide_banner"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := hasOutput(tt.args); got != tt.want {
+			t.Errorf("hasOutput(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
+
+// countingWriter counts the writes made to it.
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return len(b), nil
+}
+
+func TestMaxBytesPerSec(t *testing.T) {
+	updates := func(maxBytes int) int {
+		var w countingWriter
+		pb := NewProgressBar("in.mp4", 1000, "frames", false, &w)
+		pb.updateDelay = 0
+		pb.SetMaxBytesPerSec(maxBytes)
+		for i := 1; i <= 100; i++ {
+			pb.Update(i)
+		}
+		return w.writes
+	}
+
+	if got := updates(0); got != 100 {
+		t.Errorf("%d writes without a cap, want 100", got)
+	}
+	// A cap of 200 bytes per second leaves room for a line or two
+	if got := updates(200); got > 3 {
+		t.Errorf("%d writes with a 200 bytes/s cap, want at most 3", got)
+	}
+}
+
+func TestOutTimeUs(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+
+	// Steps smaller than a second move the bar
+	for _, step := range []struct {
+		us   string
+		want float64
+	}{
+		{"1500000", 0.375},
+		{"1750000", 0.4375},
+		{"1760000", 0.44},
+	} {
+		feed(cpn, "out_time_us="+step.us+"\n")
+		if cpn.pbar == nil || !cpn.pbar.hasFraction || cpn.pbar.fraction != step.want {
+			t.Fatalf("out_time_us=%s: bar %+v, want fraction %v", step.us, cpn.pbar, step.want)
+		}
+	}
+
+	// The coarse time= of the stats line no longer moves it
+	feed(cpn, "frame=   25 fps= 25 q=28.0 size=     128KiB time=00:00:01.00 bitrate=1048.6kbits/s speed=1x\r")
+	if cpn.pbar.fraction != 0.44 {
+		t.Errorf("fraction %v after a stats line, want 0.44", cpn.pbar.fraction)
+	}
+}
+
+func TestFrameCountTag(t *testing.T) {
+	header := "Input #0, matroska,webm, from 'in.mkv':\n" +
+		"  Duration: N/A, start: 0.000000, bitrate: N/A\n" +
+		"  Stream #0:0: Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n" +
+		"    Metadata:\n" +
+		"      NUMBER_OF_FRAMES-eng: 200\n" +
+		"  Stream #0:1: Audio: aac, 48000 Hz, stereo\n" +
+		"    Metadata:\n" +
+		"      NUMBER_OF_FRAMES-eng: 9999\n" +
+		"Output #0, mp4, to 'out.mp4':\n"
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, header)
+	feed(cpn, "frame=  150 fps= 25 q=28.0 size=     256KiB time=00:00:06.00 bitrate=349.5kbits/s speed=1x\n")
+	cpn.Close()
+	if !strings.Contains(stderr.String(), "in.mkv: 75.0% 150/200 frames") {
+		t.Errorf("output:\n%s\nwant the video stream's frame count as the total", stderr.String())
+	}
+}
+
+func TestNonTerminalUsesLines(t *testing.T) {
+	var stderr bytes.Buffer
+	if got := resolveMode(ModeAuto, &stderr); got != ModeLine {
+		t.Errorf("resolveMode(auto) for a buffer = %s, want %s", got, ModeLine)
+	}
+	if got := resolveMode(ModeJSON, &stderr); got != ModeJSON {
+		t.Errorf("resolveMode(json) = %s", got)
+	}
+
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if strings.ContainsAny(stderr.String(), "\r\033") {
+		t.Errorf("output %q has carriage returns or escape sequences", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "in.mp4: 100.0% 100/100 frames") {
+		t.Errorf("output %q, want a final status line", stderr.String())
+	}
+}
+
+func TestWriteChunks(t *testing.T) {
+	log, err := os.ReadFile("testdata/verbose.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var byChar, byChunk bytes.Buffer
+	want := NewColoredProgressNotifier(&byChar, false, nopWriteCloser{io.Discard}, nil)
+	feed(want, string(log))
+	want.Close()
+	got := NewColoredProgressNotifier(&byChunk, false, nopWriteCloser{io.Discard}, nil)
+	for chunk := log; len(chunk) > 0; {
+		n := min(len(chunk), 4096)
+		if written, err := got.Write(chunk[:n]); written != n || err != nil {
+			t.Fatalf("Write = %d, %v", written, err)
+		}
+		chunk = chunk[n:]
+	}
+	got.Close()
+
+	if got.GetStderrContent() != want.GetStderrContent() {
+		t.Error("Write kept different FFmpeg output than ProcessChar")
+	}
+	if got.Duration() != want.Duration() || got.FPS() != want.FPS() || got.Source() != want.Source() {
+		t.Errorf("Write parsed %v %d %q, ProcessChar %v %d %q",
+			got.Duration(), got.FPS(), got.Source(), want.Duration(), want.FPS(), want.Source())
+	}
+	if !strings.Contains(byChunk.String(), "concert.mp4: 100.0%") {
+		t.Errorf("output:\n%s\nwant the final status line", byChunk.String())
+	}
+}
+
+// BenchmarkWrite measures parsing a verbose FFmpeg log written in 4 KiB
+// chunks, as the stderr reader does.
+func BenchmarkWrite(b *testing.B) {
+	log, err := os.ReadFile("testdata/verbose.log")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+		for chunk := log; len(chunk) > 0; {
+			n := min(len(chunk), 4096)
+			cpn.Write(chunk[:n])
+			chunk = chunk[n:]
+		}
+		cpn.Close()
+	}
+}
+
+// BenchmarkProcessChar measures the same log fed one byte at a time.
+func BenchmarkProcessChar(b *testing.B) {
+	log, err := os.ReadFile("testdata/verbose.log")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(log)))
+	for i := 0; i < b.N; i++ {
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+		for _, c := range log {
+			cpn.ProcessChar(c)
+		}
+		cpn.Close()
+	}
+}
+
+func TestHeaderFieldsFoundOnce(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+
+	// A second input's header doesn't replace the first one's fields
+	feed(cpn, "Input #1, wav, from 'music.wav':\n"+
+		"  Duration: 00:00:00.00, bitrate: 1411 kb/s\n"+
+		"  Stream #1:0: Video: png, rgb24, 640x480, 50 fps\n"+
+		"Output #1, mp4, to 'second.mp4':\n")
+	if cpn.Duration() != 4*time.Second || cpn.Source() != "in.mp4" || cpn.Output() != "out.mp4" || cpn.FPS() != 25 {
+		t.Errorf("parsed %v %q %q %d, want the first header's 4s in.mp4 out.mp4 25",
+			cpn.Duration(), cpn.Source(), cpn.Output(), cpn.FPS())
+	}
+}
+
+func TestDescriptionFallback(t *testing.T) {
+	noInput := fakeEncode[strings.Index(fakeEncode, "\n")+1:]
+	noHeader := fakeEncode[strings.Index(fakeEncode, "frame="):]
+	tests := []struct {
+		name   string
+		stderr string
+		want   string
+	}{
+		{"full header", fakeEncode, "in.mp4: "},
+		{"no input line", noInput, "out.mp4: "},
+		{"no header", "  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n" + noHeader, "Processing: "},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+		feed(cpn, tt.stderr)
+		cpn.Close()
+		if !strings.HasPrefix(stderr.String(), tt.want) {
+			t.Errorf("%s: output %q, want it labeled %q", tt.name, stderr.String(), tt.want)
+		}
+	}
+}
+
+func TestStableBarColumn(t *testing.T) {
+	pb := NewProgressBar("in.mp4", 1000, "frames", false, io.Discard)
+	steps := []struct {
+		current int
+		elapsed time.Duration
+	}{
+		{1, 10 * time.Second},        // 0fps, long ETA
+		{500, time.Second},           // 500fps
+		{990, 100 * time.Second},     // 10fps
+		{999, 10 * time.Millisecond}, // Very fast, short ETA
+	}
+
+	// Fields only grow, so once each has been at its widest the bar stays put
+	var widths, barEnds []int
+	for pass := 0; pass < 2; pass++ {
+		for _, step := range steps {
+			pb.current = step.current
+			pb.startTime = time.Now().Add(-step.elapsed)
+			line := strings.TrimPrefix(pb.renderBar(), "\r\033[K")
+			if pass == 1 {
+				widths = append(widths, utf8.RuneCountInString(line))
+				barEnds = append(barEnds, utf8.RuneCountInString(line[:strings.Index(line, "%")]))
+			}
+		}
+	}
+	for i := range widths {
+		if widths[i] != widths[0] || barEnds[i] != barEnds[0] {
+			t.Fatalf("line widths %v, bar ends %v; want them constant", widths, barEnds)
+		}
+	}
+}
+
+func TestIsTerminalDevNull(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	if isTerminal(devNull) {
+		t.Errorf("%s counted as a terminal", os.DevNull)
+	}
+}
+
+func TestFindOverwriteFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, ""},
+		{[]string{"-y", "-i", "in.mp4", "out.mp4"}, "-y"},
+		{[]string{"-i", "in.mp4", "-n", "out.mp4"}, "-n"},
+		{[]string{"-n", "-i", "in.mp4", "-y", "out.mp4"}, "-y"},
+	}
+	for _, tt := range tests {
+		if got := findOverwriteFlag(tt.args); got != tt.want {
+			t.Errorf("findOverwriteFlag(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPromptDetectionDisabled(t *testing.T) {
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetPromptDetection(false)
+	feed(cpn, "File 'out.mp4' already exists. Overwrite? [y/N] ")
+	if cpn.WaitingForInput() || stderr.Len() != 0 {
+		t.Errorf("prompt taken with detection disabled: waiting %t, output %q", cpn.WaitingForInput(), stderr.String())
+	}
+}
+
+func TestInlinePercent(t *testing.T) {
+	pb := NewProgressBar("in.mp4", 1000, "frames", false, io.Discard)
+	pb.SetInlinePercent(true)
+	pb.current = 420
+	line := pb.renderBar()
+	bar := line[strings.Index(line, "in.mp4 ")+len("in.mp4 ") : strings.Index(line, " 420/1000")]
+	if !regexp.MustCompile(`[━╸]42\.0%[━╸]`).MatchString(bar) {
+		t.Errorf("bar %q, want the percentage inside it", bar)
+	}
+	if strings.Count(line, "42.0%") != 1 {
+		t.Errorf("line %q, want the percentage only once", line)
+	}
+
+	// A bar squeezed by a long name keeps the percentage on the side
+	pb = NewProgressBar(strings.Repeat("long name ", 5), 1000, "frames", false, io.Discard)
+	pb.SetInlinePercent(true)
+	pb.current = 420
+	if line := pb.renderBar(); !strings.Contains(line, " 42.0% • ") {
+		t.Errorf("narrow bar line %q, want the percentage on the side", line)
+	}
+}
+
+func TestFinishNewline(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		var out bytes.Buffer
+		pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+		pb.SetFinishNewline(enabled)
+		pb.Finish()
+		if got := strings.HasSuffix(out.String(), "\n"); got != enabled {
+			t.Errorf("SetFinishNewline(%t): output %q", enabled, out.String())
+		}
+		if !strings.Contains(out.String(), "100.0%") {
+			t.Errorf("output %q, want the final bar", out.String())
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, "out.mp4"},
+		{[]string{"-i", "in.mp4", "file:/tmp/out.mp4"}, "/tmp/out.mp4"},
+		{[]string{"-i", "in.mp4", `C:\out.mp4`}, `C:\out.mp4`},
+		{[]string{"-i", "in.mp4", "pipe:1"}, ""},
+		{[]string{"-i", "in.mp4", "tcp://host:1234"}, ""},
+		{[]string{"-i", "in.mp4", "srt:host"}, ""},
+		{[]string{"-i", "in.mp4", "-f", "null", "-"}, ""},
+		{[]string{"-i", "in.mp4", os.DevNull}, ""},
+		{[]string{"-i", "in.mp4", "-f", "tee", "[f=mp4]/tmp/a.mp4|[f=flv]rtmp://h/app"}, ""},
+		{[]string{"-i", "in.mp4", "-f", "tee", "/tmp/a.mp4|rtmp://h/app"}, ""},
+		{[]string{"-i", "in.mp4", "[f=mp4]/tmp/a.mp4"}, ""},
+		{[]string{"-i", "in.mp4"}, ""},
+	}
+	for _, tt := range tests {
+		if got := outputPath(tt.args); got != tt.want {
+			t.Errorf("outputPath(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOutputWritable(t *testing.T) {
+	dir := t.TempDir()
+	notDir := filepath.Join(dir, "file")
+	if err := os.WriteFile(notDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkOutputWritable(filepath.Join(dir, "out.mp4"), false); err != nil {
+		t.Errorf("writable directory: %v", err)
+	}
+	if err := checkOutputWritable(filepath.Join(dir, "missing", "out.mp4"), false); err == nil {
+		t.Error("missing directory accepted")
+	}
+	if err := checkOutputWritable(filepath.Join(notDir, "out.mp4"), false); err == nil {
+		t.Error("file as directory accepted")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("check left files behind: %v", entries)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't apply to root")
+	}
+	readOnlyDir := filepath.Join(dir, "ro")
+	if err := os.Mkdir(readOnlyDir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOutputWritable(filepath.Join(readOnlyDir, "out.mp4"), false); err == nil {
+		t.Error("read-only directory accepted")
+	}
+	readOnly := filepath.Join(dir, "ro.mp4")
+	if err := os.WriteFile(readOnly, nil, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOutputWritable(readOnly, false); err == nil {
+		t.Error("read-only existing output accepted")
+	}
+	if err := checkOutputWritable(readOnly, true); err != nil {
+		t.Errorf("read-only existing output with -n: %v", err)
+	}
+}
+
+func TestFPSColor(t *testing.T) {
+	colors := NewColors()
+	tests := []struct {
+		target  int
+		current int // Frames done after 10 seconds
+		want    string
+	}{
+		{25, 500, colors.Green}, // 50fps, twice real time
+		{25, 251, colors.Green}, // Just over real time
+		{25, 100, colors.Red},   // 10fps, slower than real time
+		{0, 500, colors.Red},    // Unknown source rate
+	}
+	for _, tt := range tests {
+		pb := NewProgressBar("in.mp4", 1000, "frames", true, io.Discard)
+		pb.SetTargetFPS(tt.target)
+		pb.current = tt.current
+		pb.startTime = time.Now().Add(-10 * time.Second)
+		fps := fmt.Sprintf("%.0ffps", float64(tt.current)/10)
+		if line := pb.renderBar(); !strings.Contains(line, tt.want+fps+colors.Reset) {
+			t.Errorf("target %d, %s: bar %q, want the fps in %q", tt.target, fps, line, tt.want)
+		}
+	}
+}
+
+func TestSpeedColors(t *testing.T) {
+	colors := NewColors()
+	tests := []struct {
+		speed    float64
+		hasSpeed bool
+		want     string
+	}{
+		{2.5, true, colors.Green},
+		{1, true, colors.Green},
+		{0.5, true, colors.Yellow},
+		{0.49, true, colors.Red},
+		{0, false, colors.Green}, // speed=N/A
+	}
+	for _, tt := range tests {
+		pb := NewProgressBar("in.mp4", 100, "frames", true, io.Discard)
+		pb.SetSpeedColors(true)
+		pb.SetSpeed(tt.speed, tt.hasSpeed)
+		if got := pb.fillColor(); got != tt.want {
+			t.Errorf("speed %.2f (known %t): fill %q, want %q", tt.speed, tt.hasSpeed, got, tt.want)
+		}
+		if bar := pb.buildRichBar(50, 100, ""); !strings.HasPrefix(bar, tt.want+"━") {
+			t.Errorf("speed %.2f: bar starts %q, want %q", tt.speed, bar[:min(len(bar), 12)], tt.want)
+		}
+	}
+
+	// Without --fpb-speed-colors the fill stays green
+	pb := NewProgressBar("in.mp4", 100, "frames", true, io.Discard)
+	pb.SetSpeed(0.1, true)
+	if got := pb.fillColor(); got != colors.Green {
+		t.Errorf("fill %q without speed colors, want green", got)
+	}
+}
+
+func TestFinalColors(t *testing.T) {
+	colors := NewColors()
+	newBar := func(out io.Writer, final bool) *ProgressBar {
+		pb := NewProgressBar("in.mp4", 100, "frames", true, out)
+		pb.SetFinalColors(final)
+		pb.updateDelay = 0
+		pb.Update(40)
+		return pb
+	}
+
+	// Success turns the whole fill bright green
+	var out bytes.Buffer
+	pb := newBar(&out, true)
+	out.Reset()
+	pb.Finish()
+	if !strings.Contains(out.String(), colors.BrightGreen+strings.Repeat("━", 5)) {
+		t.Errorf("final bar %q, want a bright green fill", out.String())
+	}
+
+	// Failure leaves the bar at its value, in red
+	out.Reset()
+	pb = newBar(&out, true)
+	out.Reset()
+	pb.Fail()
+	if !strings.Contains(out.String(), colors.BrightRed+"━") || !strings.Contains(out.String(), " 40/100") || !strings.HasSuffix(out.String(), "\n") {
+		t.Errorf("failed bar %q, want it left at 40/100 in red", out.String())
+	}
+
+	// A bar completed before FFmpeg failed is redrawn in red
+	out.Reset()
+	pb = newBar(&out, true)
+	pb.Finish()
+	out.Reset()
+	pb.Fail()
+	if !strings.Contains(out.String(), colors.BrightRed+"━") || strings.Contains(out.String(), colors.BrightGreen) {
+		t.Errorf("redrawn bar %q, want it red", out.String())
+	}
+
+	// Without --fpb-final-colors nothing changes
+	out.Reset()
+	pb = newBar(&out, false)
+	pb.Finish()
+	out.Reset()
+	pb.Fail()
+	if out.Len() != 0 || pb.fillColor() != colors.Green {
+		t.Errorf("Fail wrote %q, fill %q; want nothing and green", out.String(), pb.fillColor())
+	}
+}
+
+func TestMaxBarWidth(t *testing.T) {
+	pb := NewProgressBar("in.mp4", 100, "frames", true, io.Discard)
+	left, right := "in.mp4 ", " 50.0% • 50/100 • 25fps • ETA 00:02"
+	if got := pb.barSpace(400, left, right); got != 400-len(left)-1-textWidth(right) {
+		t.Errorf("barSpace(400) = %d without a limit, want the whole width", got)
+	}
+	pb.SetMaxBarWidth(60)
+	if got := pb.barSpace(400, left, right); got != 60 {
+		t.Errorf("barSpace(400) = %d, want the 60 cell limit", got)
+	}
+	if got := pb.barSpace(50, left, right); got != 50-len(left)-1-textWidth(right) {
+		t.Errorf("barSpace(50) = %d, want narrower than the limit", got)
+	}
+
+	// A wide bar is written in one run per color, not one code per cell
+	colors := NewColors()
+	bar := pb.buildRichBar(200, 400, "")
+	if n := strings.Count(bar, colors.Green); n != 2 {
+		t.Errorf("400 cell bar has %d green codes, want 2 (fill and edge)", n)
+	}
+	if got := pb.stripANSI(bar); got != strings.Repeat("━", 200)+"╸"+strings.Repeat("━", 199) {
+		t.Errorf("bar cells %q", got)
+	}
+	bar = pb.buildRichBar(200, 400, "50.0%")
+	if got := pb.stripANSI(bar); utf8.RuneCountInString(got) != 400 || !strings.Contains(got, "50.0%") {
+		t.Errorf("labeled bar cells %q, want 400 with the label", got)
+	}
+}
+
+func TestSplitBar(t *testing.T) {
+	colors := NewColors()
+	pb := NewProgressBar("in.mp4", 100, "frames", true, io.Discard)
+	tests := []struct {
+		size float64
+		want string // Colored fill segments of a 20 cell bar at 50% time
+	}{
+		// Size behind time: blue up to the size, green up to the time
+		{0.25, colors.Blue + strings.Repeat("━", 5) + colors.Reset + colors.Green + strings.Repeat("━", 5) + colors.Reset + colors.Green + "╸"},
+		// Size ahead of time: green up to the time, blue up to the size
+		{0.75, colors.Green + strings.Repeat("━", 10) + colors.Reset + colors.Blue + strings.Repeat("━", 5) + colors.Reset + colors.Blue + "╸"},
+		// Sizes past the expected one fill the bar without overflowing
+		{1.5, colors.Green + strings.Repeat("━", 10) + colors.Reset + colors.Blue + strings.Repeat("━", 10) + colors.Reset},
+	}
+	for _, tt := range tests {
+		pb.SetSizeProgress(tt.size, true)
+		bar := pb.buildRichBar(10, 20, "")
+		if !strings.HasPrefix(bar, tt.want) {
+			t.Errorf("size %.2f: bar %q, want it to start %q", tt.size, bar, tt.want)
+		}
+		if got := utf8.RuneCountInString(pb.stripANSI(bar)); got != 20 {
+			t.Errorf("size %.2f: bar has %d cells, want 20", tt.size, got)
+		}
+	}
+
+	// The size comes from total_size in the -progress stream
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetExpectedSize(1000)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "\r")+1]+"total_size=250\n")
+	if cpn.pbar == nil || !cpn.pbar.hasSize || cpn.pbar.sizeFraction != 0.25 {
+		t.Errorf("bar %+v, want a quarter of the expected size", cpn.pbar)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"1000", 1000, true},
+		{"500k", 500000, true},
+		{"500K", 500000, true},
+		{"10M", 10000000, true},
+		{"10Mi", 10485760, true},
+		{"1.5G", 1500000000, true},
+		{"1MB", 8000000, true},
+		{"", 0, false},
+		{"M", 0, false},
+		{"10X", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSize(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if limit, ok := outputSizeLimit([]string{"-i", "in.mp4", "-fs", "10M", "out.mp4"}); !ok || limit != 10000000 {
+		t.Errorf("outputSizeLimit with -fs 10M = %d, %v", limit, ok)
+	}
+	for _, args := range [][]string{{"-i", "in.mp4", "out.mp4"}, {"-i", "in.mp4", "-fs", "big", "out.mp4"}, {"-fs"}} {
+		if limit, ok := outputSizeLimit(args); ok {
+			t.Errorf("outputSizeLimit(%q) = %d, want no limit", args, limit)
+		}
+	}
+}
+
+func TestSizeLimitProgress(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetSizeLimit(1000)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+
+	// The bar follows whichever of total_size against -fs and out_time_us
+	// against the duration is further along
+	for _, step := range []struct {
+		size, us string
+		want     float64
+	}{
+		{"500", "1000000", 0.5},
+		{"600", "3000000", 0.75},
+		{"900", "3200000", 0.9},
+		{"1200", "3400000", 1},
+	} {
+		feed(cpn, "total_size="+step.size+"\nout_time_us="+step.us+"\n")
+		if cpn.pbar == nil || !cpn.pbar.hasFraction || cpn.pbar.fraction != step.want {
+			t.Fatalf("total_size=%s out_time_us=%s: bar %+v, want fraction %v", step.size, step.us, cpn.pbar, step.want)
+		}
+	}
+}
+
+func TestFramesWithoutDuration(t *testing.T) {
+	const sequence = "Input #0, image2, from 'img%03d.png':\n" +
+		"  Stream #0:0: Video: png, rgb24(pc), 640x480, 25 fps, 25 tbr\n" +
+		"Output #0, mp4, to 'out.mp4':\n" +
+		"frame=   50 fps= 25 q=28.0 size=     256KiB time=N/A bitrate=N/A speed=N/A\r"
+	const last = "frame=  120 fps= 25 q=-1.0 Lsize=     512KiB time=N/A bitrate=N/A speed=N/A\n"
+
+	// Status lines show the growing frame count, without a percentage or ETA
+	opts, _, err := parseArgs([]string{"--fpb-mode=line", "-i", "img%03d.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, sequence)
+	if !regexp.MustCompile(`^img%03d\.png: 50 frames \d+fps\n$`).MatchString(stderr.String()) {
+		t.Errorf("status line %q, want the frame count and rate only", stderr.String())
+	}
+	stderr.Reset()
+	feed(cpn, last)
+	cpn.Close()
+	if !strings.Contains(stderr.String(), "img%03d.png: 100.0% 120/120 frames") {
+		t.Errorf("final line %q, want the frames reached as the total", stderr.String())
+	}
+
+	// The bar pulses instead of filling
+	pb := NewProgressBar("img%03d.png", 0, "frames", false, io.Discard)
+	pb.SetMode(ModeBar)
+	pb.current = 50
+	bar := pb.renderBar()
+	if !strings.Contains(bar, "50 frames") || strings.Contains(bar, ".0%") || strings.Contains(bar, T(msgETA)) {
+		t.Errorf("bar %q, want the frame count without a percentage or ETA", bar)
+	}
+	if pulse := pb.buildPulseBar(40); utf8.RuneCountInString(pulse) != 40 || strings.Count(pulse, "━") != 5 {
+		t.Errorf("pulse bar %q, want a 5 cell pulse in 40 cells", pulse)
+	}
+}
+
+func TestInputDurations(t *testing.T) {
+	const header = "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"  Duration: 00:02:08.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+		"  Stream #0:0(und): Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n" +
+		"Input #1, mov,mp4,m4a,3gp,3g2,mj2, from 'overlay.mov':\n" +
+		"  Duration: 00:00:30.00, start: 0.000000, bitrate: 500 kb/s\n" +
+		"  Stream #1:0(und): Video: png, rgba, 320x240, 25 fps, 25 tbr\n" +
+		"Output #0, mp4, to 'out.mp4':\n" +
+		"  Duration: 00:02:08.00, start: 0.000000, bitrate: N/A\n"
+
+	for _, enabled := range []bool{true, false} {
+		opts := NewOptions()
+		opts.InputDurations = enabled
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, opts)
+		feed(cpn, header)
+		want := "in.mp4"
+		if enabled {
+			want = "in:02:08 overlay:00:30"
+		}
+		if got := cpn.description(); got != want {
+			t.Errorf("enabled %v: description %q, want %q", enabled, got, want)
+		}
+		// The bar still follows the first input's duration
+		if got := cpn.Duration(); got != 128*time.Second {
+			t.Errorf("enabled %v: duration %v, want 2m8s", enabled, got)
+		}
+	}
+
+	// A single input keeps its filename
+	opts := NewOptions()
+	opts.InputDurations = true
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	if got := cpn.description(); got != "in.mp4" {
+		t.Errorf("single input: description %q, want in.mp4", got)
+	}
+}
+
+func TestLateDuration(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, "Input #0, mpegts, from 'in.ts':\n"+
+		"  Stream #0:0[0x100]: Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n"+
+		"frame=   25 fps= 25 q=28.0 size=     128KiB time=00:00:01.00 bitrate=1048.6kbits/s speed=1x\r")
+	if cpn.pbar == nil || !cpn.pbar.indeterminate() {
+		t.Fatalf("bar %+v, want it indeterminate before the duration", cpn.pbar)
+	}
+
+	feed(cpn, "  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n"+
+		"frame=   50 fps= 25 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x\r")
+	if cpn.pbar.indeterminate() || cpn.pbar.total != 100 || cpn.pbar.unit != "frames" {
+		t.Errorf("bar total %d %s, want 100 frames once the duration is known", cpn.pbar.total, cpn.pbar.unit)
+	}
+	if line := cpn.pbar.renderLine(); !strings.HasPrefix(line, "in.ts: 50.0% 50/100 frames") {
+		t.Errorf("status line %q, want the percentage once the duration is known", line)
+	}
+
+	// SetTotal alone makes a bar determinate
+	pb := NewProgressBar("in.ts", 0, "frames", false, io.Discard)
+	pb.current = 50
+	pb.SetTotal(200)
+	if pb.indeterminate() {
+		t.Error("bar still indeterminate after SetTotal(200)")
+	}
+	if percentage, _, _ := pb.stats(); percentage != 25 {
+		t.Errorf("percentage %v, want 25", percentage)
+	}
+}
+
+func TestFormatPercent(t *testing.T) {
+	pb := NewProgressBar("in.mp4", 100, "frames", false, io.Discard)
+	tests := []struct {
+		precision int
+		want      []string // 5%, 42.345% and 100%, padded to a stable width
+	}{
+		{0, []string{"  5%", " 42%", "100%"}},
+		{1, []string{"  5.0%", " 42.3%", "100.0%"}},
+		{2, []string{"  5.00%", " 42.34%", "100.00%"}},
+	}
+	for _, tt := range tests {
+		pb.SetPercentPrecision(tt.precision)
+		for i, percentage := range []float64{5, 42.345, 100} {
+			if got := pb.formatPercent(percentage); got != tt.want[i] {
+				t.Errorf("precision %d: formatPercent(%v) = %q, want %q", tt.precision, percentage, got, tt.want[i])
+			}
+		}
+	}
+
+	pb.SetMode(ModeLine)
+	pb.SetPercentPrecision(0)
+	pb.current = 42
+	if line := pb.renderLine(); !strings.HasPrefix(line, "in.mp4: 42% 42/100 frames") {
+		t.Errorf("status line %q, want an integer percentage", line)
+	}
+}
+
+func TestGetReading(t *testing.T) {
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"[concat @ 0x55d] Opening 'clips/b.mp4' for reading", "b.mp4", true},
+		{"[hls @ 0x55d] Opening 'https://example.com/seg12.ts' for reading", "seg12.ts", true},
+		{"[hls @ 0x55d] Opening 'https://example.com/index.m3u8' for reading", "", false},
+		{"[concat @ 0x55d] Opening 'list.ffconcat' for reading", "", false},
+		{"[segment @ 0x55d] Opening 'out003.ts' for writing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cpn.getReading(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("getReading(%q) = %q, %v, want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadingDescription(t *testing.T) {
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetDuration(30000000)
+	feed(cpn, "Input #0, concat, from 'list.txt':\n"+
+		"  Stream #0:0: Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n"+
+		"Output #0, mp4, to 'out.mp4':\n")
+	var fractions []float64
+	for i, name := range []string{"a.mp4", "b.mp4", "c.mp4"} {
+		feed(cpn, "[concat @ 0x55d] Opening '"+name+"' for reading\n")
+		feed(cpn, fmt.Sprintf("out_time_us=%d\n", (i*10+5)*1000000))
+		if cpn.pbar == nil || cpn.pbar.desc != name {
+			t.Fatalf("after opening %s: bar %+v, want it described as %s", name, cpn.pbar, name)
+		}
+		fractions = append(fractions, cpn.pbar.fraction)
+	}
+	// The percentage runs over the whole concat
+	if want := []float64{5.0 / 30, 15.0 / 30, 25.0 / 30}; !slices.Equal(fractions, want) {
+		t.Errorf("fractions %v, want %v", fractions, want)
+	}
+}
+
+func TestDecimalSeparator(t *testing.T) {
+	pb := NewProgressBar("in.mp4", 200, "frames", false, io.Discard)
+	pb.current = 47
+	pb.SetDecimalSeparator(",")
+	if got := pb.formatPercent(23.5); got != " 23,5%" {
+		t.Errorf("formatPercent(23.5) = %q, want \" 23,5%%\"", got)
+	}
+	pb.SetMode(ModeLine)
+	if line := pb.renderLine(); !strings.HasPrefix(line, "in.mp4: 23,5% 47/200 frames") {
+		t.Errorf("status line %q, want a decimal comma", line)
+	}
+
+	// The default is a decimal point
+	opts := NewOptions()
+	if got := RenderLine(ProgressState{Desc: "in.mp4", Current: 47, Total: 200, Unit: "frames"}, 80, *opts); !strings.Contains(got, " 23.5% ") {
+		t.Errorf("default rendering %q, want 23.5%%", got)
+	}
+	opts.DecimalSeparator = ","
+	if got := RenderLine(ProgressState{Desc: "in.mp4", Current: 47, Total: 200, Unit: "frames"}, 80, *opts); !strings.Contains(got, " 23,5% ") {
+		t.Errorf("comma rendering %q, want 23,5%%", got)
+	}
+}
+
+func TestUnitWaitsForFrameRate(t *testing.T) {
+	const header = "Input #0, mpegts, from 'in.ts':\n" +
+		"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n"
+	const early = "frame=    0 fps=N/A q=0.0 size=       0KiB time=00:00:00.50 bitrate=N/A speed=N/A\r"
+	const stream = "  Stream #0:0[0x100]: Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n"
+	const later = "frame=   50 fps= 25 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x\r"
+
+	// fps=N/A doesn't settle the unit: the bar waits for the frame rate
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, header+early)
+	if cpn.pbar != nil {
+		t.Fatalf("bar %+v drawn before the frame rate is known", cpn.pbar)
+	}
+	feed(cpn, stream+later)
+	if cpn.pbar == nil || cpn.pbar.unit != "frames" || cpn.pbar.total != 100 || cpn.pbar.current != 50 {
+		t.Fatalf("bar %+v, want 50/100 frames", cpn.pbar)
+	}
+
+	// Past the wait the bar counts seconds, and switches to frames once the rate shows up
+	cpn = NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetUnitWait(0)
+	feed(cpn, header+early)
+	if cpn.pbar == nil || cpn.pbar.unit != "seconds" || cpn.pbar.total != 4 {
+		t.Fatalf("bar %+v, want seconds after the wait", cpn.pbar)
+	}
+	feed(cpn, stream+later)
+	if cpn.pbar.unit != "frames" || cpn.pbar.total != 100 || cpn.pbar.targetFPS != 25 {
+		t.Errorf("bar %+v, want it switched to frames at 25 fps", cpn.pbar)
+	}
+
+	// A job ending while the bar waits still gets its final bar
+	var stderr bytes.Buffer
+	cpn = NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, header+early)
+	cpn.Close()
+	if !strings.Contains(stderr.String(), "in.ts: 100.0% 4/4 seconds") {
+		t.Errorf("output %q, want the final bar", stderr.String())
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+
+	// -progress on stdout, in chunks that split lines
+	w := cpn.ProgressWriter()
+	for _, chunk := range []string{"frame=50\nout_time=00:00:0", "2.000000\nprogress=cont", "inue\nout_time=00:00:04.000000\n", "progress=end\n"} {
+		if n, err := w.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if !cpn.finished || !strings.Contains(stderr.String(), "in.mp4: 100.0%") {
+		t.Errorf("finished %t, output:\n%s\nwant the bar finished at 100%%", cpn.finished, stderr.String())
+	}
+	if strings.Contains(cpn.GetStderrContent(), "out_time") {
+		t.Error("stdout progress kept as FFmpeg output")
+	}
+}
+
+func TestProgressTarget(t *testing.T) {
+	tests := []struct {
+		args   []string
+		target string
+		stdout bool
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, "", false},
+		{[]string{"-progress", "pipe:1", "-i", "in.mp4", "out.mp4"}, "pipe:1", true},
+		{[]string{"-progress", "-", "-i", "in.mp4", "out.mp4"}, "-", true},
+		{[]string{"-progress", "pipe:", "-i", "in.mp4", "out.mp4"}, "pipe:", true},
+		{[]string{"-progress", "pipe:2", "-i", "in.mp4", "out.mp4"}, "pipe:2", false},
+		{[]string{"-progress", "progress.txt", "-i", "in.mp4", "out.mp4"}, "progress.txt", false},
+	}
+	for _, tt := range tests {
+		target := findProgressTarget(tt.args)
+		if target != tt.target || isStdoutTarget(target) != tt.stdout {
+			t.Errorf("%q: target %q (stdout %t), want %q (%t)", tt.args, target, isStdoutTarget(target), tt.target, tt.stdout)
+		}
+	}
+}
+
+func TestTimeCount(t *testing.T) {
+	opts := NewOptions()
+	opts.CountUnit = UnitTime
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if !strings.Contains(stderr.String(), "in.mp4: 100.0% 00:04 / 00:04 ") {
+		t.Errorf("output:\n%s\nwant the count as media time", stderr.String())
+	}
+
+	// 1500 frames at 25fps are a minute of media
+	pb := NewProgressBar("in.mp4", 3000, "frames", false, io.Discard)
+	pb.SetCountUnit(UnitTime, 25)
+	pb.current = 1500
+	if got := pb.countText(); got != "01:00 / 02:00" {
+		t.Errorf("countText() = %q, want 01:00 / 02:00", got)
+	}
+	pb.SetCountUnit(UnitFrames, 25)
+	if got := pb.countText(); got != "1500/3000" {
+		t.Errorf("countText() = %q with frames, want 1500/3000", got)
+	}
+}
+
+func TestTimecodeCount(t *testing.T) {
+	tests := []struct {
+		fps            float64
+		current, total int
+		want           string
+	}{
+		{25, 1462, 3200, "00:00:58:12 / 00:02:08:00"},
+		{25, 90000, 90000, "01:00:00:00 / 01:00:00:00"},
+		// 29.97 fps counts 30 frames per timecode second (non-drop-frame)
+		{30000.0 / 1001, 1749, 3600, "00:00:58:09 / 00:02:00:00"},
+		{30000.0 / 1001, 0, 108000, "00:00:00:00 / 01:00:00:00"},
+	}
+	for _, tt := range tests {
+		pb := NewProgressBar("in.mp4", tt.total, "frames", false, io.Discard)
+		pb.SetCountUnit(UnitTimecode, tt.fps)
+		pb.current = tt.current
+		if got := pb.countText(); got != tt.want {
+			t.Errorf("%d/%d at %.3f fps: countText() = %q, want %q", tt.current, tt.total, tt.fps, got, tt.want)
+		}
+	}
+
+	// Without a frame rate the count falls back to media time
+	pb := NewProgressBar("in.mp4", 120, "seconds", false, io.Discard)
+	pb.SetCountUnit(UnitTimecode, 1)
+	pb.current = 58
+	if got := pb.countText(); got != "00:58 / 02:00" {
+		t.Errorf("countText() = %q in seconds, want 00:58 / 02:00", got)
+	}
+}
+
+func TestClearOnExit(t *testing.T) {
+	for _, mode := range []string{ModeBar, ModeLine} {
+		opts := NewOptions()
+		opts.Mode = mode
+		opts.ClearOnExit = true
+		var stderr bytes.Buffer
+		cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+		feed(cpn, fakeEncode)
+		cpn.Close()
+
+		out := stderr.String()
+		if mode == ModeBar && (!strings.HasSuffix(out, "\r\033[K") || strings.HasSuffix(out, "\n")) {
+			t.Errorf("bar output ends with %q, want the line erased", out[max(0, len(out)-20):])
+		}
+		// Plain status lines are a log, which is kept
+		if mode == ModeLine && !strings.Contains(out, "100.0%") {
+			t.Errorf("line output %q, want the final status line", out)
+		}
+	}
+}
+
+func TestClearANSI(t *testing.T) {
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetMode(ModeBar)
+	pb.SetClearStyle(ClearANSI)
+	pb.updateDelay = 0
+	pb.Update(10)
+	first := out.String()
+	if strings.Contains(first, "\r") || strings.Contains(first, "\033[1A") || !strings.HasSuffix(first, "\n") {
+		t.Errorf("first bar %q, want a line ending in a newline", first)
+	}
+
+	// Each later bar first moves up over the previous one and erases it
+	for _, current := range []int{20, 30} {
+		out.Reset()
+		pb.Update(current)
+		if !strings.HasPrefix(out.String(), "\033[1A\033[2K") || strings.Count(out.String(), "\033[1A") != 1 {
+			t.Errorf("bar %q, want the previous line erased once", out.String())
+		}
+	}
+
+	out.Reset()
+	pb.Finish()
+	if !strings.HasPrefix(out.String(), "\033[1A\033[2K") || strings.HasSuffix(out.String(), "\n\n") {
+		t.Errorf("final bar %q, want it to replace the last one without an extra newline", out.String())
+	}
+
+	out.Reset()
+	pb.Clear()
+	if out.String() != "\033[1A\033[2K" {
+		t.Errorf("Clear wrote %q, want the final bar erased", out.String())
+	}
+
+	// Status lines are never erased
+	out.Reset()
+	pb = NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetMode(ModeLine)
+	pb.SetClearStyle(ClearANSI)
+	pb.updateDelay = 0
+	pb.Update(10)
+	pb.Update(20)
+	if strings.Contains(out.String(), "\033[") {
+		t.Errorf("line output %q has escape sequences", out.String())
+	}
+}
+
+func TestPrintln(t *testing.T) {
+	for _, style := range []string{ClearCR, ClearANSI} {
+		var out bytes.Buffer
+		pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+		pb.SetMode(ModeBar)
+		pb.SetClearStyle(style)
+		pb.updateDelay = 0
+		pb.Update(10)
+
+		out.Reset()
+		pb.Println("frame=   10 fps= 25")
+		erase := "\r\033[K"
+		if style == ClearANSI {
+			erase = "\033[1A\033[2K"
+		}
+		// The bar is erased, the text printed, then the bar drawn again below it
+		want := erase + "frame=   10 fps= 25\n"
+		if !strings.HasPrefix(out.String(), want) || !strings.Contains(strings.TrimPrefix(out.String(), want), "10/100") {
+			t.Errorf("%s: Println wrote %q, want %q then the bar", style, out.String(), want)
+		}
+	}
+}
+
+func TestShowStats(t *testing.T) {
+	stats := []string{
+		"frame=   50 fps= 25 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x\n",
+		"frame=  100 fps= 25 q=-1.0 Lsize=     512KiB time=00:00:04.00 bitrate=1048.6kbits/s speed=1x\n",
+	}
+	for _, mode := range []string{ModeBar, ModeLine, ModeJSON} {
+		opts, _, err := parseArgs([]string{"--fpb-show-stats", "--fpb-mode=" + mode, "-i", "in.mp4"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stderr bytes.Buffer
+		cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+		feed(cpn, fakeEncode)
+		cpn.Close()
+		for _, line := range stats {
+			if got := strings.Contains(stderr.String(), line); got != (mode != ModeJSON) {
+				t.Errorf("%s: stats line %q printed: %v", mode, line, got)
+			}
+		}
+		if !strings.Contains(stderr.String(), "100/100") && !strings.Contains(stderr.String(), `"current":100`) {
+			t.Errorf("%s: output %q, want the progress as well", mode, stderr.String())
+		}
+	}
+}
+
+func TestMinDuration(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-min-duration=5", "--fpb-mode=bar", "-i", "in.mp4"})
+	if err != nil || opts.MinDuration != 5*time.Second {
+		t.Fatalf("--fpb-min-duration=5: %s, error %v", opts.MinDuration, err)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-min-duration=-1"}); err == nil {
+		t.Error("--fpb-min-duration=-1 accepted")
+	}
+
+	// fakeEncode's 4s input is shorter than 5s: no bar, just the done line
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if !regexp.MustCompile(`^in\.mp4: done in \d+\.\ds\n$`).MatchString(stderr.String()) {
+		t.Errorf("output %q, want only the done line", stderr.String())
+	}
+
+	// Longer inputs get the bar
+	opts.MinDuration = 3 * time.Second
+	stderr.Reset()
+	cpn = NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if strings.Contains(stderr.String(), "done in") || !strings.Contains(stderr.String(), "100/100") {
+		t.Errorf("output %q, want the bar", stderr.String())
+	}
+}
+
+// answerPrompt shows the notifier an overwrite prompt with the given user
+// input and returns what was forwarded to FFmpeg's stdin.
+func answerPrompt(t *testing.T, input string) string {
+	t.Helper()
+	var stdin bytes.Buffer
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{&stdin}, nil)
+	cpn.SetInput(strings.NewReader(input))
+	feed(cpn, "File 'out.mp4' already exists. Overwrite? [y/N] ")
+	for deadline := time.Now().Add(5 * time.Second); cpn.WaitingForInput(); {
+		if time.Now().After(deadline) {
+			t.Fatal("the answer wasn't forwarded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return stdin.String()
+}
+
+func TestStartDelay(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-start-delay=0.05", "--fpb-mode=bar", "-i", "in.mp4"})
+	if err != nil || opts.StartDelay != 50*time.Millisecond {
+		t.Fatalf("--fpb-start-delay=0.05: %v, error %v", opts.StartDelay, err)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-start-delay=-1"}); err == nil {
+		t.Error("--fpb-start-delay=-1 accepted")
+	}
+
+	// A job finishing within the delay draws no bar at all
+	opts.StartDelay = time.Hour
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if stderr.Len() != 0 {
+		t.Errorf("output %q, want nothing within the delay", stderr.String())
+	}
+
+	// After the delay the bar shows up with the state accumulated meanwhile
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetStartDelay(50 * time.Millisecond)
+	pb.updateDelay = 0
+	pb.Update(10)
+	if out.Len() != 0 {
+		t.Errorf("bar %q drawn within the delay", out.String())
+	}
+	pb.startTime = pb.startTime.Add(-time.Second)
+	pb.Update(20)
+	if !strings.Contains(out.String(), " 20/100") {
+		t.Errorf("bar %q after the delay, want it at 20/100", out.String())
+	}
+
+	// Status lines aren't delayed
+	out.Reset()
+	pb = NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetMode(ModeLine)
+	pb.SetStartDelay(time.Hour)
+	pb.Update(10)
+	if !strings.Contains(out.String(), "10/100 frames") {
+		t.Errorf("status line %q, want it right away", out.String())
+	}
+}
+
+func TestForwardUserInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"y\n", "y\n"},
+		{"y\r\n", "y\n"},
+		{"y", "y\n"}, // Ctrl+D after typing
+		{"", "\n"},   // Ctrl+D right away takes FFmpeg's default, declining
+		{"\n", "\n"}, // Enter takes FFmpeg's default
+		{"n\ny\n", "n\n"},
+	}
+	for _, tt := range tests {
+		if got := answerPrompt(t, tt.input); got != tt.want {
+			t.Errorf("input %q forwarded as %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPromptDetection(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"File 'out.mp4' already exists. Overwrite? [y/N] ", true},
+		{"Select a stream (0-2): ", true},
+		{"Keep the subtitles? [yes|no]: ", true},
+		{"File 'a (1-2) b.mp4' already exists. Overwrite? [y/N] ", true},
+		// Log lines that happen to end like a list of choices
+		{"[mp4 @ 0x55d8c2a0] Stream #1 (0-2) ", false},
+		{"  Stream #0:0 -> #0:0 (h264 (native) -> h264) ", false},
+		{"Output #0, mp4, to 'out [y/N] ", false},
+		{"Press [q] to stop, [?] for help ", false},
+		{"Overwrite? [y/N]", false},
+		{"Choose one of (a lot of/words) ", false},
+	}
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	for _, tt := range tests {
+		if got := cpn.promptRx.MatchString(tt.line); got != tt.want {
+			t.Errorf("%q taken as a prompt: %t, want %t", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestChoicePrompt(t *testing.T) {
+	var stderr, stdin bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{&stdin}, nil)
+	cpn.SetInput(strings.NewReader("2\n"))
+	feed(cpn, "Select a stream (0-2): ")
+	for deadline := time.Now().Add(5 * time.Second); cpn.WaitingForInput(); {
+		if time.Now().After(deadline) {
+			t.Fatal("the answer wasn't forwarded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if !strings.Contains(stderr.String(), "Select a stream (0-2): ") || stdin.String() != "2\n" {
+		t.Errorf("prompt shown as %q, answer forwarded as %q; want the prompt and \"2\\n\"", stderr.String(), stdin.String())
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	if os.Getenv("FPB_TEST_PANIC") == "1" {
+		defer recoverPanic()
+		panic("boom")
+	}
+
+	// The panic exits the process, so it happens in a child test process
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecoverPanic$")
+	cmd.Env = append(os.Environ(), "FPB_TEST_PANIC=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Fatalf("child exited with %v, want status 2; output:\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "panic: boom") || !strings.Contains(string(out), "TestRecoverPanic") {
+		t.Errorf("output:\n%s\nwant the panic and its stack trace", out)
+	}
+	// Stderr isn't a terminal here, so nothing needs restoring
+	if strings.Contains(string(out), terminalReset) {
+		t.Errorf("output %q resets a terminal", out)
+	}
+}
+
+func TestRedraw(t *testing.T) {
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetMode(ModeBar)
+	pb.Redraw()
+	if out.Len() != 0 {
+		t.Errorf("Redraw before Finish wrote %q", out.String())
+	}
+
+	pb.Update(50)
+	pb.Finish()
+	final := pb.render()
+
+	// A final line that rewrapped over three rows is cleared from its first row
+	termWidth, _ := getTerminalSize(&out)
+	pb.lastWidth = 3*termWidth - 1
+	out.Reset()
+	pb.Redraw()
+	if want := "\033[3A\r\033[J" + final + "\n"; out.String() != want {
+		t.Errorf("Redraw wrote %q, want %q", out.String(), want)
+	}
+	if pb.lastWidth != pb.displayWidth(final) {
+		t.Errorf("lastWidth = %d after Redraw, want %d", pb.lastWidth, pb.displayWidth(final))
+	}
+}
+
+func TestGetTerminalSizeFallback(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// Outputs that aren't a terminal take the size from the environment
+	t.Setenv("COLUMNS", "132")
+	t.Setenv("LINES", "50")
+	for _, out := range []io.Writer{&bytes.Buffer{}, file} {
+		if width, height := getTerminalSize(out); width != 132 || height != 50 {
+			t.Errorf("%T: size %dx%d, want 132x50 from COLUMNS and LINES", out, width, height)
+		}
+	}
+
+	// And then default to 80x24
+	t.Setenv("COLUMNS", "wide")
+	t.Setenv("LINES", "0")
+	if width, height := getTerminalSize(file); width != 80 || height != 24 {
+		t.Errorf("size %dx%d, want the 80x24 default", width, height)
+	}
+}
+
+func TestGetTerminalSizeFromOutput(t *testing.T) {
+	_, terminal := testPTY(t)
+	setTerminalSize(t, terminal, 90, 40)
+
+	// Stdout redirected to a file doesn't matter when the bar is drawn on a terminal
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = saved }()
+	t.Setenv("COLUMNS", "132")
+
+	if width, height := getTerminalSize(terminal); width != 90 || height != 40 {
+		t.Errorf("size %dx%d, want the terminal's 90x40", width, height)
+	}
+	pb := NewProgressBar("in.mp4", 100, "frames", false, terminal)
+	if width := pb.terminalWidth(); width != 90 {
+		t.Errorf("bar fitted to %d columns, want 90", width)
+	}
+}
+
+func TestHideCursor(t *testing.T) {
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetHideCursor(true)
+	pb.updateDelay = 0
+	pb.Update(10)
+	pb.Update(20)
+	if !strings.HasPrefix(out.String(), "\033[?25l") || strings.Count(out.String(), "\033[?25l") != 1 {
+		t.Errorf("output %q, want the cursor hidden once before the first bar", out.String())
+	}
+	pb.Finish()
+	pb.ShowCursor()
+	if strings.Count(out.String(), "\033[?25h") != 1 {
+		t.Errorf("output %q, want the cursor shown once by Finish", out.String())
+	}
+
+	// Ctrl+C and errors don't finish the bar, but show the cursor
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	cpn.ShowCursor()
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+	feed(cpn, "out_time_us=1000000\n")
+	cpn.pbar.SetHideCursor(true)
+	cpn.pbar.updateDelay = 0
+	feed(cpn, "out_time_us=2000000\n")
+	cpn.ShowCursor()
+	if !strings.Contains(stderr.String(), "\033[?25l") || !strings.HasSuffix(stderr.String(), "\033[?25h") {
+		t.Errorf("output %q, want the cursor hidden, then shown at the end", stderr.String())
+	}
+
+	// Bars that aren't on a terminal never hide it
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	stderr.Reset()
+	cpn = NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	if strings.Contains(stderr.String(), "\033[?25") {
+		t.Errorf("output %q hides the cursor off a terminal", stderr.String())
+	}
+}
+
+func TestIsInfoCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-version"}, true},
+		{[]string{"-hide_banner", "-encoders"}, true},
+		{[]string{"-h", "encoder=libx264"}, true},
+		{[]string{"-h"}, true},
+		{[]string{"-sources", "pulse", "-hide_banner"}, true},
+		{[]string{"-hide_banner"}, false},
+		{[]string{"-i", "in.mp4", "out.mp4"}, false},
+		{[]string{"-version", "-i", "in.mp4", "out.mp4"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isInfoCommand(tt.args); got != tt.want {
+			t.Errorf("isInfoCommand(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunInfoPassthrough(t *testing.T) {
+	argsLog := fakeFFmpeg(t)
+	var status int
+	stderr := captureStderr(t, func() { status = Run([]string{"-hide_banner", "-version"}) })
+	if status != 0 || stderr != fakeEncode {
+		t.Errorf("status %d, stderr %q; want FFmpeg's output untouched", status, stderr)
+	}
+	if args, _ := os.ReadFile(argsLog); string(args) != "-hide_banner -version\n" {
+		t.Errorf("FFmpeg ran with %q, want the arguments unchanged", args)
+	}
+
+	stderr = captureStderr(t, func() { status = Run([]string{"-h", "fail"}) })
+	if status != 1 {
+		t.Errorf("status %d, want FFmpeg's 1", status)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var status int
+	stderr := captureStderr(t, func() { status = Run(nil) })
+	if status != 1 || !strings.HasPrefix(stderr, "Usage: ") {
+		t.Errorf("no arguments: status %d, stderr %q; want the usage", status, stderr)
+	}
+
+	// Only fpb flags: nothing is left for FFmpeg
+	argsLog := fakeFFmpeg(t)
+	stderr = captureStderr(t, func() { status = Run([]string{"--fpb-mode=line"}) })
+	if status != 1 || !strings.HasPrefix(stderr, "Error: no ffmpeg arguments given after the fpb options\n\nUsage: ") {
+		t.Errorf("fpb flags only: status %d, stderr %q; want the error and the usage", status, stderr)
+	}
+	if _, err := os.Stat(argsLog); err == nil {
+		t.Error("FFmpeg ran without arguments")
+	}
+}
+
+func TestRunStrict(t *testing.T) {
+	argsLog := fakeFFmpeg(t)
+	var status int
+	stderr := captureStderr(t, func() { status = Run([]string{"--fpb-strict", "--fpb-foo", "-i", "in.mp4", "out.mp4"}) })
+	if status != 1 || !strings.HasPrefix(stderr, "Error: unknown flag --fpb-foo") {
+		t.Errorf("status %d, stderr %q; want the unknown flag error", status, stderr)
+	}
+	if _, err := os.Stat(argsLog); err == nil {
+		t.Error("FFmpeg ran despite the unknown flag")
+	}
+}
+
+func TestRunSuccess(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	p := newFakeProcess(fakeEncode)
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", out)
+	if status != 0 {
+		t.Fatalf("status %d, stderr:\n%s", status, stderr)
+	}
+	if want := []string{"-progress", "pipe:2", "-n", "-i", "in.mp4", out}; !slices.Equal(p.Args(), want) {
+		t.Errorf("FFmpeg ran with %q, want %q", p.Args(), want)
+	}
+	// Stderr isn't a terminal: plain status lines, and FFmpeg's log stays hidden
+	if !strings.Contains(stderr, "in.mp4: 100.0% 100/100 frames") || strings.Contains(stderr, "Input #0") {
+		t.Errorf("stderr %q, want only the status lines", stderr)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	log := "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+		"[libx264 @ 0x1] Error initializing output stream 0:0\n" +
+		"Conversion failed!\n"
+	p := newFakeProcess(log)
+	p.exit = 187
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4"))
+	if status != 187 {
+		t.Errorf("status %d, want FFmpeg's 187", status)
+	}
+	if !strings.HasSuffix(stderr, log) {
+		t.Errorf("stderr %q, want FFmpeg's whole log dumped", stderr)
+	}
+}
+
+func TestRunUnknownEncoder(t *testing.T) {
+	p := newFakeProcess("Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"[vost#0:0 @ 0x1] Unknown encoder 'libx254'\n" +
+		"Error selecting an encoder\n")
+	p.exit = 8
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""),
+		"-i", "in.mp4", "-c:v", "libx254", filepath.Join(t.TempDir(), "out.mp4"))
+	if status != 8 {
+		t.Errorf("status %d, want FFmpeg's 8", status)
+	}
+	want := "Unknown encoder 'libx254'; run 'ffmpeg -encoders' to list the available encoders\n"
+	if !strings.HasSuffix(stderr, want) || strings.Contains(stderr, "Input #0") {
+		t.Errorf("stderr %q, want only the explanation %q", stderr, want)
+	}
+}
+
+func TestRunDiskFull(t *testing.T) {
+	p := newFakeProcess(fakeEncode[:strings.Index(fakeEncode, "\r")+1] +
+		"[out#0/mp4 @ 0x1] Error writing trailer: No space left on device\n" +
+		"Conversion failed!\n")
+	p.exit = 1
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4"))
+	if status != exitDiskFull {
+		t.Errorf("status %d, want %d", status, exitDiskFull)
+	}
+	if p.Stdin() != "q" {
+		t.Errorf("FFmpeg's stdin got %q, want the graceful stop \"q\"", p.Stdin())
+	}
+	want := "Disk full: FFmpeg was stopped and the output is incomplete\n"
+	if !strings.HasSuffix(stderr, want) || strings.Contains(stderr, "Conversion failed!") {
+		t.Errorf("stderr %q, want only the progress and %q", stderr, want)
+	}
+}
+
+// stdinWaitProcess is a fakeProcess that, like FFmpeg stuck reading a late
+// answer, only exits once its stdin is closed.
+type stdinWaitProcess struct {
+	*fakeProcess
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (p *stdinWaitProcess) StdinPipe() (io.WriteCloser, error) { return p, nil }
+func (p *stdinWaitProcess) Write(b []byte) (int, error)        { return fakeStdin{p.fakeProcess}.Write(b) }
+
+func (p *stdinWaitProcess) Close() error {
+	p.once.Do(func() { close(p.closed) })
+	return nil
+}
+
+func (p *stdinWaitProcess) Wait() error {
+	select {
+	case <-p.closed:
+		return p.fakeProcess.Wait()
+	case <-time.After(5 * time.Second):
+		return errors.New("stdin never closed")
+	}
+}
+
+func TestRunStderrClosesEarly(t *testing.T) {
+	// FFmpeg's output ends before it exits
+	p := &stdinWaitProcess{fakeProcess: newFakeProcess(fakeEncode), closed: make(chan struct{})}
+	var stderr bytes.Buffer
+	start := time.Now()
+	status := run(NewOptions(), []string{"-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4")}, runEnv{
+		newCommand: func(name string, args ...string) ffmpegProcess { return p },
+		stdin:      strings.NewReader(""),
+		stderr:     &stderr,
+	})
+	if status != 0 {
+		t.Errorf("status %d, stderr %q; want a clean exit", status, stderr.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run took %v, want it to close FFmpeg's stdin right away", elapsed)
+	}
+	if !strings.Contains(stderr.String(), "in.mp4: 100.0% 100/100 frames") {
+		t.Errorf("stderr %q, want the final status line", stderr.String())
+	}
+}
+
+func TestRunDTSWarnings(t *testing.T) {
+	split := strings.Index(fakeEncode, "frame=")
+	warnings := strings.Repeat("[mp4 @ 0x1] Non-monotonous DTS in output stream 0:1; previous: 1024, current: 1000; changing to 1025.\n", 36) +
+		"[mp4 @ 0x1] Application provided invalid, non monotonically increasing dts to muxer in stream 1: 2048 >= 2000\n"
+	p := newFakeProcess(fakeEncode[:split] + warnings + fakeEncode[split:])
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4"))
+	if status != 0 {
+		t.Fatalf("status %d, stderr:\n%s", status, stderr)
+	}
+	if want := "⚠ 37 DTS warnings (audio and video may be out of sync)\n"; !strings.HasSuffix(stderr, want) {
+		t.Errorf("stderr %q, want it to end in %q", stderr, want)
+	}
+
+	// The warning is yellow with colors, and left out of JSON output
+	opts := NewOptions()
+	opts.Mode = ModeJSON
+	cpn := NewColoredProgressNotifier(io.Discard, true, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, warnings)
+	if warning, ok := cpn.DTSWarning(); ok {
+		t.Errorf("DTS warning %q in JSON mode", warning)
+	}
+	opts.Mode = ModeBar
+	cpn = NewColoredProgressNotifier(io.Discard, true, nopWriteCloser{io.Discard}, opts)
+	c := NewColors()
+	cpn.useColors, cpn.colors = true, c // io.Discard doesn't support colors
+	feed(cpn, warnings)
+	if warning, _ := cpn.DTSWarning(); !strings.HasPrefix(warning, c.Yellow+"⚠ 37 ") || !strings.HasSuffix(warning, c.Reset) {
+		t.Errorf("DTS warning %q, want it yellow", warning)
+	}
+
+	// No warnings, nothing to report
+	cpn = NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode)
+	if warning, ok := cpn.DTSWarning(); ok {
+		t.Errorf("DTS warning %q without any warnings", warning)
+	}
+}
+
+func TestRunFinalSummaryOff(t *testing.T) {
+	split := strings.Index(fakeEncode, "frame=")
+	warning := "[mp4 @ 0x1] Non-monotonous DTS in output stream 0:1; previous: 1024, current: 1000; changing to 1025.\n"
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	if err := os.WriteFile(out, make([]byte, 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := NewOptions()
+	opts.Summary = true
+	opts.FinalSummary = false
+	p := newFakeProcess(fakeEncode[:split] + warning + fakeEncode[split:])
+	status, stderr := runFake(t, p, opts, strings.NewReader(""), "-y", "-i", "in.mp4", out)
+	if status != 0 {
+		t.Fatalf("status %d, stderr:\n%s", status, stderr)
+	}
+	// The final bar's newline is the last thing printed
+	lines := strings.Split(strings.TrimSuffix(stderr, "\n"), "\n")
+	if last := lines[len(lines)-1]; !strings.HasSuffix(stderr, "\n") || !strings.Contains(last, "100.0%") {
+		t.Errorf("stderr %q, want nothing after the final bar", stderr)
+	}
+
+	// With the summary on, both follow the bar
+	opts.FinalSummary = true
+	p = newFakeProcess(fakeEncode[:split] + warning + fakeEncode[split:])
+	_, full := runFake(t, p, opts, strings.NewReader(""), "-y", "-i", "in.mp4", out)
+	if !strings.HasPrefix(full, stderr) || !strings.Contains(full[len(stderr):], "DTS warning") || !strings.Contains(full[len(stderr):], "Output: ") {
+		t.Errorf("summary on: stderr %q, want the warning and summary after %q", full, stderr)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use, for output
+// written by run's goroutines while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunInterrupt(t *testing.T) {
+	stderrR, stderrW := io.Pipe()
+	defer stderrW.Close()
+	p := &fakeProcess{stderr: stderrR}
+	signals := make(chan os.Signal, 1)
+	var stderr syncBuffer
+	status := make(chan int, 1)
+	go func() {
+		status <- run(NewOptions(), []string{"-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4")}, runEnv{
+			newCommand: p.runner(),
+			stdin:      strings.NewReader(""),
+			stderr:     &stderr,
+			signals:    signals,
+		})
+	}()
+
+	// Interrupt halfway through, once the progress is shown
+	fmt.Fprint(stderrW, fakeEncode[:strings.Index(fakeEncode, "\r")+1])
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(stderr.String(), "50/100"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("stderr %q, want the progress at 50%%", stderr.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	signals <- os.Interrupt
+	select {
+	case got := <-status:
+		if got != exitInterrupted {
+			t.Errorf("status %d, want %d", got, exitInterrupted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run didn't return after the interrupt")
+	}
+	p.mu.Lock()
+	killed := p.killed
+	p.mu.Unlock()
+	if !killed {
+		t.Error("FFmpeg wasn't killed")
+	}
+	if !strings.HasSuffix(stderr.String(), "Exiting.\n") {
+		t.Errorf("stderr %q, want the exit notice last", stderr.String())
+	}
+}
+
+func TestRunOverwriteFlag(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	_, terminal := testPTY(t)
+	piped := func() io.Reader { return strings.NewReader("") }
+	const prompt = "File 'out.mp4' already exists. Overwrite? [y/N] "
+
+	tests := []struct {
+		name    string
+		stdin   io.Reader
+		policy  string
+		args    []string
+		want    string // Overwrite flag FFmpeg should get ("" = none)
+		prompts bool   // Whether FFmpeg's question is taken as a prompt
+	}{
+		{"piped stdin", piped(), OverwriteAsk, nil, "-n", false},
+		{"dev null stdin", devNull, OverwriteAsk, nil, "-n", false},
+		{"terminal stdin", terminal, OverwriteAsk, nil, "", true},
+		{"user -y", piped(), OverwriteAsk, []string{"-y"}, "-y", false},
+		{"policy yes", terminal, OverwriteYes, nil, "-y", false},
+		{"policy no", terminal, OverwriteNo, nil, "-n", false},
+		{"policy yes, piped stdin", piped(), OverwriteYes, nil, "-y", false},
+		{"policy yes, user -y", piped(), OverwriteYes, []string{"-y"}, "-y", false},
+		{"policy no, user -n", terminal, OverwriteNo, []string{"-n"}, "-n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.mp4")
+			opts := NewOptions()
+			opts.Overwrite = tt.policy
+			p := newFakeProcess(prompt + "\n" + fakeEncode)
+			status, stderr := runFake(t, p, opts, tt.stdin, append(tt.args, "-i", "in.mp4", out)...)
+			if status != 0 {
+				t.Fatalf("status %d, stderr:\n%s", status, stderr)
+			}
+			args := p.Args()
+			if got := findOverwriteFlag(args); got != tt.want {
+				t.Errorf("overwrite flag %q, want %q (args %q)", got, tt.want, args)
+			}
+			n := 0
+			for _, arg := range args {
+				if arg == "-y" || arg == "-n" {
+					n++
+				}
+			}
+			if tt.want != "" && n != 1 {
+				t.Errorf("%s given %d times (args %q)", tt.want, n, args)
+			}
+			if got := strings.Contains(stderr, prompt); got != tt.prompts {
+				t.Errorf("prompt shown %t, want %t (stderr %q)", got, tt.prompts, stderr)
+			}
+		})
+	}
+}
+
+func TestRunOverwritePolicyConflict(t *testing.T) {
+	for _, tt := range []struct{ policy, flag string }{{OverwriteYes, "-n"}, {OverwriteNo, "-y"}} {
+		opts := NewOptions()
+		opts.Overwrite = tt.policy
+		p := newFakeProcess(fakeEncode)
+		status, stderr := runFake(t, p, opts, strings.NewReader(""), tt.flag, "-i", "in.mp4", "out.mp4")
+		want := "Error: --fpb-overwrite=" + tt.policy + " conflicts with FFmpeg's " + tt.flag + "\n"
+		if status != 1 || stderr != want {
+			t.Errorf("--fpb-overwrite=%s with %s: status %d, stderr %q; want %q", tt.policy, tt.flag, status, stderr, want)
+		}
+		if p.Args() != nil {
+			t.Errorf("FFmpeg ran with %q", p.Args())
+		}
+	}
+}
+
+func TestRunUnwritableOutput(t *testing.T) {
+	p := newFakeProcess(fakeEncode)
+	out := filepath.Join(t.TempDir(), "missing", "out.mp4")
+	status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", out)
+	if status != 1 || !strings.Contains(stderr, "does not exist") {
+		t.Errorf("status %d, stderr %q; want a missing directory error", status, stderr)
+	}
+	if p.Args() != nil {
+		t.Errorf("FFmpeg ran with %q", p.Args())
+	}
+
+	p = newFakeProcess(fakeEncode)
+	out = "file:" + filepath.Join(t.TempDir(), "out.mp4")
+	if status, stderr := runFake(t, p, NewOptions(), strings.NewReader(""), "-i", "in.mp4", out); status != 0 {
+		t.Errorf("file: output: status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestRunProgressInjection(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	tests := []struct {
+		args []string
+		want []string // The -progress destinations FFmpeg should get
+	}{
+		{[]string{"-i", "in.mp4", out}, []string{"pipe:2"}},
+		{[]string{"-i", "in.mp4", "-f", "null", "-"}, []string{"pipe:2"}},
+		{[]string{"-i", "in.mp4"}, nil},
+		{[]string{"-progress", "pipe:1", "-i", "in.mp4", out}, []string{"pipe:1"}},
+		{[]string{"-progress", "pipe:2", "-i", "in.mp4", out}, []string{"pipe:2"}},
+		{[]string{"-progress", "/tmp/progress.txt", "-i", "in.mp4", out}, []string{"/tmp/progress.txt"}},
+	}
+	for _, tt := range tests {
+		p := newFakeProcess(fakeEncode)
+		runFake(t, p, NewOptions(), strings.NewReader(""), tt.args...)
+		var got []string
+		args := p.Args()
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "-progress" {
+				got = append(got, args[i+1])
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: FFmpeg got -progress %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// readerFunc is an io.Reader calling the function.
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestRunUserProgressPipe(t *testing.T) {
+	// Only the -progress stream on stdout carries the progress. As with FFmpeg,
+	// it starts after the header on stderr, and ends before stderr does.
+	split := strings.Index(fakeEncode, "frame=")
+	headerRead, progressRead := make(chan struct{}), make(chan struct{})
+	p := newFakeProcess("")
+	p.stderr = io.MultiReader(strings.NewReader(fakeEncode[:split]), readerFunc(func([]byte) (int, error) {
+		close(headerRead)
+		<-progressRead
+		return 0, io.EOF
+	}))
+	progress := strings.NewReader("frame=50\nfps=25.00\nout_time_us=2000000\nspeed=1x\nprogress=continue\n" +
+		"frame=100\nfps=25.00\nout_time_us=4000000\nspeed=1x\nprogress=end\n")
+	p.stdout = readerFunc(func(b []byte) (int, error) {
+		<-headerRead
+		n, err := progress.Read(b)
+		if err == io.EOF {
+			close(progressRead)
+		}
+		return n, err
+	})
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	status, stderr := runFake(t, p, opts, strings.NewReader(""), "-progress", "pipe:1", "-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4"))
+	if status != 0 {
+		t.Fatalf("status %d, stderr:\n%s", status, stderr)
+	}
+	if !strings.Contains(stderr, "in.mp4: 100.0% 100/100 frames") {
+		t.Errorf("stderr %q, want the bar driven by the -progress lines", stderr)
+	}
+	if strings.Contains(stderr, "out_time_us") {
+		t.Errorf("stderr %q shows the -progress lines", stderr)
+	}
+	if got := strings.Count(strings.Join(p.Args(), " "), "-progress"); got != 1 {
+		t.Errorf("FFmpeg ran with %q, want only the user's -progress", p.Args())
+	}
+}
+
+func TestRunModeNonePrompt(t *testing.T) {
+	master, terminal := testPTY(t)
+	stderrR, stderrW := io.Pipe()
+	p := &fakeProcess{stderr: stderrR}
+	opts := NewOptions()
+	opts.Mode = ModeNone
+	var stderr syncBuffer
+	status := make(chan int, 1)
+	go func() {
+		status <- run(opts, []string{"-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4")}, runEnv{
+			newCommand: p.runner(),
+			stdin:      terminal,
+			stderr:     &stderr,
+		})
+	}()
+
+	const prompt = "File 'out.mp4' already exists. Overwrite? [y/N] "
+	fmt.Fprint(stderrW, fakeEncode[:strings.Index(fakeEncode, "\r")+1])
+	fmt.Fprint(stderrW, prompt)
+	fmt.Fprint(master, "y\n")
+	for deadline := time.Now().Add(5 * time.Second); p.Stdin() != "y\n"; {
+		if time.Now().After(deadline) {
+			t.Fatalf("FFmpeg got %q, want the answer forwarded", p.Stdin())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	fmt.Fprint(stderrW, fakeEncode[strings.Index(fakeEncode, "\r")+1:])
+	stderrW.Close()
+	if got := <-status; got != 0 {
+		t.Errorf("status %d, want 0", got)
+	}
+
+	// Only the prompt was shown
+	if got := stderr.String(); !strings.Contains(got, prompt) || strings.Contains(got, "%") || strings.Contains(got, "━") {
+		t.Errorf("stderr %q, want the prompt without progress", got)
+	}
+}
+
+func TestRunInterruptDuringPrompt(t *testing.T) {
+	master, terminal := testPTY(t)
+	stderrR, stderrW := io.Pipe()
+	p := &fakeProcess{stderr: stderrR}
+	signals := make(chan os.Signal, 1)
+	status := make(chan int, 1)
+	go func() {
+		status <- run(NewOptions(), []string{"-i", "in.mp4", filepath.Join(t.TempDir(), "out.mp4")}, runEnv{
+			newCommand: p.runner(),
+			stdin:      terminal,
+			stderr:     io.Discard,
+			signals:    signals,
+		})
+	}()
+
+	// The write returns once fpb has taken the prompt, and started reading the answer
+	fmt.Fprint(stderrW, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+	fmt.Fprint(stderrW, "File 'out.mp4' already exists. Overwrite? [y/N] ")
+	time.Sleep(50 * time.Millisecond)
+	signals <- os.Interrupt
+	select {
+	case got := <-status:
+		if got != exitInterrupted {
+			t.Errorf("status %d, want %d", got, exitInterrupted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run didn't return after the interrupt")
+	}
+
+	// An answer typed after the interrupt is neither forwarded nor taken by a leftover reader
+	time.Sleep(300 * time.Millisecond)
+	fmt.Fprint(master, "y\n")
+	terminal.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 8)
+	n, err := terminal.Read(buf)
+	if string(buf[:n]) != "y\n" {
+		t.Errorf("read %q (%v) after the interrupt, want the answer left in stdin", buf[:n], err)
+	}
+	if got := p.Stdin(); got != "" {
+		t.Errorf("FFmpeg got %q", got)
+	}
+}
+
+func TestTimeReset(t *testing.T) {
+	header := "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"  Duration: 00:00:10.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+		"  Stream #0:0(und): Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n" +
+		"Output #0, mp4, to 'out.mp4':\n"
+	stats := func(ts string) string {
+		return "frame=  100 fps= 25 q=28.0 size=     256KiB time=" + ts + " bitrate=1048.6kbits/s speed=1x\r"
+	}
+	tests := []struct {
+		loops int
+		times []string
+		total int
+		want  []int // The bar's frame after each time
+	}{
+		// Unknown loops: the bar holds until the time catches up
+		{0, []string{"00:00:04.00", "00:00:01.00", "00:00:03.50", "00:00:06.00"}, 250, []int{100, 100, 100, 150}},
+		// Two passes: the time of the first is added to the second
+		{1, []string{"00:00:08.00", "00:00:10.00", "00:00:02.00", "00:00:06.00"}, 500, []int{200, 250, 300, 400}},
+		// Jitter under a second isn't a restart
+		{1, []string{"00:00:04.00", "00:00:03.50", "00:00:05.00"}, 500, []int{100, 100, 125}},
+	}
+	for _, tt := range tests {
+		opts := NewOptions()
+		opts.Mode = ModeLine
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, opts)
+		cpn.SetLoops(tt.loops)
+		feed(cpn, header)
+		for i, ts := range tt.times {
+			feed(cpn, stats(ts))
+			if cpn.pbar == nil || cpn.pbar.total != tt.total || cpn.pbar.current != tt.want[i] {
+				t.Fatalf("loops %d, after %v: bar %+v, want %d/%d", tt.loops, tt.times[:i+1], cpn.pbar, tt.want[i], tt.total)
+			}
+		}
+	}
+}
+
+func TestLiveFPS(t *testing.T) {
+	var stderr bytes.Buffer
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	opts.DualFPS = true
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, strings.Replace(fakeEncode, "fps= 25", "fps=118", 1))
+	if !cpn.pbar.hasLiveFPS || cpn.pbar.liveFPS != 25 {
+		t.Errorf("live fps %v (known %v), want FFmpeg's last fps=25", cpn.pbar.liveFPS, cpn.pbar.hasLiveFPS)
+	}
+	if !strings.Contains(stderr.String(), " 118/") {
+		t.Errorf("output %q, want the live rate 118 from the first stats line", stderr.String())
+	}
+}
+
+func TestFPSSources(t *testing.T) {
+	var stderr bytes.Buffer
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, strings.Replace(fakeEncode, "fps= 25 q=-1.0", "fps=118 q=-1.0", 1))
+
+	// The stream's 25 fps sets the total, FFmpeg's fps= the shown rate
+	if cpn.pbar == nil || cpn.pbar.total != 100 || cpn.fps != 25 {
+		t.Fatalf("bar %+v, stream fps %d, want 100 frames at 25 fps", cpn.pbar, cpn.fps)
+	}
+	if !cpn.pbar.hasLiveFPS || cpn.pbar.liveFPS != 118 {
+		t.Errorf("live fps %v, want 118 from fps=", cpn.pbar.liveFPS)
+	}
+	if got := cpn.pbar.statusLine(); !strings.Contains(got, "| 118fps |") {
+		t.Errorf("status line %q, want FFmpeg's 118fps", got)
+	}
+	var rec progressJSON
+	if err := json.Unmarshal([]byte(cpn.pbar.renderJSON()), &rec); err != nil || rec.FPS != 118 {
+		t.Errorf("JSON fps %v (error %v), want 118", rec.FPS, err)
+	}
+
+	// Counting seconds, only FFmpeg's rate is shown: the average would be
+	// seconds per second
+	pb := NewProgressBar("in.mp4", 100, "seconds", false, io.Discard)
+	pb.SetLiveFPS(118, true)
+	if got := pb.fpsText(2); got != "118fps" {
+		t.Errorf("fps counting seconds %q, want FFmpeg's 118fps", got)
+	}
+	pb.SetLiveFPS(0, false)
+	if got := pb.fpsText(2); got != "" {
+		t.Errorf("fps counting seconds without fps= %q, want none", got)
+	}
+}
+
+func TestPlainStats(t *testing.T) {
+	var stderr bytes.Buffer
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	opts.Plain = true
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	// Size and bitrate come from the -progress output fpb asks for
+	feed(cpn, strings.SplitAfter(fakeEncode, "Output #0, mp4, to 'out.mp4':\n")[0]+
+		"frame=100\nfps=25.00\nbitrate=1048.6kbits/s\ntotal_size=524288\nout_time_us=4000000\nspeed=1.92x\n")
+
+	want := "frame=  100 fps= 25 size=     512kB time=00:00:04.00 bitrate=1048.6kbits/s speed=1.92x"
+	if got := cpn.plainStats(); got != want {
+		t.Errorf("plain stats:\ngot  %q\nwant %q", got, want)
+	}
+	if out := stderr.String(); !strings.Contains(out, "\r\033[Kframe=  100 fps= 25 size=     512kB time=00:00:04.00 bitrate=1048.6kbits/s") || strings.Contains(out, "━") {
+		t.Errorf("output %q, want the stats line drawn in place of the bar", out)
+	}
+
+	// Audio has no frames, and unknown values read N/A as in FFmpeg
+	cpn = NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, "Input #0, wav, from 'in.wav':\n"+
+		"  Duration: 00:00:10.00, bitrate: 1411 kb/s\n"+
+		"size=N/A time=00:00:05.00 bitrate=N/A speed=N/A\r")
+	if got, want := cpn.plainStats(), "size=N/A time=00:00:05.00 bitrate=N/A speed=N/A"; got != want {
+		t.Errorf("audio plain stats:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestLongDuration(t *testing.T) {
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, "Input #0, mpegts, from 'day.ts':\n  Duration: 123:45:06.00, start: 0.000000, bitrate: 8000 kb/s\n")
+	if !cpn.durationFound || cpn.duration != 445506 || cpn.durationUs != 445506*1000000 {
+		t.Errorf("duration %ds (%dus, found %v), want 445506s", cpn.duration, cpn.durationUs, cpn.durationFound)
+	}
+}
+
+func TestTimeMode(t *testing.T) {
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	opts.TimeMode = true
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, opts)
+	cpn.SetUnitWait(time.Hour) // Not waited for: the frame rate doesn't matter
+	feed(cpn, strings.Replace(fakeEncode, "time=00:00:02.00", "time=00:00:01.00", 1))
+
+	// 25 fps is detected, yet the totals are the 4 seconds of the input
+	if cpn.fps != 25 {
+		t.Errorf("frame rate %d, want 25 detected", cpn.fps)
+	}
+	if cpn.pbar == nil || cpn.pbar.unit != "seconds" || cpn.pbar.total != 4 || cpn.pbar.current != 4 {
+		t.Fatalf("bar %+v, want 4/4 seconds", cpn.pbar)
+	}
+	if got := cpn.pbar.renderLine(); !strings.HasPrefix(got, "in.mp4: 100.0% 4/4 seconds 25fps ") {
+		t.Errorf("line %q, want seconds and FFmpeg's fps", got)
+	}
+}
+
+func TestRefreshIdle(t *testing.T) {
+	var stderr syncBuffer
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, strings.SplitAfter(fakeEncode, "speed=1x\r")[0])
+	if cpn.pbar == nil {
+		t.Fatal("no bar after the first stats line")
+	}
+
+	// 50 of 100 frames after 10 seconds, then FFmpeg goes quiet for 10 more:
+	// the ETA grows although no data came in
+	cpn.mu.Lock()
+	cpn.pbar.startTime = time.Now().Add(-10 * time.Second)
+	cpn.pbar.lastUpdate = time.Now().Add(-time.Second)
+	cpn.mu.Unlock()
+	cpn.RefreshIdle(time.Second)
+	if out := stderr.String(); !strings.HasSuffix(out, "ETA 00:10") {
+		t.Fatalf("output %q, want the bar redrawn with a 10 second ETA", out)
+	}
+	cpn.mu.Lock()
+	cpn.pbar.startTime = cpn.pbar.startTime.Add(-10 * time.Second)
+	cpn.pbar.lastUpdate = time.Now().Add(-time.Second)
+	cpn.mu.Unlock()
+
+	// The timer redraws the bar while FFmpeg is silent
+	loop := startPeriodic(10*time.Millisecond, func() { cpn.RefreshIdle(10 * time.Millisecond) })
+	for deadline := time.Now().Add(5 * time.Second); !strings.HasSuffix(stderr.String(), "ETA 00:20"); {
+		if time.Now().After(deadline) {
+			loop.Stop()
+			t.Fatalf("output %q, want the bar redrawn with a 20 second ETA", stderr.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	loop.Stop()
+
+	// Not after the bar is finished or the output has ended
+	feed(cpn, strings.SplitAfter(fakeEncode, "speed=1x\r")[1])
+	cpn.StopInput()
+	n := len(stderr.String())
+	cpn.RefreshIdle(0)
+	if out := stderr.String(); len(out) != n {
+		t.Errorf("bar redrawn after the end: %q", out[n:])
+	}
+}
+
+// forceTerminal makes every stream count as a terminal, or none of them,
+// until the end of the test.
+func forceTerminal(t *testing.T, terminal bool) {
+	t.Helper()
+	saved := isTerminalStream
+	t.Cleanup(func() { isTerminalStream = saved })
+	isTerminalStream = func(any) bool { return terminal }
+}
+
+func TestColorDecisions(t *testing.T) {
+	defer func(os string) { colorOS = os }(colorOS)
+	tests := []struct {
+		os       string
+		terminal bool
+		colors   bool
+		mode     string
+	}{
+		{"linux", true, true, ModeBar},
+		{"darwin", true, true, ModeBar},
+		{"linux", false, false, ModeLine},
+		{"windows", true, false, ModeBar},
+		{"windows", false, false, ModeLine},
+	}
+	for _, tt := range tests {
+		forceTerminal(t, tt.terminal)
+		colorOS = tt.os
+		var buf bytes.Buffer
+		if got := supportsColor(&buf); got != tt.colors {
+			t.Errorf("%s, terminal %t: colors %t, want %t", tt.os, tt.terminal, got, tt.colors)
+		}
+		if got := resolveMode(ModeAuto, &buf); got != tt.mode {
+			t.Errorf("%s, terminal %t: mode %s, want %s", tt.os, tt.terminal, got, tt.mode)
+		}
+	}
+}
+
+func TestRunForcedTerminal(t *testing.T) {
+	defer func(os string) { colorOS = os }(colorOS)
+	colorOS = "linux"
+	c := NewColors()
+	out := filepath.Join(t.TempDir(), "out.mp4")
+
+	// On a terminal: the colored bar, drawn in place with the cursor hidden
+	forceTerminal(t, true)
+	_, stderr := runFake(t, newFakeProcess(fakeEncode), NewOptions(), strings.NewReader(""), "-y", "-i", "in.mp4", out)
+	if !strings.Contains(stderr, "\r\033[K") || !strings.Contains(stderr, c.Green+"━") || !strings.Contains(stderr, "\033[?25l") {
+		t.Errorf("stderr %q, want the colored bar", stderr)
+	}
+
+	// Elsewhere: plain lines
+	forceTerminal(t, false)
+	_, stderr = runFake(t, newFakeProcess(fakeEncode), NewOptions(), strings.NewReader(""), "-y", "-i", "in.mp4", out)
+	if strings.Contains(stderr, "\033") || !strings.Contains(stderr, "in.mp4: 100.0% 100/100 frames") {
+		t.Errorf("stderr %q, want plain lines", stderr)
+	}
+}
@@ -3,6 +3,6 @@ module fpb
 go 1.23.0
 
 require (
-	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/term v0.32.0 // indirect
+	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
 )
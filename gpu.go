@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// gpuPollInterval is how often nvidia-smi is asked for the GPU utilization.
+const gpuPollInterval = 2 * time.Second
+
+// gpuMonitor polls nvidia-smi in the background for the GPU utilization shown
+// with --fpb-gpu, so hardware encodes (NVENC) can be checked for GPU bottlenecks.
+// Polling is best effort: failures just leave the utilization unknown.
+type gpuMonitor struct {
+	util atomic.Int64  // Last utilization in percent, or -1 while unknown
+	done chan struct{} // Closed by Stop to end polling
+}
+
+// startGPUMonitor starts polling nvidia-smi. Returns nil if it isn't installed.
+func startGPUMonitor() *gpuMonitor {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return nil
+	}
+	g := &gpuMonitor{done: make(chan struct{})}
+	g.util.Store(-1)
+	go func() {
+		defer recoverPanic()
+		ticker := time.NewTicker(gpuPollInterval)
+		defer ticker.Stop()
+		for {
+			g.poll()
+			select {
+			case <-ticker.C:
+			case <-g.done:
+				return
+			}
+		}
+	}()
+	return g
+}
+
+// Stop ends polling. It doesn't wait for a running nvidia-smi to finish.
+func (g *gpuMonitor) Stop() {
+	close(g.done)
+}
+
+// Utilization returns the last polled GPU utilization in percent,
+// and false while it is unknown.
+func (g *gpuMonitor) Utilization() (int, bool) {
+	util := g.util.Load()
+	return int(util), util >= 0
+}
+
+// poll runs nvidia-smi once and records the utilization it reports.
+func (g *gpuMonitor) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), gpuPollInterval)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=utilization.gpu", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		g.util.Store(-1)
+		return
+	}
+	if util, ok := parseGPUUtilization(string(out)); ok {
+		g.util.Store(int64(util))
+	} else {
+		g.util.Store(-1)
+	}
+}
+
+// parseGPUUtilization parses nvidia-smi's utilization query output, one
+// percentage per GPU (e.g. "87\n3\n"), returning the busiest GPU's value.
+func parseGPUUtilization(out string) (int, bool) {
+	busiest, found := 0, false
+	for _, line := range strings.Split(out, "\n") {
+		util, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "%")))
+		if err != nil {
+			continue
+		}
+		if !found || util > busiest {
+			busiest, found = util, true
+		}
+	}
+	return busiest, found
+}
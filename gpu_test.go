@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGPUUtilization(t *testing.T) {
+	tests := []struct {
+		out  string
+		util int
+		ok   bool
+	}{
+		{"87\n", 87, true},
+		{"3\n87\n12\n", 87, true}, // Busiest of several GPUs
+		{"45 %\n", 45, true},
+		{"[N/A]\n", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if util, ok := parseGPUUtilization(tt.out); util != tt.util || ok != tt.ok {
+			t.Errorf("parseGPUUtilization(%q) = %d, %t; want %d, %t", tt.out, util, ok, tt.util, tt.ok)
+		}
+	}
+}
+
+func TestGPUSegment(t *testing.T) {
+	util, known := 87, false
+	stub := func() (int, bool) { return util, known }
+
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetMode(ModeLine)
+	pb.SetGPU(stub)
+	pb.current = 50
+	if line := pb.renderLine(); strings.Contains(line, "GPU") {
+		t.Errorf("line %q shows the GPU before its utilization is known", line)
+	}
+	known = true
+	if line := pb.renderLine(); !regexp.MustCompile(`\d+fps GPU 87% `).MatchString(line) {
+		t.Errorf("line %q, want the GPU utilization after the fps", line)
+	}
+	if info := pb.rightInfo("50.0%", "50/100", "25fps", "00:02", 25, false); !strings.Contains(info, "25fps • GPU  87% • ") {
+		t.Errorf("bar statistics %q, want the GPU utilization after the fps", info)
+	}
+}
+
+func TestGPUMonitor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake nvidia-smi is a shell script")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	if g := startGPUMonitor(); g != nil {
+		g.Stop()
+		t.Fatal("monitor started without nvidia-smi")
+	}
+
+	script := "#!/bin/sh\necho 3\necho 64\n"
+	if err := os.WriteFile(filepath.Join(dir, "nvidia-smi"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	g := startGPUMonitor()
+	if g == nil {
+		t.Fatal("monitor didn't start with nvidia-smi in PATH")
+	}
+	defer g.Stop()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if util, ok := g.Utilization(); ok {
+			if util != 64 {
+				t.Errorf("Utilization() = %d, want 64", util)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the utilization wasn't polled")
+		}
+	}
+
+	// The notifier hands the monitor to its bar
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetGPUMonitor(g)
+	feed(cpn, fakeEncode)
+	if cpn.pbar == nil || cpn.pbar.gpu == nil {
+		t.Error("the bar has no GPU source")
+	}
+}
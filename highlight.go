@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultErrorKeywords select the lines of FFmpeg's output that are highlighted
+// when it fails, matched case-insensitively. --fpb-error-keywords adds to them.
+var defaultErrorKeywords = []string{
+	"error",
+	"failed",
+	"invalid",
+	"unable",
+	"could not",
+	"cannot",
+	"not found",
+	"no such file",
+	"permission denied",
+	"unrecognized option",
+}
+
+// highlightErrors returns FFmpeg's output with every line containing one of the
+// keywords (case-insensitively) shown in bold red, so the cause of a failure
+// stands out from the surrounding stream information.
+func highlightErrors(output string, keywords []string, colors *Colors) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(output, "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		if text == "" || !containsKeyword(text, keywords) {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(colors.BrightRed + colors.Bold + text + colors.Reset)
+		b.WriteString(line[len(text):])
+	}
+	return b.String()
+}
+
+// containsKeyword reports whether line contains any of the keywords, ignoring case.
+func containsKeyword(line string, keywords []string) bool {
+	lower := strings.ToLower(line)
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownCodecRx matches FFmpeg's error for a misspelled codec name,
+// e.g. "Unknown encoder 'libx254'".
+var unknownCodecRx = regexp.MustCompile(`Unknown (encoder|decoder) '([^']*)'`)
+
+// diagnoseFailure recognizes common mistakes in the output of a failed FFmpeg run
+// and returns a concise explanation to show instead of the full output.
+func diagnoseFailure(output string) (string, bool) {
+	if m := unknownCodecRx.FindStringSubmatch(output); m != nil {
+		if m[1] == "encoder" {
+			return fmt.Sprintf(T(msgUnknownEncoder), m[2]), true
+		}
+		return fmt.Sprintf(T(msgUnknownDecoder), m[2]), true
+	}
+	return "", false
+}
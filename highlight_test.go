@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestHighlightErrors(t *testing.T) {
+	c := NewColors()
+	output := "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"[libx264 @ 0x1] Error initializing output stream\r\n" +
+		"[aac @ 0x2] Too many bits per frame requested\n" +
+		"Conversion failed!"
+
+	opts, _, err := parseArgs([]string{"--fpb-error-keywords=too many bits, ", "-i", "in.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		c.BrightRed + c.Bold + "[libx264 @ 0x1] Error initializing output stream" + c.Reset + "\r\n" +
+		c.BrightRed + c.Bold + "[aac @ 0x2] Too many bits per frame requested" + c.Reset + "\n" +
+		c.BrightRed + c.Bold + "Conversion failed!" + c.Reset
+	if got := highlightErrors(output, opts.errorKeywords(), c); got != want {
+		t.Errorf("with a custom keyword:\ngot  %q\nwant %q", got, want)
+	}
+
+	// Replacing the defaults highlights only the custom keyword
+	opts.ReplaceErrorKeywords = true
+	want = "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+		"[libx264 @ 0x1] Error initializing output stream\r\n" +
+		c.BrightRed + c.Bold + "[aac @ 0x2] Too many bits per frame requested" + c.Reset + "\n" +
+		"Conversion failed!"
+	if got := highlightErrors(output, opts.errorKeywords(), c); got != want {
+		t.Errorf("with the defaults replaced:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestDiagnoseFailure(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+		ok     bool
+	}{
+		{"[vost#0:0 @ 0x1] Unknown encoder 'libx254'\nError selecting an encoder\n",
+			"Unknown encoder 'libx254'; run 'ffmpeg -encoders' to list the available encoders", true},
+		{"Unknown decoder 'h265x'\n",
+			"Unknown decoder 'h265x'; run 'ffmpeg -decoders' to list the available decoders", true},
+		{"[libx264 @ 0x1] Error initializing output stream 0:0\nConversion failed!\n", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := diagnoseFailure(tt.output)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("diagnoseFailure(%q) = %q, %v, want %q, %v", tt.output, got, ok, tt.want, tt.ok)
+		}
+	}
+}
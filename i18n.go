@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Keys of the translatable user interface strings.
+const (
+	msgProcessing     = "processing"      // Description shown when no filename is known
+	msgETA            = "eta"             // Label of the estimated time remaining
+	msgExiting        = "exiting"         // Printed when interrupted with Ctrl+C
+	msgBatchComplete  = "batch_complete"  // Batch tally; takes succeeded and failed counts
+	msgBatchFailed    = "batch_failed"    // Batch command failure; takes index, count, and exit code
+	msgOutputSummary  = "output_summary"  // Completion summary; takes the output filename and its details
+	msgDone           = "done"            // Done line of short jobs; takes the description and seconds taken
+	msgSegments       = "segments"        // Segments written by segmenting muxers; takes the count
+	msgUnknownEncoder = "unknown_encoder" // Failure explanation; takes the misspelled encoder name
+	msgUnknownDecoder = "unknown_decoder" // Failure explanation; takes the misspelled decoder name
+	msgDiskFull       = "disk_full"       // Printed when FFmpeg was stopped because the disk is full
+	msgJobOverall     = "job_overall"     // Batch line above the bar; takes the job, job count and overall percentage
+	msgDTSWarnings    = "dts_warnings"    // Printed after an encode with timestamp warnings; takes the count
+	msgBenchCPU       = "bench_cpu"       // Summary detail from -benchmark; takes the CPU seconds
+	msgBenchRSS       = "bench_rss"       // Summary detail from -benchmark; takes the formatted peak memory size
+)
+
+// catalogs holds the built-in translations, keyed by language code.
+// English is the fallback for unknown languages and missing keys.
+var catalogs = map[string]map[string]string{
+	"en": {
+		msgProcessing:     "Processing",
+		msgETA:            "ETA",
+		msgExiting:        "Exiting.",
+		msgBatchComplete:  "Batch complete: %d succeeded, %d failed",
+		msgBatchFailed:    "[%d/%d] failed with exit code %d",
+		msgOutputSummary:  "Output: %s (%s)",
+		msgDone:           "%s: done in %.1fs",
+		msgSegments:       "%d segments",
+		msgUnknownEncoder: "Unknown encoder '%s'; run 'ffmpeg -encoders' to list the available encoders",
+		msgUnknownDecoder: "Unknown decoder '%s'; run 'ffmpeg -decoders' to list the available decoders",
+		msgDiskFull:       "Disk full: FFmpeg was stopped and the output is incomplete",
+		msgJobOverall:     "Job %d/%d • overall %.0f%%",
+		msgDTSWarnings:    "⚠ %d DTS warnings (audio and video may be out of sync)",
+		msgBenchCPU:       "%.2fs CPU time",
+		msgBenchRSS:       "%s peak memory",
+	},
+	"es": {
+		msgProcessing:     "Procesando",
+		msgETA:            "Restan",
+		msgExiting:        "Saliendo.",
+		msgBatchComplete:  "Lote terminado: %d correctos, %d con error",
+		msgBatchFailed:    "[%d/%d] falló con código de salida %d",
+		msgOutputSummary:  "Salida: %s (%s)",
+		msgDone:           "%s: terminado en %.1fs",
+		msgSegments:       "%d segmentos",
+		msgUnknownEncoder: "Codificador desconocido '%s'; ejecuta 'ffmpeg -encoders' para ver los disponibles",
+		msgUnknownDecoder: "Decodificador desconocido '%s'; ejecuta 'ffmpeg -decoders' para ver los disponibles",
+		msgDiskFull:       "Disco lleno: se detuvo FFmpeg y la salida está incompleta",
+		msgJobOverall:     "Trabajo %d/%d • total %.0f%%",
+		msgDTSWarnings:    "⚠ %d avisos de DTS (el audio y el video pueden estar desincronizados)",
+		msgBenchCPU:       "%.2fs de CPU",
+		msgBenchRSS:       "%s de memoria máxima",
+	},
+	"pt": {
+		msgProcessing:     "Processando",
+		msgETA:            "Restam",
+		msgExiting:        "Saindo.",
+		msgBatchComplete:  "Lote concluído: %d com sucesso, %d com falha",
+		msgBatchFailed:    "[%d/%d] falhou com código de saída %d",
+		msgOutputSummary:  "Saída: %s (%s)",
+		msgDone:           "%s: concluído em %.1fs",
+		msgSegments:       "%d segmentos",
+		msgUnknownEncoder: "Codificador desconhecido '%s'; execute 'ffmpeg -encoders' para ver os disponíveis",
+		msgUnknownDecoder: "Decodificador desconhecido '%s'; execute 'ffmpeg -decoders' para ver os disponíveis",
+		msgDiskFull:       "Disco cheio: o FFmpeg foi interrompido e a saída está incompleta",
+		msgJobOverall:     "Tarefa %d/%d • total %.0f%%",
+		msgDTSWarnings:    "⚠ %d avisos de DTS (áudio e vídeo podem estar dessincronizados)",
+		msgBenchCPU:       "%.2fs de CPU",
+		msgBenchRSS:       "%s de memória máxima",
+	},
+	"fr": {
+		msgProcessing:     "Traitement",
+		msgETA:            "Reste",
+		msgExiting:        "Arrêt.",
+		msgBatchComplete:  "Lot terminé : %d réussis, %d échoués",
+		msgBatchFailed:    "[%d/%d] a échoué avec le code de sortie %d",
+		msgOutputSummary:  "Sortie : %s (%s)",
+		msgDone:           "%s : terminé en %.1fs",
+		msgSegments:       "%d segments",
+		msgUnknownEncoder: "Encodeur inconnu '%s' ; lancez 'ffmpeg -encoders' pour voir ceux disponibles",
+		msgUnknownDecoder: "Décodeur inconnu '%s' ; lancez 'ffmpeg -decoders' pour voir ceux disponibles",
+		msgDiskFull:       "Disque plein : FFmpeg a été arrêté et la sortie est incomplète",
+		msgJobOverall:     "Tâche %d/%d • global %.0f%%",
+		msgDTSWarnings:    "⚠ %d avertissements DTS (l'audio et la vidéo peuvent être désynchronisés)",
+		msgBenchCPU:       "%.2fs de CPU",
+		msgBenchRSS:       "%s de mémoire max.",
+	},
+	"de": {
+		msgProcessing:     "Verarbeitung",
+		msgETA:            "Rest",
+		msgExiting:        "Beende.",
+		msgBatchComplete:  "Stapel fertig: %d erfolgreich, %d fehlgeschlagen",
+		msgBatchFailed:    "[%d/%d] mit Exit-Code %d fehlgeschlagen",
+		msgOutputSummary:  "Ausgabe: %s (%s)",
+		msgDone:           "%s: fertig in %.1fs",
+		msgSegments:       "%d Segmente",
+		msgUnknownEncoder: "Unbekannter Encoder '%s'; 'ffmpeg -encoders' listet die verfügbaren Encoder",
+		msgUnknownDecoder: "Unbekannter Decoder '%s'; 'ffmpeg -decoders' listet die verfügbaren Decoder",
+		msgDiskFull:       "Datenträger voll: FFmpeg wurde gestoppt, die Ausgabe ist unvollständig",
+		msgJobOverall:     "Auftrag %d/%d • gesamt %.0f%%",
+		msgDTSWarnings:    "⚠ %d DTS-Warnungen (Audio und Video sind möglicherweise nicht synchron)",
+		msgBenchCPU:       "%.2fs CPU-Zeit",
+		msgBenchRSS:       "%s Spitzenspeicher",
+	},
+}
+
+// messages is the catalog for the active locale.
+var messages = catalogs["en"]
+
+func init() {
+	setLocale(detectLocale())
+}
+
+// detectLocale returns the user's language from the standard locale
+// environment variables (LC_ALL, LC_MESSAGES, LANG), e.g. "es" for "es_MX.UTF-8".
+func detectLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			lang, _, _ := strings.Cut(value, "_")
+			lang, _, _ = strings.Cut(lang, ".")
+			return strings.ToLower(lang)
+		}
+	}
+	return "en"
+}
+
+// setLocale selects the message catalog for the given language code,
+// falling back to English when no translation is available.
+func setLocale(lang string) {
+	if catalog, ok := catalogs[lang]; ok {
+		messages = catalog
+	} else {
+		messages = catalogs["en"]
+	}
+}
+
+// T returns the translated user interface string for key.
+func T(key string) string {
+	if msg, ok := messages[key]; ok {
+		return msg
+	}
+	return catalogs["en"][key]
+}
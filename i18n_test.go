@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the tests with the English messages, whatever the locale of
+// the user running them.
+func TestMain(m *testing.M) {
+	setLocale("en")
+	os.Exit(m.Run())
+}
+
+func TestDetectLocale(t *testing.T) {
+	tests := []struct {
+		lcAll, lcMessages, lang string
+		want                    string
+	}{
+		{"", "", "", "en"},
+		{"", "", "es_ES.UTF-8", "es"},
+		{"", "pt_BR", "es_ES.UTF-8", "pt"},
+		{"fr_FR.UTF-8", "pt_BR", "es_ES.UTF-8", "fr"},
+		{"", "", "C.UTF-8", "c"},
+		{"", "", "DE", "de"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_MESSAGES", tt.lcMessages)
+		t.Setenv("LANG", tt.lang)
+		if got := detectLocale(); got != tt.want {
+			t.Errorf("detectLocale() with LC_ALL=%q LC_MESSAGES=%q LANG=%q = %q, want %q",
+				tt.lcAll, tt.lcMessages, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestSpanishETA(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	setLocale(detectLocale())
+	defer setLocale("en")
+
+	pb := NewProgressBar("in.mp4", 100, "frames", false, io.Discard)
+	pb.current = 50
+	if line := pb.renderBar(); !strings.Contains(line, "• Restan ") || strings.Contains(line, "ETA") {
+		t.Errorf("bar %q, want the Spanish ETA label", line)
+	}
+	pb.SetMode(ModeLine)
+	if line := pb.render(); !strings.Contains(line, " Restan ") {
+		t.Errorf("status line %q, want the Spanish ETA label", line)
+	}
+
+	// Unknown languages and missing keys fall back to English
+	setLocale("xx")
+	if got := T(msgETA); got != "ETA" {
+		t.Errorf("T(msgETA) = %q for an unknown language, want ETA", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// errInputStopped is returned by a stoppableReader's Read once its input is stopped.
+var errInputStopped = errors.New("input stopped")
+
+// stoppableReader reads prompt answers from r until done is closed. A stopped
+// read returns errInputStopped instead of waiting for the user, so no goroutine
+// is left blocked on the input, and nothing typed afterwards is consumed.
+type stoppableReader struct {
+	r    io.Reader
+	done <-chan struct{}
+}
+
+// Read waits until r has input, or until the input is stopped. Files (stdin)
+// are polled for input (see waitReadable); other readers with a read deadline,
+// such as network connections, are unblocked by stop.
+func (sr *stoppableReader) Read(p []byte) (int, error) {
+	select {
+	case <-sr.done:
+		return 0, errInputStopped
+	default:
+	}
+	if f, ok := sr.r.(*os.File); ok {
+		if err := waitReadable(f, sr.done); err != nil {
+			return 0, err
+		}
+	}
+	n, err := sr.r.Read(p)
+	select {
+	case <-sr.done:
+		if n == 0 {
+			return 0, errInputStopped
+		}
+	default:
+	}
+	return n, err
+}
+
+// stop interrupts a read blocked on a reader with a read deadline. Files are
+// left alone: they are polled instead, and a deadline on stdin would outlive fpb.
+func (sr *stoppableReader) stop() {
+	if _, ok := sr.r.(*os.File); ok {
+		return
+	}
+	if d, ok := sr.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		d.SetReadDeadline(time.Now())
+	}
+}
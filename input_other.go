@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// waitReadable returns right away where files can't be polled for input: the
+// read then blocks until the user answers, even after the input is stopped.
+func waitReadable(f *os.File, done <-chan struct{}) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// inputPollTimeout is how often a wait for input checks whether it was stopped.
+const inputPollTimeout = 100 // Milliseconds
+
+// waitReadable waits until f has input (or is at its end), or returns
+// errInputStopped once done is closed. The file is polled rather than read,
+// since a blocking read of a terminal can't be interrupted.
+func waitReadable(f *os.File, done <-chan struct{}) error {
+	// Unlike f.Fd(), the raw connection keeps f's mode and is safe against a concurrent Close
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	for {
+		select {
+		case <-done:
+			return errInputStopped
+		default:
+		}
+		var n int
+		var pollErr error
+		err := conn.Control(func(fd uintptr) {
+			n, pollErr = unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, inputPollTimeout)
+		})
+		if pollErr == unix.EINTR {
+			continue
+		}
+		if err != nil || pollErr != nil || n > 0 {
+			// Let the read itself report errors
+			return nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobsInterval is how often a job's progress is shared and the overall progress re-read.
+const jobsInterval = time.Second
+
+// jobBoard shares the progress of one job of a batch that a parent script runs
+// as separate fpb invocations, and reads back the progress of the whole batch.
+// The jobs share a directory holding one file per job ("job-3" holds job 3's
+// percentage), each replaced atomically by the job that owns it, so concurrent
+// jobs never write the same file and readers never see a partial one.
+type jobBoard struct {
+	dir   string // Shared directory
+	index int    // This job's number, from 1
+	total int    // Number of jobs in the batch
+
+	mu      sync.Mutex // Guards latest, dirty and overall
+	latest  float64    // Most recent percentage of this job
+	dirty   bool       // Whether latest hasn't been shared yet
+	overall float64    // Overall percentage of the batch as last read
+
+	loop *periodic // Shares new percentages every jobsInterval
+}
+
+// newJobBoard starts sharing the progress of job index of total through dir,
+// which is created if needed.
+func newJobBoard(dir string, index, total int) (*jobBoard, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	jb := &jobBoard{dir: dir, index: index, total: total}
+	jb.overall = jb.readOverall()
+	jb.loop = startPeriodic(jobsInterval, jb.shareLatest)
+	return jb, nil
+}
+
+// Update records the latest percentage of this job, to be shared with the next refresh.
+func (jb *jobBoard) Update(percent float64) {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+	jb.latest = percent
+	jb.dirty = true
+}
+
+// Overall returns the overall percentage of the batch: the average over all
+// jobs, counting jobs that haven't reported yet as 0%.
+func (jb *jobBoard) Overall() float64 {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+	return jb.overall
+}
+
+// Close stops sharing progress after sharing the last percentage, if it is new.
+func (jb *jobBoard) Close() {
+	jb.loop.Stop()
+}
+
+// shareLatest writes the latest percentage if it hasn't been shared yet, and
+// re-reads the overall percentage.
+func (jb *jobBoard) shareLatest() {
+	jb.mu.Lock()
+	percent, dirty := jb.latest, jb.dirty
+	jb.dirty = false
+	jb.mu.Unlock()
+
+	if dirty {
+		writeFileAtomic(filepath.Join(jb.dir, fmt.Sprintf("job-%d", jb.index)), []byte(strconv.FormatFloat(percent, 'f', 2, 64)+"\n"))
+	}
+	overall := jb.readOverall()
+
+	jb.mu.Lock()
+	jb.overall = overall
+	jb.mu.Unlock()
+}
+
+// readOverall averages the percentages of jobs 1 to total found in the directory.
+func (jb *jobBoard) readOverall() float64 {
+	var sum float64
+	for i := 1; i <= jb.total; i++ {
+		data, err := os.ReadFile(filepath.Join(jb.dir, fmt.Sprintf("job-%d", i)))
+		if err != nil {
+			continue
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err == nil {
+			sum += min(max(percent, 0), 100)
+		}
+	}
+	return sum / float64(jb.total)
+}
+
+// parseJob parses a job number and batch size given as "3/10".
+func parseJob(v string) (index, total int, err error) {
+	i, n, ok := strings.Cut(v, "/")
+	if ok {
+		index, err = strconv.Atoi(i)
+	}
+	if ok && err == nil {
+		total, err = strconv.Atoi(n)
+	}
+	if !ok || err != nil || index < 1 || index > total {
+		return 0, 0, fmt.Errorf("must be JOB/TOTAL, e.g. 3/10")
+	}
+	return index, total, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJobBoard(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "jobs")
+
+	// Four jobs of a batch of five report concurrently; job 5 hasn't started
+	var wg sync.WaitGroup
+	for i, percent := range []float64{100, 50, 25, 10} {
+		wg.Add(1)
+		go func(index int, percent float64) {
+			defer wg.Done()
+			jb, err := newJobBoard(dir, index, 5)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for p := 0.0; p <= percent; p += 5 {
+				jb.Update(p)
+			}
+			jb.Close() // Shares the last percentage
+		}(i+1, percent)
+	}
+	wg.Wait()
+
+	jb, err := newJobBoard(dir, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jb.Close()
+	if got := jb.Overall(); got != 37 {
+		t.Errorf("overall %v%%, want (100+50+25+10+0)/5 = 37%%", got)
+	}
+
+	// The files are replaced atomically, leaving nothing else behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("directory holds %d files, want one per reporting job", len(entries))
+	}
+
+	// Unreadable and out of range percentages are ignored or clamped
+	os.WriteFile(filepath.Join(dir, "job-1"), []byte("garbage\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "job-2"), []byte("250\n"), 0o644)
+	if got := jb.readOverall(); got != (100+25+10)/5.0 {
+		t.Errorf("overall %v%%, want job 1 skipped and job 2 clamped to 100%%", got)
+	}
+}
+
+func TestJobOverallHeader(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "job-1"), []byte("100.00\n"), 0o644)
+	jb, err := newJobBoard(dir, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jb.Close()
+
+	opts := NewOptions()
+	opts.JobOverall = true
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	cpn.SetJobBoard(jb)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "\r")+1])
+	if cpn.pbar == nil || cpn.pbar.header != "Job 2/2 • overall 50%" {
+		t.Fatalf("bar %+v, want the overall progress header", cpn.pbar)
+	}
+
+	// In ModeBar the header is drawn above the bar, and erased with it
+	var out bytes.Buffer
+	pb := NewProgressBar("in.mp4", 100, "frames", false, &out)
+	pb.SetHeader("Job 2/2 • overall 50%")
+	pb.updateDelay = 0
+	pb.Update(10)
+	if !strings.HasPrefix(out.String(), "\r\033[KJob 2/2 • overall 50%\n\033[Kin.mp4 ") {
+		t.Errorf("bar %q, want the header line above it", out.String())
+	}
+	out.Reset()
+	pb.Update(20)
+	if !strings.HasPrefix(out.String(), "\033[1A\r\033[KJob 2/2") {
+		t.Errorf("next bar %q, want it to redraw the header line", out.String())
+	}
+	out.Reset()
+	pb.Clear()
+	if out.String() != "\r\033[K\033[1A\033[K" {
+		t.Errorf("Clear wrote %q, want the bar and header erased", out.String())
+	}
+}
+
+func TestParseJob(t *testing.T) {
+	if index, total, err := parseJob("3/10"); index != 3 || total != 10 || err != nil {
+		t.Errorf("parseJob(3/10) = %d, %d, %v", index, total, err)
+	}
+	for _, v := range []string{"3", "0/10", "11/10", "a/b", "3/"} {
+		if _, _, err := parseJob(v); err == nil {
+			t.Errorf("parseJob(%q) accepted", v)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pushInterval is how often progress metrics are pushed to the Pushgateway.
+const pushInterval = 5 * time.Second
+
+// finalPushTimeout is how long Close waits for the pushes to finish, so an
+// unreachable Pushgateway delays fpb's exit (and each job of a batch) by at
+// most this much.
+const finalPushTimeout = 500 * time.Millisecond
+
+// metricsSample is one set of progress metrics.
+type metricsSample struct {
+	Percent float64 // Completed percentage (0-100)
+	FPS     float64 // Processing rate in frames per second
+	Speed   float64 // Processing speed relative to real time
+}
+
+// metricsPusher periodically pushes the latest progress metrics to a Prometheus
+// Pushgateway in the text exposition format. Updates never block: the pushing
+// happens on its own goroutine, and failed pushes are dropped so monitoring
+// problems never disturb the encode.
+type metricsPusher struct {
+	url    string             // Push URL, including the job/output grouping key
+	client *http.Client       // HTTP client with a timeout
+	ctx    context.Context    // Context of every push request
+	cancel context.CancelFunc // Cancels the pushes still running finalPushTimeout into Close
+
+	mu     sync.Mutex    // Guards latest and dirty
+	latest metricsSample // Most recent sample
+	dirty  bool          // Whether latest hasn't been pushed yet
+
+	loop *periodic // Pushes new samples every pushInterval
+}
+
+// newMetricsPusher starts pushing metrics to the Pushgateway at gateway,
+// grouped under the given job and (if not empty) output labels.
+func newMetricsPusher(gateway, job, output string) *metricsPusher {
+	mp := &metricsPusher{
+		url:    pushURL(gateway, job, output),
+		client: &http.Client{Timeout: pushInterval},
+	}
+	mp.ctx, mp.cancel = context.WithCancel(context.Background())
+	mp.loop = startPeriodic(pushInterval, mp.pushLatest)
+	return mp
+}
+
+// pushURL builds the Pushgateway URL for a grouping key. Label values are
+// base64-encoded, as the Pushgateway allows, since paths may contain slashes.
+func pushURL(gateway, job, output string) string {
+	u := strings.TrimRight(gateway, "/") + "/metrics/job@base64/" + base64.RawURLEncoding.EncodeToString([]byte(job))
+	if output != "" {
+		u += "/output@base64/" + base64.RawURLEncoding.EncodeToString([]byte(output))
+	}
+	return u
+}
+
+// Update records the latest sample, to be sent with the next push.
+func (mp *metricsPusher) Update(s metricsSample) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.latest = s
+	mp.dirty = true
+}
+
+// Close stops the push loop after pushing the last sample, if it is new,
+// giving up on the pushes after finalPushTimeout.
+func (mp *metricsPusher) Close() {
+	timer := time.AfterFunc(finalPushTimeout, mp.cancel)
+	defer timer.Stop()
+	mp.loop.Stop()
+	mp.cancel()
+}
+
+// pushLatest pushes the latest sample if it hasn't been pushed yet.
+func (mp *metricsPusher) pushLatest() {
+	mp.mu.Lock()
+	s, dirty := mp.latest, mp.dirty
+	mp.dirty = false
+	mp.mu.Unlock()
+
+	if dirty {
+		if err := mp.push(s); err != nil {
+			debugf("pushing metrics: %v", err)
+		}
+	}
+}
+
+// push sends one sample, replacing the metrics of the grouping key (HTTP PUT).
+func (mp *metricsPusher) push(s metricsSample) error {
+	req, err := http.NewRequestWithContext(mp.ctx, http.MethodPut, mp.url, strings.NewReader(formatMetrics(s)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := mp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// formatMetrics renders a sample in the Prometheus text exposition format.
+func formatMetrics(s metricsSample) string {
+	var b strings.Builder
+	for _, m := range []struct {
+		name, help string
+		value      float64
+	}{
+		{"fpb_progress_percent", "Completed percentage of the FFmpeg job.", s.Percent},
+		{"fpb_fps", "Frames processed per second.", s.FPS},
+		{"fpb_speed", "Processing speed relative to real time.", s.Speed},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", m.name, m.help, m.name, m.name, m.value)
+	}
+	return b.String()
+}
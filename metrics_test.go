@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsPusher(t *testing.T) {
+	var mu sync.Mutex
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		method, path, body = r.Method, r.URL.Path, string(b)
+	}))
+	defer gateway.Close()
+
+	mp := newMetricsPusher(gateway.URL+"/", "encode", "/videos/out.mp4")
+	mp.Update(metricsSample{Percent: 42.5, FPS: 118, Speed: 4.7})
+	mp.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if method != http.MethodPut {
+		t.Errorf("method %q, want PUT", method)
+	}
+	wantPath := "/metrics/job@base64/" + base64.RawURLEncoding.EncodeToString([]byte("encode")) +
+		"/output@base64/" + base64.RawURLEncoding.EncodeToString([]byte("/videos/out.mp4"))
+	if path != wantPath {
+		t.Errorf("path %q, want %q", path, wantPath)
+	}
+	for _, want := range []string{"fpb_progress_percent 42.5\n", "fpb_fps 118\n", "fpb_speed 4.7\n", "# TYPE fpb_speed gauge\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsPusherCloseUnresponsive(t *testing.T) {
+	release := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer gateway.Close()
+	defer close(release)
+
+	mp := newMetricsPusher(gateway.URL, "fpb", "")
+	mp.Update(metricsSample{Percent: 10})
+	start := time.Now()
+	mp.Close()
+	if elapsed := time.Since(start); elapsed > 2*finalPushTimeout {
+		t.Errorf("Close took %s with an unresponsive Pushgateway", elapsed)
+	}
+}
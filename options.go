@@ -0,0 +1,886 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fpbFlagPrefix marks command-line flags that belong to fpb rather than FFmpeg.
+const fpbFlagPrefix = "--fpb-"
+
+// defaultEnvPrefix starts the environment variables that set fpb's flags.
+const defaultEnvPrefix = "FPB_"
+
+// Progress display modes selectable with --fpb-mode.
+const (
+	ModeAuto = "auto" // Bar on a terminal, plain lines otherwise
+	ModeBar  = "bar"  // Animated in-place progress bar
+	ModeLine = "line" // One plain status line per update
+	ModeJSON = "json" // One JSON record per update
+	ModeNone = "none" // No progress output; prompts are still shown and answered
+)
+
+// Units for the current/total segment selectable with --fpb-unit.
+const (
+	UnitAuto     = "auto"     // Frames when the frame rate is known, seconds otherwise
+	UnitTime     = "time"     // Media time, e.g. "00:58 / 02:08"
+	UnitTimecode = "timecode" // SMPTE timecode, e.g. "00:00:58:12 / 00:02:08:00" (time when the frame rate is unknown)
+	UnitFrames   = "frames"   // Frame counts (seconds when the frame rate is unknown)
+)
+
+// Overwrite policies selectable with --fpb-overwrite.
+const (
+	OverwriteAsk = "ask" // FFmpeg asks, unless -y or -n is given (never overwrite when nobody can answer)
+	OverwriteYes = "yes" // Always overwrite, passing FFmpeg -y
+	OverwriteNo  = "no"  // Never overwrite, passing FFmpeg -n
+)
+
+// Ways of erasing the previous bar selectable with --fpb-clear.
+const (
+	ClearCR   = "cr"   // Carriage return and erase line ("\r\033[K")
+	ClearANSI = "ansi" // Cursor up and erase line for every line of the block ("\033[1A\033[2K")
+)
+
+// Options holds fpb's own settings.
+// They are given on the command line as --fpb-* flags mixed with FFmpeg's arguments,
+// and are removed before the remaining arguments are passed to FFmpeg.
+type Options struct {
+	MaxBytesPerSec  int    // Maximum terminal output in bytes per second (0 = unlimited)
+	Mode            string // Progress display mode (one of the Mode* constants)
+	InlinePercent   bool   // Draw the percentage centered inside the bar
+	KeepGoing       bool   // Run one FFmpeg command per stdin line, continuing past failures
+	Overwrite       string // Whether existing output files are overwritten (one of the Overwrite* constants)
+	CountUnit       string // Unit of the current/total segment (one of the Unit* constants)
+	TimeMode        bool   // Count progress in seconds of media even when the frame rate is known
+	FastParse       bool   // Parse stats lines with the hand-written scanner instead of regexps
+	ClearOnExit     bool   // Erase the progress bar when done instead of leaving the final bar
+	Tmux            bool   // Show the progress in the tmux pane title when running inside tmux
+	RefreshOnResize bool   // Reflow the final bar when the terminal is resized after completion
+	ClearStyle      string // How the previous bar is erased (one of the Clear* constants)
+	Placeholder     string // Description shown until a filename is known ("" = translated "Processing")
+	Spinner         string // Spinner shown until the progress bar appears ("none" or a key of spinners)
+	Summary         bool   // Print the output file's size and duration after a successful encode
+	FinalSummary    bool   // Print anything at all after the final bar (summary, warnings)
+	ThemeAuto       bool   // Pick light or dark colors from the terminal background (OSC 11 query)
+	SpeedColors     bool   // Color the bar by processing speed relative to real time
+	FinalColors     bool   // Turn the final bar bright green on success and red on failure
+	MaxBarWidth     int    // Widest the bar itself may be drawn (0 = fill the terminal)
+	Brackets        string // Opening and closing characters drawn around the bar ("" = none)
+	TrackColor      string // Color of the unfilled part of the bar (one of TrackColors, "" = gray)
+	ETAGauge        bool   // Show a small gauge of the remaining work after the ETA
+	DualFPS         bool   // Show FFmpeg's current fps next to the average fps, e.g. "118/104fps"
+	GPU             bool   // Show the GPU utilization polled from nvidia-smi
+	SplitBar        bool   // Also fill the bar by output size relative to the input size
+	ShowStats       bool   // Print FFmpeg's raw stats lines above the bar
+	Plain           bool   // Draw an FFmpeg-style stats line in place of the bar
+	InputDurations  bool   // Describe multiple inputs by name and duration
+	PTY             bool   // Run FFmpeg with its stderr on a pseudo-terminal (Linux and macOS)
+
+	ErrorKeywords        []string // Extra keywords selecting the output lines highlighted on failure
+	ReplaceErrorKeywords bool     // Use ErrorKeywords instead of the defaults rather than in addition
+
+	PushGateway string // Prometheus Pushgateway URL to push progress metrics to ("" = disabled)
+	PushJob     string // Job label of the pushed metrics
+	Checkpoint  string // File the progress state is periodically written to ("" = disabled)
+	StatusFile  string // File kept holding a single human-readable progress line ("" = disabled)
+	StatsFile   string // CSV file a row of statistics is appended to per update ("" = disabled)
+	JobsDir     string // Directory the jobs of a batch share their progress through ("" = disabled)
+	Job         int    // This job's number in the batch, from 1 (0 = not part of a batch)
+	JobTotal    int    // Number of jobs in the batch
+	JobOverall  bool   // Draw the batch's overall progress above the bar
+
+	Sample      string        // Saved FFmpeg log to run through the parser instead of running FFmpeg
+	Simulate    time.Duration // Length of a synthetic encode drawn instead of running FFmpeg (0 = run FFmpeg)
+	Replay      bool          // Draw the progress of FFmpeg output read from stdin instead of running FFmpeg
+	ReplayPaced bool          // Replay stats lines at the pace of the original run
+
+	MinDuration time.Duration // Inputs shorter than this get a done line instead of the bar (0 = always show the bar)
+	StartDelay  time.Duration // How long the progress runs before the bar is first drawn (0 = right away)
+	IdleRefresh time.Duration // How long the bar may go without being redrawn while FFmpeg is silent (0 = never)
+
+	PercentPrecision int    // Decimals shown in the percentage (0-2)
+	DecimalSeparator string // Separator of the decimals in displayed numbers ("." or ",")
+
+	Debug     bool   // Log fpb's internal decisions to stderr
+	DebugFile string // Log fpb's internal decisions to this file instead ("" = stderr, if Debug)
+
+	EnvPrefix string // Prefix of the environment variables that set flags
+	Strict    bool   // Reject unknown --fpb-* flags instead of passing them to FFmpeg
+	Version   bool   // Print fpb's version instead of running FFmpeg
+}
+
+// errorKeywords returns the keywords selecting the output lines highlighted on failure:
+// the defaults plus ErrorKeywords, or only ErrorKeywords with ReplaceErrorKeywords.
+func (o *Options) errorKeywords() []string {
+	if o.ReplaceErrorKeywords {
+		return o.ErrorKeywords
+	}
+	return append(append([]string{}, defaultErrorKeywords...), o.ErrorKeywords...)
+}
+
+// NewOptions creates an Options instance with fpb's default settings.
+func NewOptions() *Options {
+	return &Options{
+		Mode:       ModeAuto,
+		CountUnit:  UnitAuto,
+		Overwrite:  OverwriteAsk,
+		PushJob:    "fpb",
+		ClearStyle: ClearCR,
+		Spinner:    "none",
+		EnvPrefix:  defaultEnvPrefix,
+
+		FinalSummary: true,
+		IdleRefresh:  time.Second,
+
+		PercentPrecision: 1,
+		DecimalSeparator: ".",
+	}
+}
+
+// fpbFlag describes a single --fpb-* command-line flag.
+type fpbFlag struct {
+	name  string                           // Flag name without the --fpb- prefix
+	arg   string                           // Value placeholder for the usage text, empty for boolean flags
+	usage string                           // One-line description for the usage text
+	set   func(o *Options, v string) error // Applies the flag to the options
+
+	hidden bool // Whether the flag is left out of the usage text
+}
+
+// fpbFlags lists every flag understood by fpb.
+var fpbFlags = []fpbFlag{
+	{
+		name:  "min-interval-bytes",
+		arg:   "N",
+		usage: "limit terminal output to N bytes per second (coalesces updates on slow links)",
+		set: func(o *Options, v string) error {
+			n, err := parseNonNegativeInt(v)
+			o.MaxBytesPerSec = n
+			return err
+		},
+	},
+	{
+		name:  "mode",
+		arg:   "auto|bar|line|json|none",
+		usage: "progress display; auto uses the bar on a terminal and plain lines otherwise, none only prompts",
+		set: func(o *Options, v string) error {
+			switch v {
+			case ModeAuto, ModeBar, ModeLine, ModeJSON, ModeNone:
+				o.Mode = v
+				return nil
+			}
+			return fmt.Errorf("must be one of auto, bar, line, json, none")
+		},
+	},
+	{
+		name:  "overwrite",
+		arg:   "ask|yes|no",
+		usage: "overwrite existing output files (yes, FFmpeg's -y), never (no, -n), or let FFmpeg ask (default ask)",
+		set: func(o *Options, v string) error {
+			switch v {
+			case OverwriteAsk, OverwriteYes, OverwriteNo:
+				o.Overwrite = v
+				return nil
+			}
+			return fmt.Errorf("must be one of ask, yes, no")
+		},
+	},
+	{
+		name:  "inline-percent",
+		usage: "draw the percentage centered inside the bar when it is wide enough",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.InlinePercent = b
+			return err
+		},
+	},
+	{
+		name:  "percent-precision",
+		arg:   "N",
+		usage: "show the percentage with N decimals, 0 to 2 (default 1)",
+		set: func(o *Options, v string) error {
+			n, err := parseNonNegativeInt(v)
+			if err == nil && n > 2 {
+				return fmt.Errorf("must be 0, 1 or 2")
+			}
+			o.PercentPrecision = n
+			return err
+		},
+	},
+	{
+		name:  "decimal",
+		arg:   ".|,",
+		usage: "decimal separator of the displayed numbers (default .)",
+		set: func(o *Options, v string) error {
+			if v != "." && v != "," {
+				return fmt.Errorf("must be . or ,")
+			}
+			o.DecimalSeparator = v
+			return nil
+		},
+	},
+	{
+		name:  "unit",
+		arg:   "auto|time|timecode|frames",
+		usage: "unit of the current/total count; time shows media time even when fps is known, timecode HH:MM:SS:FF",
+		set: func(o *Options, v string) error {
+			switch v {
+			case UnitAuto, UnitTime, UnitTimecode, UnitFrames:
+				o.CountUnit = v
+				return nil
+			}
+			return fmt.Errorf("must be one of auto, time, timecode, frames")
+		},
+	},
+	{
+		name:  "time-mode",
+		usage: "compute the progress from time= and the duration only, never from frames and an estimated frame rate",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.TimeMode = b
+			return err
+		},
+	},
+	{
+		name:  "fast-parse",
+		usage: "parse ffmpeg stats lines with a hand-written scanner instead of regexps",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.FastParse = b
+			return err
+		},
+	},
+	{
+		name:  "clear-on-exit",
+		usage: "erase the progress bar when done instead of leaving the final bar on screen",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ClearOnExit = b
+			return err
+		},
+	},
+	{
+		name:  "tmux",
+		usage: "show the progress in the tmux pane title (no-op outside tmux)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Tmux = b
+			return err
+		},
+	},
+	{
+		name:  "clear",
+		arg:   "cr|ansi",
+		usage: "how the previous bar is erased; ansi moves up and clears whole lines instead of using \\r",
+		set: func(o *Options, v string) error {
+			switch v {
+			case ClearCR, ClearANSI:
+				o.ClearStyle = v
+				return nil
+			}
+			return fmt.Errorf("must be one of cr, ansi")
+		},
+	},
+	{
+		name:  "placeholder",
+		arg:   "TEXT",
+		usage: "description shown until the input or output filename is known (default Processing)",
+		set: func(o *Options, v string) error {
+			o.Placeholder = v
+			return nil
+		},
+	},
+	{
+		name:  "spinner",
+		arg:   "none|dots|line|braille",
+		usage: "spinner animated while waiting for ffmpeg to start reporting progress",
+		set: func(o *Options, v string) error {
+			if _, ok := spinners[v]; !ok && v != "none" {
+				return fmt.Errorf("must be one of none, dots, line, braille")
+			}
+			o.Spinner = v
+			return nil
+		},
+	},
+	{
+		name:  "summary",
+		usage: "after a successful encode, print the output file's size and duration",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Summary = b
+			return err
+		},
+	},
+	{
+		name:  "final-summary",
+		usage: "print the summary and warnings after the final bar; =off prints nothing after it (default on)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.FinalSummary = b
+			return err
+		},
+	},
+	{
+		name:  "theme-auto",
+		usage: "ask the terminal for its background color and use darker colors on light backgrounds",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ThemeAuto = b
+			return err
+		},
+	},
+	{
+		name:  "min-duration",
+		arg:   "SECONDS",
+		usage: "don't animate the bar for inputs shorter than this; just print a done line",
+		set: func(o *Options, v string) error {
+			d, err := parseSeconds(v)
+			o.MinDuration = d
+			return err
+		},
+	},
+	{
+		name:  "start-delay",
+		arg:   "SECONDS",
+		usage: "draw the bar only once the progress has run this long; faster jobs show no bar at all",
+		set: func(o *Options, v string) error {
+			d, err := parseSeconds(v)
+			o.StartDelay = d
+			return err
+		},
+	},
+	{
+		name:  "idle-refresh",
+		arg:   "SECONDS",
+		usage: "redraw the bar this often while ffmpeg reports nothing, so the ETA stays current; 0 disables (default 1)",
+		set: func(o *Options, v string) error {
+			d, err := parseSeconds(v)
+			o.IdleRefresh = d
+			return err
+		},
+	},
+	{
+		name:  "speed-colors",
+		usage: "color the bar by speed: green at real time or faster, yellow from 0.5x, red below",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.SpeedColors = b
+			return err
+		},
+	},
+	{
+		name:  "final-colors",
+		usage: "turn the whole bar bright green when the encode succeeds and red when it fails",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.FinalColors = b
+			return err
+		},
+	},
+	{
+		name:  "max-bar-width",
+		arg:   "N",
+		usage: "draw the bar at most N cells wide on wide terminals (0 = fill the terminal)",
+		set: func(o *Options, v string) error {
+			n, err := parseNonNegativeInt(v)
+			o.MaxBarWidth = n
+			return err
+		},
+	},
+	{
+		name:  "brackets",
+		arg:   "CHARS",
+		usage: "draw the bar between two characters, e.g. [] (default none)",
+		set: func(o *Options, v string) error {
+			if v != "" && len([]rune(v)) != 2 {
+				return fmt.Errorf("must be two characters, the opening and the closing one")
+			}
+			o.Brackets = v
+			return nil
+		},
+	},
+	{
+		name:  "track-color",
+		arg:   "gray|white|cyan|blue|none",
+		usage: "color of the unfilled part of the bar; none uses the terminal's default (default gray)",
+		set: func(o *Options, v string) error {
+			for _, name := range TrackColors {
+				if v == name {
+					o.TrackColor = v
+					return nil
+				}
+			}
+			return fmt.Errorf("must be one of %s", strings.Join(TrackColors, ", "))
+		},
+	},
+	{
+		name:  "eta-gauge",
+		usage: "show a small gauge of the remaining work after the ETA, e.g. [▓▓▓░░]",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ETAGauge = b
+			return err
+		},
+	},
+	{
+		name:  "dual-fps",
+		usage: "show FFmpeg's current fps next to the average of the whole run, e.g. 118/104fps",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.DualFPS = b
+			return err
+		},
+	},
+	{
+		name:  "gpu",
+		usage: "show the GPU utilization reported by nvidia-smi, e.g. for NVENC encodes",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.GPU = b
+			return err
+		},
+	},
+	{
+		name:  "split-bar",
+		usage: "for remuxes, also fill the bar in blue by output size relative to the input size",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.SplitBar = b
+			return err
+		},
+	},
+	{
+		name:  "show-stats",
+		usage: "also print ffmpeg's raw stats lines (frame=... speed=...) above the bar, for debugging",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ShowStats = b
+			return err
+		},
+	},
+	{
+		name:  "plain",
+		usage: "draw ffmpeg's familiar one-line stats (frame= fps= size= time= bitrate= speed=) in place of the bar",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Plain = b
+			return err
+		},
+	},
+	{
+		name:  "input-durations",
+		usage: "with several inputs (overlay, mix), describe them by name and duration (in:02:08 overlay:00:30)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.InputDurations = b
+			return err
+		},
+	},
+	{
+		name:  "refresh-on-resize",
+		usage: "redraw the final bar at the new width when the terminal is resized after completion",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.RefreshOnResize = b
+			return err
+		},
+	},
+	{
+		name:  "error-keywords",
+		arg:   "LIST",
+		usage: "comma-separated extra keywords of the output lines highlighted when ffmpeg fails",
+		set: func(o *Options, v string) error {
+			for _, keyword := range strings.Split(v, ",") {
+				if keyword = strings.TrimSpace(keyword); keyword != "" {
+					o.ErrorKeywords = append(o.ErrorKeywords, keyword)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:  "error-keywords-replace",
+		usage: "use only the --fpb-error-keywords list instead of adding it to the defaults",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ReplaceErrorKeywords = b
+			return err
+		},
+	},
+	{
+		name:  "pushgateway",
+		arg:   "URL",
+		usage: "push progress metrics (fpb_progress_percent, fpb_fps, fpb_speed) to a Prometheus Pushgateway",
+		set: func(o *Options, v string) error {
+			if !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+				return fmt.Errorf("must be an http:// or https:// URL")
+			}
+			o.PushGateway = v
+			return nil
+		},
+	},
+	{
+		name:  "push-job",
+		arg:   "NAME",
+		usage: "job label of the pushed metrics (default fpb)",
+		set: func(o *Options, v string) error {
+			if v == "" {
+				return fmt.Errorf("must not be empty")
+			}
+			o.PushJob = v
+			return nil
+		},
+	},
+	{
+		name:  "sample",
+		arg:   "FILE",
+		usage: "don't run ffmpeg; show how a saved ffmpeg log (2>FILE) is parsed, to debug progress problems",
+		set: func(o *Options, v string) error {
+			o.Sample = v
+			return nil
+		},
+	},
+	{
+		name:  "simulate",
+		arg:   "SECONDS",
+		usage: "don't run ffmpeg; draw a synthetic encode lasting SECONDS, for demos and rendering tests",
+		set: func(o *Options, v string) error {
+			d, err := parseSeconds(v)
+			if err == nil && d == 0 {
+				return fmt.Errorf("must be positive")
+			}
+			o.Simulate = d
+			return err
+		},
+		hidden: true,
+	},
+	{
+		name:  "replay",
+		usage: "don't run ffmpeg; draw the progress of ffmpeg output read from stdin (fpb --fpb-replay < FILE)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Replay = b
+			return err
+		},
+	},
+	{
+		name:  "replay-paced",
+		usage: "with --fpb-replay, draw each stats line when it was logged in the original run, going by its time and speed",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.ReplayPaced = b
+			return err
+		},
+	},
+	{
+		name:  "checkpoint",
+		arg:   "FILE",
+		usage: "every few seconds, atomically write the progress state as JSON to FILE, for monitoring",
+		set: func(o *Options, v string) error {
+			o.Checkpoint = v
+			return nil
+		},
+	},
+	{
+		name:  "status-file",
+		arg:   "FILE",
+		usage: "keep FILE holding one up-to-date progress line (42% | 118fps | ETA 03:12), e.g. for watch",
+		set: func(o *Options, v string) error {
+			o.StatusFile = v
+			return nil
+		},
+	},
+	{
+		name:  "stats-to-file",
+		arg:   "FILE",
+		usage: "append a CSV row (timestamp,percent,frame,fps,speed,bitrate,size) per update to FILE",
+		set: func(o *Options, v string) error {
+			o.StatsFile = v
+			return nil
+		},
+	},
+	{
+		name:  "job",
+		arg:   "N/TOTAL",
+		usage: "this run is job N of TOTAL started by a script; shares its progress through --fpb-jobs-dir",
+		set: func(o *Options, v string) error {
+			index, total, err := parseJob(v)
+			o.Job, o.JobTotal = index, total
+			return err
+		},
+	},
+	{
+		name:  "jobs-dir",
+		arg:   "DIR",
+		usage: "directory the jobs of a batch (see --fpb-job) share their progress through, one file per job",
+		set: func(o *Options, v string) error {
+			o.JobsDir = v
+			return nil
+		},
+	},
+	{
+		name:  "job-overall",
+		usage: "draw the job number and the overall progress of the batch above the bar",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.JobOverall = b
+			return err
+		},
+	},
+	{
+		name:  "pty",
+		usage: "run ffmpeg with its stderr on a pseudo-terminal, so it logs as in a terminal (Linux, macOS)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.PTY = b
+			return err
+		},
+	},
+	{
+		name:  "debug",
+		usage: "log fpb's own decisions (duration, frame rate, unit, width, injected flags) to stderr",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Debug = b
+			return err
+		},
+	},
+	{
+		name:  "debug-file",
+		arg:   "FILE",
+		usage: "append the --fpb-debug log to FILE instead of stderr",
+		set: func(o *Options, v string) error {
+			o.DebugFile = v
+			return nil
+		},
+	},
+	{
+		name:  "env-prefix",
+		arg:   "PREFIX",
+		usage: "read flags from PREFIX<NAME> environment variables instead of FPB_<NAME> (e.g. FPB_MODE)",
+		set: func(o *Options, v string) error {
+			o.EnvPrefix = v
+			return nil
+		},
+	},
+	{
+		name:  "strict",
+		usage: "treat unknown --fpb-* flags as errors instead of passing them to ffmpeg",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Strict = b
+			return err
+		},
+	},
+	{
+		name:  "keep-going",
+		usage: "batch mode: run one ffmpeg command per stdin line, continuing past failures",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.KeepGoing = b
+			return err
+		},
+	},
+	{
+		name:  "version",
+		usage: "print the fpb and ffmpeg versions and exit (-version still prints ffmpeg's banner)",
+		set: func(o *Options, v string) error {
+			b, err := parseBool(v)
+			o.Version = b
+			return err
+		},
+	},
+}
+
+// lookupFlag returns the registered flag with the given name, or nil if unknown.
+func lookupFlag(name string) *fpbFlag {
+	for i := range fpbFlags {
+		if fpbFlags[i].name == name {
+			return &fpbFlags[i]
+		}
+	}
+	return nil
+}
+
+// parseArgs separates fpb's own flags from the arguments meant for FFmpeg.
+// Flags are accepted as "--fpb-name=value" or "--fpb-name value".
+// Unrecognized --fpb-* flags are passed through to FFmpeg untouched, or are an
+// error with --fpb-strict. Flags can also be set in the environment (see applyEnv); command-line
+// flags take precedence.
+//
+// Returns the parsed options and the remaining FFmpeg arguments.
+func parseArgs(args []string) (*Options, []string, error) {
+	opts := NewOptions()
+	ffmpegArgs := make([]string, 0, len(args))
+
+	// Flags are collected first, since --fpb-env-prefix decides which
+	// environment variables apply before them
+	type flagValue struct {
+		flag  *fpbFlag
+		value string
+	}
+	var given []flagValue
+	var unknown []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, fpbFlagPrefix) {
+			ffmpegArgs = append(ffmpegArgs, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, fpbFlagPrefix), "=")
+		flag := lookupFlag(name)
+		if flag == nil {
+			unknown = append(unknown, fpbFlagPrefix+name)
+			ffmpegArgs = append(ffmpegArgs, arg)
+			continue
+		}
+
+		if flag.arg != "" && !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag %s%s requires a value", fpbFlagPrefix, name)
+			}
+			i++
+			value = args[i]
+		}
+		given = append(given, flagValue{flag, value})
+	}
+
+	for _, fv := range given {
+		if fv.flag.name == "env-prefix" {
+			fv.flag.set(opts, fv.value)
+		}
+	}
+	if err := applyEnv(opts, opts.EnvPrefix); err != nil {
+		return nil, nil, err
+	}
+
+	for _, fv := range given {
+		if err := fv.flag.set(opts, fv.value); err != nil {
+			return nil, nil, fmt.Errorf("invalid value %q for %s%s: %v", fv.value, fpbFlagPrefix, fv.flag.name, err)
+		}
+	}
+	if opts.Strict && len(unknown) > 0 {
+		return nil, nil, fmt.Errorf("unknown flag %s (run fpb without arguments to list fpb's flags)", unknown[0])
+	}
+
+	return opts, ffmpegArgs, nil
+}
+
+// applyEnv sets the flags given in the environment as <prefix><NAME>, where NAME
+// is the flag name in upper case with underscores, e.g. FPB_MODE=line for
+// --fpb-mode=line or FPB_MAX_BAR_WIDTH=80. Empty variables are ignored.
+func applyEnv(opts *Options, prefix string) error {
+	for i := range fpbFlags {
+		flag := &fpbFlags[i]
+		if commandLineOnly[flag.name] {
+			continue
+		}
+		name := envName(prefix, flag.name)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := flag.set(opts, value); err != nil {
+			return fmt.Errorf("invalid value %q for $%s: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// commandLineOnly lists the flags that can't be set in the environment, as they
+// pick what a single invocation does (and batch mode runs several).
+var commandLineOnly = map[string]bool{
+	"env-prefix":   true,
+	"keep-going":   true,
+	"sample":       true,
+	"simulate":     true,
+	"replay":       true,
+	"replay-paced": true,
+	"version":      true,
+}
+
+// envName returns the environment variable that sets a flag, e.g. "FPB_MAX_BAR_WIDTH"
+// for "max-bar-width" with the default prefix.
+func envName(prefix, flagName string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// withoutFlag returns a copy of args with the given boolean --fpb-* flag removed.
+func withoutFlag(args []string, name string) []string {
+	flag := fpbFlagPrefix + name
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+// printUsage writes the command-line usage, including fpb's own flags.
+func printUsage(w io.Writer, program string) {
+	fmt.Fprintf(w, "Usage: %s [fpb-options] <ffmpeg-args>\n", program)
+	fmt.Fprintf(w, "\nfpb options:\n")
+	names := make([]string, len(fpbFlags))
+	width := 0
+	for i, flag := range fpbFlags {
+		if flag.hidden {
+			continue
+		}
+		names[i] = fpbFlagPrefix + flag.name
+		if flag.arg != "" {
+			names[i] += "=" + flag.arg
+		}
+		width = max(width, len(names[i]))
+	}
+	for i, flag := range fpbFlags {
+		if flag.hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, names[i], flag.usage)
+	}
+	fmt.Fprintf(w, "\nFFmpeg commands that write an output also get -progress pipe:2, unless they have a -progress.\n")
+}
+
+// parseBool parses the value of a boolean flag.
+// A flag given without a value ("--fpb-name") means true; on and off are
+// accepted besides strconv.ParseBool's true/false forms.
+func parseBool(v string) (bool, error) {
+	switch v {
+	case "", "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// parseSeconds parses a flag value that must be a number of seconds >= 0.
+func parseSeconds(v string) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if secs < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// parseNonNegativeInt parses a flag value that must be a whole number >= 0.
+func parseNonNegativeInt(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
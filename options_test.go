@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		maxBytes int
+		ffmpeg   []string
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, 0, []string{"-i", "in.mp4", "out.mp4"}},
+		{[]string{"--fpb-min-interval-bytes=500", "-i", "in.mp4", "out.mp4"}, 500, []string{"-i", "in.mp4", "out.mp4"}},
+		{[]string{"-i", "in.mp4", "--fpb-min-interval-bytes", "64", "out.mp4"}, 64, []string{"-i", "in.mp4", "out.mp4"}},
+		{[]string{"--fpb-unknown", "-i", "in.mp4"}, 0, []string{"--fpb-unknown", "-i", "in.mp4"}},
+	}
+	for _, tt := range tests {
+		opts, ffmpegArgs, err := parseArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if opts.MaxBytesPerSec != tt.maxBytes || !slices.Equal(ffmpegArgs, tt.ffmpeg) {
+			t.Errorf("parseArgs(%q) = %d, %q; want %d, %q", tt.args, opts.MaxBytesPerSec, ffmpegArgs, tt.maxBytes, tt.ffmpeg)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--fpb-min-interval-bytes"},
+		{"--fpb-min-interval-bytes=-1"},
+		{"--fpb-min-interval-bytes=fast"},
+	} {
+		if _, _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q) accepted", args)
+		}
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	var buf bytes.Buffer
+	printUsage(&buf, "fpb")
+	for _, want := range []string{"Usage: fpb", "--fpb-min-interval-bytes=N", "-progress pipe:2"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("usage:\n%s\nwant %q", buf.String(), want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-mode=json", "-i", "in.mp4"})
+	if err != nil || opts.Mode != ModeJSON {
+		t.Errorf("--fpb-mode=json: mode %q, error %v", opts.Mode, err)
+	}
+	if opts := NewOptions(); opts.Mode != ModeAuto {
+		t.Errorf("default mode %q, want %s", opts.Mode, ModeAuto)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-mode=fancy"}); err == nil {
+		t.Error("--fpb-mode=fancy accepted")
+	}
+}
+
+func TestParseInlinePercent(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"--fpb-inline-percent"}, true},
+		{[]string{"--fpb-inline-percent=true"}, true},
+		{[]string{"--fpb-inline-percent=false"}, false},
+	} {
+		opts, _, err := parseArgs(append(tt.args, "-i", "in.mp4"))
+		if err != nil || opts.InlinePercent != tt.want {
+			t.Errorf("parseArgs(%q): inline %t, error %v; want %t", tt.args, opts.InlinePercent, err, tt.want)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-unit=time", "-i", "in.mp4"})
+	if err != nil || opts.CountUnit != UnitTime {
+		t.Errorf("--fpb-unit=time: unit %q, error %v", opts.CountUnit, err)
+	}
+	opts, _, err = parseArgs([]string{"--fpb-unit=timecode", "-i", "in.mp4"})
+	if err != nil || opts.CountUnit != UnitTimecode {
+		t.Errorf("--fpb-unit=timecode: unit %q, error %v", opts.CountUnit, err)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-unit=hours"}); err == nil {
+		t.Error("--fpb-unit=hours accepted")
+	}
+}
+
+func TestParseClear(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-clear=ansi", "-i", "in.mp4"})
+	if err != nil || opts.ClearStyle != ClearANSI {
+		t.Errorf("--fpb-clear=ansi: style %q, error %v", opts.ClearStyle, err)
+	}
+	if NewOptions().ClearStyle != ClearCR {
+		t.Errorf("default clear style %q, want %q", NewOptions().ClearStyle, ClearCR)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-clear=vt52"}); err == nil {
+		t.Error("--fpb-clear=vt52 accepted")
+	}
+}
+
+func TestParseMaxBarWidth(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-max-bar-width=60", "-i", "in.mp4"})
+	if err != nil || opts.MaxBarWidth != 60 {
+		t.Errorf("--fpb-max-bar-width=60: width %d, error %v", opts.MaxBarWidth, err)
+	}
+	for _, v := range []string{"-1", "wide"} {
+		if _, _, err := parseArgs([]string{"--fpb-max-bar-width=" + v}); err == nil {
+			t.Errorf("--fpb-max-bar-width=%s accepted", v)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	t.Setenv("FPB_MODE", "line")
+	t.Setenv("FPB_MAX_BAR_WIDTH", "40")
+	t.Setenv("FPB_KEEP_GOING", "true")
+	opts, _, err := parseArgs([]string{"-i", "in.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Mode != ModeLine || opts.MaxBarWidth != 40 {
+		t.Errorf("mode %q, max bar width %d, want line and 40 from the environment", opts.Mode, opts.MaxBarWidth)
+	}
+	if opts.KeepGoing {
+		t.Error("FPB_KEEP_GOING set batch mode; it is a command-line only flag")
+	}
+
+	// Command-line flags take precedence
+	opts, _, err = parseArgs([]string{"--fpb-mode=json", "-i", "in.mp4"})
+	if err != nil || opts.Mode != ModeJSON {
+		t.Errorf("mode %q, error %v, want json from the command line", opts.Mode, err)
+	}
+
+	// A custom prefix reads other variables and ignores FPB_*
+	t.Setenv("MYAPP_MODE", "json")
+	opts, _, err = parseArgs([]string{"--fpb-env-prefix=MYAPP_", "-i", "in.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Mode != ModeJSON || opts.MaxBarWidth != 0 {
+		t.Errorf("mode %q, max bar width %d, want only MYAPP_MODE applied", opts.Mode, opts.MaxBarWidth)
+	}
+
+	t.Setenv("FPB_MAX_BAR_WIDTH", "wide")
+	if _, _, err := parseArgs([]string{"-i", "in.mp4"}); err == nil || !strings.Contains(err.Error(), "$FPB_MAX_BAR_WIDTH") {
+		t.Errorf("invalid FPB_MAX_BAR_WIDTH: error %v, want one naming the variable", err)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	_, _, err := parseArgs([]string{"--fpb-strict", "--fpb-foo=1", "-i", "in.mp4"})
+	if err == nil || !strings.Contains(err.Error(), "unknown flag --fpb-foo") {
+		t.Errorf("strict with --fpb-foo: error %v, want one naming the flag", err)
+	}
+
+	// The flag can come after the unknown one, or from the environment
+	if _, _, err := parseArgs([]string{"--fpb-foo", "--fpb-strict", "-i", "in.mp4"}); err == nil {
+		t.Error("--fpb-foo accepted before --fpb-strict")
+	}
+	t.Setenv("FPB_STRICT", "1")
+	if _, _, err := parseArgs([]string{"--fpb-foo", "-i", "in.mp4"}); err == nil {
+		t.Error("--fpb-foo accepted with FPB_STRICT=1")
+	}
+
+	// Known flags are fine
+	if _, ffmpegArgs, err := parseArgs([]string{"--fpb-mode=line", "-i", "in.mp4"}); err != nil || !slices.Equal(ffmpegArgs, []string{"-i", "in.mp4"}) {
+		t.Errorf("strict with known flags: %q, %v", ffmpegArgs, err)
+	}
+}
+
+func TestParsePercentPrecision(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-percent-precision=0", "-i", "in.mp4"})
+	if err != nil || opts.PercentPrecision != 0 {
+		t.Errorf("--fpb-percent-precision=0: %d, error %v", opts.PercentPrecision, err)
+	}
+	if opts := NewOptions(); opts.PercentPrecision != 1 {
+		t.Errorf("default precision %d, want 1", opts.PercentPrecision)
+	}
+	for _, v := range []string{"3", "-1", "one"} {
+		if _, _, err := parseArgs([]string{"--fpb-percent-precision=" + v}); err == nil {
+			t.Errorf("--fpb-percent-precision=%s accepted", v)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-decimal=,", "-i", "in.mp4"})
+	if err != nil || opts.DecimalSeparator != "," {
+		t.Errorf("--fpb-decimal=,: %q, error %v", opts.DecimalSeparator, err)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-decimal=;"}); err == nil {
+		t.Error("--fpb-decimal=; accepted")
+	}
+}
+
+func TestParseOverwrite(t *testing.T) {
+	if opts, _, _ := parseArgs(nil); opts.Overwrite != OverwriteAsk {
+		t.Errorf("default overwrite policy %q, want ask", opts.Overwrite)
+	}
+	for _, v := range []string{OverwriteAsk, OverwriteYes, OverwriteNo} {
+		if opts, _, err := parseArgs([]string{"--fpb-overwrite=" + v}); err != nil || opts.Overwrite != v {
+			t.Errorf("--fpb-overwrite=%s: %q, error %v", v, opts.Overwrite, err)
+		}
+	}
+	if _, _, err := parseArgs([]string{"--fpb-overwrite=maybe"}); err == nil {
+		t.Error("--fpb-overwrite=maybe accepted")
+	}
+}
+
+func TestParseIdleRefresh(t *testing.T) {
+	if opts, _, _ := parseArgs(nil); opts.IdleRefresh != time.Second {
+		t.Errorf("default idle refresh %v, want 1s", opts.IdleRefresh)
+	}
+	for v, want := range map[string]time.Duration{"0": 0, "0.5": 500 * time.Millisecond, "2": 2 * time.Second} {
+		if opts, _, err := parseArgs([]string{"--fpb-idle-refresh=" + v}); err != nil || opts.IdleRefresh != want {
+			t.Errorf("--fpb-idle-refresh=%s: %v, error %v; want %v", v, opts.IdleRefresh, err, want)
+		}
+	}
+	if _, _, err := parseArgs([]string{"--fpb-idle-refresh=-1"}); err == nil {
+		t.Error("--fpb-idle-refresh=-1 accepted")
+	}
+}
+
+func TestParseTimeMode(t *testing.T) {
+	if opts, _, err := parseArgs([]string{"--fpb-time-mode", "-i", "in.mp4"}); err != nil || !opts.TimeMode {
+		t.Errorf("--fpb-time-mode: TimeMode %v, error %v", opts.TimeMode, err)
+	}
+	if opts, _, _ := parseArgs([]string{"-i", "in.mp4"}); opts.TimeMode {
+		t.Error("TimeMode on by default")
+	}
+}
+
+func TestParseFinalSummary(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{"", true},
+		{"--fpb-final-summary", true},
+		{"--fpb-final-summary=on", true},
+		{"--fpb-final-summary=off", false},
+		{"--fpb-final-summary=false", false},
+	}
+	for _, tt := range tests {
+		args := []string{"-i", "in.mp4"}
+		if tt.arg != "" {
+			args = append([]string{tt.arg}, args...)
+		}
+		if opts, _, err := parseArgs(args); err != nil || opts.FinalSummary != tt.want {
+			t.Errorf("%q: FinalSummary %v, error %v; want %v", tt.arg, opts.FinalSummary, err, tt.want)
+		}
+	}
+	if _, _, err := parseArgs([]string{"--fpb-final-summary=maybe"}); err == nil {
+		t.Error("--fpb-final-summary=maybe accepted")
+	}
+}
+
+func TestParseDualFPS(t *testing.T) {
+	if opts, _, err := parseArgs([]string{"--fpb-dual-fps"}); err != nil || !opts.DualFPS {
+		t.Errorf("--fpb-dual-fps: DualFPS %v, error %v", opts.DualFPS, err)
+	}
+	if opts, _, _ := parseArgs(nil); opts.DualFPS {
+		t.Error("DualFPS on by default")
+	}
+}
+
+func TestParsePlain(t *testing.T) {
+	if opts, _, err := parseArgs([]string{"--fpb-plain", "-i", "in.mp4"}); err != nil || !opts.Plain {
+		t.Errorf("--fpb-plain: Plain %v, error %v", opts.Plain, err)
+	}
+}
+
+func TestParseTrackColor(t *testing.T) {
+	for _, v := range TrackColors {
+		if opts, _, err := parseArgs([]string{"--fpb-track-color=" + v}); err != nil || opts.TrackColor != v {
+			t.Errorf("--fpb-track-color=%s: %q, error %v", v, opts.TrackColor, err)
+		}
+	}
+	if _, _, err := parseArgs([]string{"--fpb-track-color=red"}); err == nil {
+		t.Error("--fpb-track-color=red accepted")
+	}
+}
+
+func TestParseBrackets(t *testing.T) {
+	for _, v := range []string{"[]", "⟦⟧", ""} {
+		if opts, _, err := parseArgs([]string{"--fpb-brackets=" + v}); err != nil || opts.Brackets != v {
+			t.Errorf("--fpb-brackets=%s: %q, error %v", v, opts.Brackets, err)
+		}
+	}
+	for _, v := range []string{"[", "[[]"} {
+		if _, _, err := parseArgs([]string{"--fpb-brackets=" + v}); err == nil {
+			t.Errorf("--fpb-brackets=%s accepted", v)
+		}
+	}
+}
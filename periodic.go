@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// periodic calls a function at a fixed interval on its own goroutine,
+// and one last time when stopped, for background reporting of progress.
+type periodic struct {
+	done    chan struct{} // Closed by Stop to end the loop
+	stopped chan struct{} // Closed when the loop has exited
+}
+
+// startPeriodic starts calling fn every interval until Stop is called.
+func startPeriodic(interval time.Duration, fn func()) *periodic {
+	p := &periodic{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer recoverPanic()
+		defer close(p.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-p.done:
+				fn()
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Stop ends the loop after a final call and waits for it to finish.
+func (p *periodic) Stop() {
+	close(p.done)
+	<-p.stopped
+}
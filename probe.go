@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// probeDuration returns the duration of a media file in microseconds, as
+// reported by ffprobe.
+func probeDuration(path string) (int64, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, err
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(secs * 1000000), nil
+}
+
+// concatInputs returns the files joined by a concat protocol input
+// ("-i concat:a.ts|b.ts"), or nil if there is none.
+func concatInputs(args []string) []string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-i" && strings.HasPrefix(args[i+1], "concat:") {
+			return strings.Split(strings.TrimPrefix(args[i+1], "concat:"), "|")
+		}
+	}
+	return nil
+}
+
+// concatDuration sums the durations of the files of a concat protocol input,
+// for which FFmpeg may report the duration of the first file only. Reports
+// false if any file can't be probed, so FFmpeg's own duration is used instead.
+func concatDuration(files []string) (int64, bool) {
+	var total int64
+	for _, file := range files {
+		us, err := probeDuration(file)
+		if err != nil || us <= 0 {
+			return 0, false
+		}
+		total += us
+	}
+	return total, total > 0
+}
+
+// streamLoops returns how many extra times an input is looped with
+// "-stream_loop N", or false if no input is looped a known number of times
+// (N = -1 loops forever).
+func streamLoops(args []string) (int, bool) {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] != "-stream_loop" {
+			continue
+		}
+		if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// inputSize returns the total size in bytes of the -i inputs, or false if any
+// of them isn't a regular file (a device, pipe or URL).
+func inputSize(args []string) (int64, bool) {
+	var total int64
+	found := false
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] != "-i" {
+			continue
+		}
+		info, err := os.Stat(args[i+1])
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		total += info.Size()
+		found = true
+	}
+	return total, found
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeFFprobe puts an "ffprobe" script first in PATH that reports a duration
+// of 10.5s for a.ts, 20.25s for b.ts and fails for anything else.
+func fakeFFprobe(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffprobe is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"for arg; do last=$arg; done\n" +
+		"case $last in\n" +
+		"a.ts) echo 10.500000;;\n" +
+		"b.ts) echo 20.250000;;\n" +
+		"*) echo \"$last: No such file or directory\" >&2; exit 1;;\n" +
+		"esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestConcatInputs(t *testing.T) {
+	files := concatInputs([]string{"-y", "-i", "concat:a.ts|b.ts", "-c", "copy", "out.mp4"})
+	if len(files) != 2 || files[0] != "a.ts" || files[1] != "b.ts" {
+		t.Errorf("concatInputs = %q, want [a.ts b.ts]", files)
+	}
+	if files := concatInputs([]string{"-i", "in.mp4", "out.mp4"}); files != nil {
+		t.Errorf("concatInputs without concat: = %q, want nil", files)
+	}
+}
+
+func TestConcatDuration(t *testing.T) {
+	fakeFFprobe(t)
+	if us, ok := concatDuration([]string{"a.ts", "b.ts"}); !ok || us != 30750000 {
+		t.Errorf("concatDuration = %d, %t, want 30750000, true", us, ok)
+	}
+	// One unreadable file falls back to FFmpeg's own duration
+	if us, ok := concatDuration([]string{"a.ts", "missing.ts"}); ok {
+		t.Errorf("concatDuration with a missing file = %d, true, want false", us)
+	}
+}
+
+func TestSetDuration(t *testing.T) {
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetDuration(30750000)
+	feed(cpn, fakeEncode) // Reports the first file's 4s
+	if got := cpn.Duration().Microseconds(); got != 30750000 {
+		t.Errorf("Duration() = %dus after FFmpeg's header, want the set 30750000us", got)
+	}
+}
+
+func TestInputSize(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.ts"), filepath.Join(dir, "b.ts")
+	for _, f := range []struct {
+		path string
+		size int
+	}{{a, 1000}, {b, 500}} {
+		if err := os.WriteFile(f.path, make([]byte, f.size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, ok := inputSize([]string{"-i", a, "-i", b, "-c", "copy", "out.mkv"}); !ok || size != 1500 {
+		t.Errorf("inputSize = %d, %t, want 1500, true", size, ok)
+	}
+	for _, args := range [][]string{
+		{"-i", a, "-i", "rtmp://example.com/live", "out.mkv"},
+		{"-i", dir, "out.mkv"},
+		{"-f", "lavfi", "out.mkv"},
+	} {
+		if size, ok := inputSize(args); ok {
+			t.Errorf("inputSize(%q) = %d, true, want false", args, size)
+		}
+	}
+}
+
+func TestStreamLoops(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+		ok   bool
+	}{
+		{[]string{"-stream_loop", "2", "-i", "in.mp4", "out.mp4"}, 2, true},
+		{[]string{"-i", "in.mp4", "out.mp4"}, 0, false},
+		{[]string{"-stream_loop", "-1", "-i", "in.mp4", "out.mp4"}, 0, false},
+		{[]string{"-stream_loop", "0", "-i", "in.mp4", "out.mp4"}, 0, false},
+		{[]string{"-stream_loop"}, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := streamLoops(tt.args); got != tt.want || ok != tt.ok {
+			t.Errorf("streamLoops(%q) = %d, %v, want %d, %v", tt.args, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// ptyProcess runs FFmpeg with its stderr on a pseudo-terminal instead of a
+// pipe, for --fpb-pty. FFmpeg then logs as it does when run directly in a
+// terminal, and fpb reads its output from the pty's master side.
+type ptyProcess struct {
+	execProcess
+	master *os.File // fpb's side of the pty
+	slave  *os.File // FFmpeg's stderr, closed in fpb once FFmpeg has started
+}
+
+// newPTYProcess is the commandRunner used with --fpb-pty.
+func newPTYProcess(name string, args ...string) ffmpegProcess {
+	return &ptyProcess{execProcess: newExecProcess(name, args...).(execProcess)}
+}
+
+// StderrPipe opens the pty and returns the reader of FFmpeg's stderr.
+func (p *ptyProcess) StderrPipe() (io.ReadCloser, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	p.master, p.slave = master, slave
+	p.Stderr = slave
+	return ptyReader{master}, nil
+}
+
+// Start starts FFmpeg, then closes fpb's copy of the slave side, so reading
+// the master ends once FFmpeg exits.
+func (p *ptyProcess) Start() error {
+	err := p.Cmd.Start()
+	if p.slave != nil {
+		p.slave.Close()
+	}
+	return err
+}
+
+// Wait waits for FFmpeg to exit and closes the pty.
+func (p *ptyProcess) Wait() error {
+	err := p.Cmd.Wait()
+	if p.master != nil {
+		p.master.Close()
+	}
+	return err
+}
+
+// Resize sets the size of the pty, so FFmpeg sees the size of fpb's terminal.
+func (p *ptyProcess) Resize(width, height int) {
+	if p.master != nil {
+		setPTYSize(p.master, width, height)
+	}
+}
+
+// ptyReader reads the master side of a pty. Once the slave side is closed by
+// every process, Linux fails reads with EIO rather than returning EOF. The file
+// isn't embedded, so io.Copy can't bypass Read through (*os.File).WriteTo.
+type ptyReader struct {
+	f *os.File
+}
+
+// Read implements io.Reader, turning EIO into io.EOF.
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (r ptyReader) Close() error {
+	return r.f.Close()
+}
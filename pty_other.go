@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// errPTYUnsupported is returned by openPTY where fpb can't allocate a pty.
+var errPTYUnsupported = errors.New("--fpb-pty is only supported on Linux and macOS")
+
+// openPTY opens a new pseudo-terminal; it isn't supported on this platform.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errPTYUnsupported
+}
+
+// setPTYSize sets the window size of a pseudo-terminal; it isn't supported on this platform.
+func setPTYSize(f *os.File, width, height int) error {
+	return errPTYUnsupported
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testPTY opens a pseudo-terminal pair, closed at the end of the test, or
+// skips the test where fpb can't open one. The slave end is a real terminal
+// for isTerminal.
+func testPTY(t *testing.T) (master, slave *os.File) {
+	t.Helper()
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() {
+		master.Close()
+		slave.Close()
+	})
+	return master, slave
+}
+
+// setTerminalSize sets the size of the pseudo-terminal f.
+func setTerminalSize(t *testing.T, f *os.File, width, height int) {
+	t.Helper()
+	if err := setPTYSize(f, width, height); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPTYProcess(t *testing.T) {
+	testPTY(t)
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+
+	// The command reports whether its stderr is a terminal, and its size
+	p := newPTYProcess("/bin/sh", "-c", "if [ -t 2 ]; then stty size <&2 >&2; else echo pipe >&2; fi")
+	stderr, err := p.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.(*ptyProcess).Resize(93, 31)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(stderr)
+	if err != nil {
+		t.Errorf("reading the pty: %v", err)
+	}
+	if err := p.Wait(); err != nil {
+		t.Errorf("wait: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "31 93" {
+		t.Errorf("command saw %q, want a 93x31 terminal on its stderr", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// ProgressState is a progress snapshot for RenderLine.
+type ProgressState struct {
+	Desc      string        // Description shown left of the bar, usually a filename
+	Current   int           // Units processed so far
+	Total     int           // Total units (0 = unknown, drawn as an indeterminate bar)
+	Unit      string        // Unit of Current and Total: "frames" or "seconds"
+	FrameRate float64       // Media frame rate, for time and timecode counts of frames (0 = unknown)
+	FPS       float64       // Processing rate in units per second
+	LiveFPS   float64       // Current rate reported by FFmpeg, shown instead of FPS for frames (0 = unknown)
+	ETA       time.Duration // Estimated time remaining
+	Colors    bool          // Whether to include ANSI colors
+}
+
+// RenderLine renders a progress snapshot the way fpb draws its own progress, for
+// tools that track progress themselves. It does no I/O or throttling and returns
+// the line without cursor movement sequences or a trailing newline. The bar is
+// fitted to width columns. opts selects the display mode (ModeAuto renders the
+// bar) and the bar's look (InlinePercent, PercentPrecision, DecimalSeparator,
+// CountUnit, MaxBarWidth, Brackets, TrackColor, ETAGauge, DualFPS).
+func RenderLine(state ProgressState, width int, opts Options) string {
+	pb := NewProgressBar(state.Desc, state.Total, state.Unit, state.Colors, io.Discard)
+	pb.current = state.Current
+	pb.width = width
+	pb.fixedStats, pb.fixedRate, pb.fixedRemaining = true, state.FPS, state.ETA
+
+	mode := opts.Mode
+	if mode == ModeAuto {
+		mode = ModeBar
+	}
+	pb.SetMode(mode)
+	pb.SetInlinePercent(opts.InlinePercent)
+	pb.SetPercentPrecision(opts.PercentPrecision)
+	pb.SetDecimalSeparator(opts.DecimalSeparator)
+	pb.SetMaxBarWidth(opts.MaxBarWidth)
+	pb.SetBrackets(opts.Brackets)
+	pb.SetTrackColor(opts.TrackColor)
+	pb.SetETAGauge(opts.ETAGauge)
+	pb.SetDualFPS(opts.DualFPS)
+	pb.SetLiveFPS(state.LiveFPS, state.LiveFPS > 0)
+	pb.SetTargetFPS(int(state.FrameRate))
+	unitsPerSecond := 1.0
+	if state.Unit == "frames" {
+		unitsPerSecond = state.FrameRate
+	}
+	pb.SetCountUnit(opts.CountUnit, unitsPerSecond)
+
+	line := strings.TrimPrefix(pb.render(), "\r\033[K")
+	return strings.TrimSuffix(line, "\n")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderLine(t *testing.T) {
+	c := NewColors()
+	half := ProgressState{Desc: "in.mp4", Current: 50, Total: 100, Unit: "frames", FrameRate: 25, FPS: 25, ETA: 2 * time.Second}
+	colored := half
+	colored.Colors = true
+	seconds := ProgressState{Desc: "in.mp4", Current: 30, Total: 120, Unit: "seconds", FPS: 2, ETA: 45 * time.Second}
+
+	withMode := func(mode string) Options {
+		opts := NewOptions()
+		opts.Mode = mode
+		return *opts
+	}
+	tests := []struct {
+		name  string
+		state ProgressState
+		width int
+		opts  Options
+		want  string
+	}{
+		{"plain", half, 60, *NewOptions(),
+			"in.mp4 " + strings.Repeat("━", 8) + "╸" + strings.Repeat("━", 7) + "  50.0% •  50/100 • 25fps • ETA 00:02"},
+		{"wider", half, 80, *NewOptions(),
+			"in.mp4 " + strings.Repeat("━", 18) + "╸" + strings.Repeat("━", 17) + "  50.0% •  50/100 • 25fps • ETA 00:02"},
+		{"colors", colored, 60, *NewOptions(),
+			"in.mp4 " + c.Green + strings.Repeat("━", 8) + c.Reset + c.Green + "╸" + c.Reset + c.Gray + strings.Repeat("━", 7) + c.Reset + " " +
+				c.Yellow + " 50.0%" + c.Reset + " •  50/100 • " + c.Green + "25fps" + c.Reset + " • ETA " + c.Blue + "00:02" + c.Reset},
+		{"line", half, 60, withMode(ModeLine), "in.mp4: 50.0% 50/100 frames 25fps ETA 00:02"},
+		{"seconds", seconds, 60, withMode(ModeLine), "in.mp4: 25.0% 30/120 seconds ETA 00:45"},
+	}
+	for _, tt := range tests {
+		if got := RenderLine(tt.state, tt.width, tt.opts); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	// An unknown total renders the count and rate only
+	unknown := half
+	unknown.Total = 0
+	got := RenderLine(unknown, 60, *NewOptions())
+	if !strings.HasPrefix(got, "in.mp4 ") || !strings.HasSuffix(got, " 50 frames • 25fps") || textWidth(got) != 60 {
+		t.Errorf("unknown total: %q, want a 60 column line ending in the count and rate", got)
+	}
+}
+
+func TestRenderLineBrackets(t *testing.T) {
+	state := ProgressState{Desc: "in.mp4", Current: 50, Total: 100, Unit: "frames", FrameRate: 25, FPS: 25, ETA: 2 * time.Second}
+	opts := NewOptions()
+	for _, width := range []int{60, 80, 120} {
+		plain := RenderLine(state, width, *opts)
+		opts.Brackets = "[]"
+		bracketed := RenderLine(state, width, *opts)
+		opts.Brackets = ""
+
+		if !strings.HasPrefix(bracketed, "in.mp4 [━") || !strings.Contains(bracketed, "━] ") {
+			t.Errorf("width %d: %q, want the bar between brackets", width, bracketed)
+		}
+		// The bar shrinks to make room for the brackets
+		if textWidth(bracketed) != textWidth(plain) || textWidth(bracketed) > width {
+			t.Errorf("width %d: bracketed line is %d columns, plain %d; want both to fit", width, textWidth(bracketed), textWidth(plain))
+		}
+	}
+}
+
+func TestETAGauge(t *testing.T) {
+	tests := []struct {
+		percentage float64
+		want       string
+	}{
+		{0, "[▓▓▓▓▓]"},
+		{40, "[▓▓▓░░]"},
+		{75, "[▓░░░░]"},
+		{100, "[░░░░░]"},
+		{120, "[░░░░░]"},
+	}
+	for _, tt := range tests {
+		if got := etaGauge(tt.percentage); got != tt.want {
+			t.Errorf("etaGauge(%v) = %q, want %q", tt.percentage, got, tt.want)
+		}
+	}
+
+	// The gauge follows the ETA while the bar stays wide enough
+	state := ProgressState{Desc: "in.mp4", Current: 40, Total: 100, Unit: "frames", FrameRate: 25, FPS: 25, ETA: 2 * time.Second}
+	opts := NewOptions()
+	opts.ETAGauge = true
+	if got := RenderLine(state, 80, *opts); !strings.HasSuffix(got, "ETA 00:02 [▓▓▓░░]") || textWidth(got) != 80 {
+		t.Errorf("at 80 columns: %q, want the gauge after the ETA", got)
+	}
+	if got := RenderLine(state, 50, *opts); strings.Contains(got, "▓") {
+		t.Errorf("at 50 columns: %q, want the gauge left out", got)
+	}
+}
+
+func TestRenderLineDualFPS(t *testing.T) {
+	state := ProgressState{Desc: "in.mp4", Current: 50, Total: 100, Unit: "frames", FrameRate: 25, FPS: 104, LiveFPS: 118, ETA: 2 * time.Second}
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	opts.DualFPS = true
+	if got, want := RenderLine(state, 60, *opts), "in.mp4: 50.0% 50/100 frames 118/104fps ETA 00:02"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	opts.Mode = ModeBar
+	if got := RenderLine(state, 80, *opts); !strings.Contains(got, " • 118/104fps • ") {
+		t.Errorf("bar %q, want both rates", got)
+	}
+
+	// Only the average while FFmpeg hasn't reported a rate, and only the
+	// live rate without the option
+	noLive := state
+	noLive.LiveFPS = 0
+	if got := RenderLine(noLive, 80, *opts); !strings.Contains(got, " • 104fps • ") {
+		t.Errorf("without a live rate: %q, want the average alone", got)
+	}
+	if got := RenderLine(state, 80, *NewOptions()); !strings.Contains(got, " • 118fps • ") {
+		t.Errorf("without --fpb-dual-fps: %q, want the live rate alone", got)
+	}
+}
+
+func TestRenderLineFPS(t *testing.T) {
+	video := ProgressState{Desc: "in.mp4", Current: 50, Total: 100, Unit: "frames", FrameRate: 25, FPS: 40, ETA: 2 * time.Second}
+	audio := ProgressState{Desc: "in.wav", Current: 30, Total: 120, Unit: "seconds", FPS: 3, ETA: 30 * time.Second}
+	live := video
+	live.LiveFPS = 48
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	tests := []struct {
+		name  string
+		state ProgressState
+		opts  Options
+		want  string
+	}{
+		{"video", video, *opts, "in.mp4: 50.0% 50/100 frames 40fps ETA 00:02"},
+		{"video live", live, *opts, "in.mp4: 50.0% 50/100 frames 48fps ETA 00:02"},
+		{"audio", audio, *opts, "in.wav: 25.0% 30/120 seconds ETA 00:30"},
+		{"audio bar", audio, *NewOptions(), "in.wav " + strings.Repeat("━", 11) + "╸" + strings.Repeat("━", 32) + "  25.0% •  30/120 • ETA 00:30"},
+	}
+	for _, tt := range tests {
+		if got := RenderLine(tt.state, 80, tt.opts); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLineTrackColor(t *testing.T) {
+	c := NewColors()
+	state := ProgressState{Desc: "in.mp4", Current: 50, Total: 100, Unit: "frames", FrameRate: 25, FPS: 25, ETA: 2 * time.Second, Colors: true}
+	filled := c.Green + strings.Repeat("━", 8) + c.Reset + c.Green + "╸" + c.Reset
+	tests := []struct {
+		color string
+		track string // The unfilled run as drawn
+	}{
+		{"", c.Gray + strings.Repeat("━", 7) + c.Reset},
+		{"gray", "\033[90m" + strings.Repeat("━", 7) + c.Reset},
+		{"cyan", "\033[36m" + strings.Repeat("━", 7) + c.Reset},
+		{"none", strings.Repeat("━", 7) + " "},
+	}
+	for _, tt := range tests {
+		opts := NewOptions()
+		opts.TrackColor = tt.color
+		if got := RenderLine(state, 60, *opts); !strings.HasPrefix(got, "in.mp4 "+filled+tt.track) {
+			t.Errorf("track color %q: %q, want the unfilled run %q", tt.color, got, tt.track)
+		}
+	}
+
+	// Without colors the track color is ignored
+	state.Colors = false
+	opts := NewOptions()
+	opts.TrackColor = "cyan"
+	if got := RenderLine(state, 60, *opts); strings.Contains(got, "\033") {
+		t.Errorf("uncolored bar %q has color codes", got)
+	}
+}
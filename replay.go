@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runReplay draws the progress of FFmpeg output read from r (usually stdin)
+// instead of running FFmpeg, as if the encode were live, to reproduce rendering
+// problems from a saved or tee'd log. Unlike --fpb-sample, the output goes to
+// the usual bar. With paced, stats lines are held back until the time they were
+// logged at in the original run (see replayDelay); otherwise they are drawn as
+// fast as they arrive.
+func runReplay(r io.Reader, paced bool, opts *Options) int {
+	if isTerminalStream(r) {
+		fmt.Fprintf(os.Stderr, "Error: --fpb-replay reads an FFmpeg log from stdin, e.g. fpb --fpb-replay < ffmpeg.log\n")
+		return 1
+	}
+
+	notifier := NewColoredProgressNotifier(os.Stderr, supportsColor(os.Stderr), nopWriteCloser{io.Discard}, opts)
+	notifier.SetPromptDetection(false)
+	// The frame rate's place in the log is known, as for --fpb-sample
+	notifier.SetUnitWait(0)
+	defer notifier.ShowCursor()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	// Lines are read in the background so that an interrupt isn't held up by a blocked read
+	lines := make(chan []byte)
+	var readErr error
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := readLine(reader)
+			if len(line) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for line := range lines {
+		var wait time.Duration
+		if paced {
+			wait = replayDelay(string(line), time.Since(start))
+		}
+		select {
+		case <-sigChan:
+			fmt.Fprintf(os.Stderr, "\n%s\n", T(msgExiting))
+			return exitInterrupted
+		case <-time.After(wait):
+		}
+		notifier.Write(line)
+	}
+	notifier.Close()
+
+	if readErr != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", readErr)
+		return 1
+	}
+	return 0
+}
+
+// replayDelay returns how long to hold back a line of a paced replay that
+// has been running for elapsed. FFmpeg's speed is the media time encoded so
+// far relative to the time since the encode started, so a stats line with
+// "time=00:01:00.00 ... speed=2x" was logged 30 seconds into the original run.
+// Other lines, and stats lines without a time or speed, are not held back.
+func replayDelay(line string, elapsed time.Duration) time.Duration {
+	if !isStatsLine(line) {
+		return 0
+	}
+	st := parseStatsRegex(line)
+	if !st.HasTime || !st.HasSpeed || st.Speed <= 0 {
+		return 0
+	}
+	logged := time.Duration(float64(st.TimeUs) / st.Speed * float64(time.Microsecond))
+	return max(logged-elapsed, 0)
+}
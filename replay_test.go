@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReplay(t *testing.T) {
+	log, err := os.Open("testdata/sample.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	var status int
+	out := captureStderr(t, func() {
+		status = runReplay(log, false, opts)
+	})
+	if status != 0 {
+		t.Errorf("runReplay returned %d", status)
+	}
+	half := strings.Index(out, "clip.mov: 50.0% 150/300 frames")
+	done := strings.Index(out, "clip.mov: 100.0% 300/300 frames")
+	if half < 0 || done < half {
+		t.Errorf("output %q, want the bar going from 50%% to 100%%", out)
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	stats := "frame=  300 fps= 60 q=-1.0 size=    2048KiB time=00:01:00.00 bitrate=1677.7kbits/s speed=2x"
+	tests := []struct {
+		line    string
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		// One minute of media at 2x was logged 30 seconds in
+		{stats, 0, 30 * time.Second},
+		{stats, 10 * time.Second, 20 * time.Second},
+		{stats, time.Minute, 0},
+		{strings.Replace(stats, "speed=2x", "speed=N/A", 1), 0, 0},
+		{"  Duration: 00:00:10.00, start: 0.000000, bitrate: 8000 kb/s", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := replayDelay(tt.line, tt.elapsed); got != tt.want {
+			t.Errorf("replayDelay(%q, %v) = %v, want %v", tt.line, tt.elapsed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize calls onResize whenever the terminal window is resized (SIGWINCH)
+// until the returned stop function is called.
+func notifyResize(onResize func()) (stop func()) {
+	sigChan := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigChan, syscall.SIGWINCH)
+	go func() {
+		defer recoverPanic()
+		for {
+			select {
+			case <-sigChan:
+				onResize()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigChan)
+		close(done)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNotifyResize(t *testing.T) {
+	resized := make(chan struct{}, 1)
+	stop := notifyResize(func() {
+		select {
+		case resized <- struct{}{}:
+		default:
+		}
+	})
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGWINCH); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-resized:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onResize wasn't called after SIGWINCH")
+	}
+}
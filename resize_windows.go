@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// notifyResize is a no-op on Windows, which has no resize signal; the bar
+// still adapts to the terminal width on every update.
+func notifyResize(onResize func()) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// parserState is a snapshot of what the notifier has parsed so far,
+// compared line by line by --fpb-sample to report parse events.
+type parserState struct {
+	DurationUs int64
+	Source     string
+	Output     string
+	FrameRate  frameRate
+	FrameCount int
+	Segments   int
+	Current    int
+	Total      int
+	Unit       string
+	Finished   bool
+}
+
+// parserState returns a snapshot of the parsed header fields and progress.
+func (cpn *ColoredProgressNotifier) parserState() parserState {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	st := parserState{
+		DurationUs: cpn.durationUs,
+		Source:     cpn.source,
+		Output:     cpn.output,
+		FrameRate:  cpn.frameRate,
+		FrameCount: cpn.frameCount,
+		Segments:   cpn.segments,
+		Finished:   cpn.finished,
+	}
+	if cpn.pbar != nil {
+		st.Current, st.Total, st.Unit = cpn.pbar.current, cpn.pbar.total, cpn.pbar.unit
+	}
+	return st
+}
+
+// runSample feeds a saved FFmpeg log through the parser instead of running FFmpeg,
+// printing each parse event followed by the progress output it produces, so that
+// parsing problems can be reproduced and reported. The progress is shown as plain
+// lines unless another --fpb-mode than bar was requested.
+func runSample(path string, opts *Options) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	if opts.Mode == ModeAuto || opts.Mode == ModeBar {
+		opts.Mode = ModeLine
+	}
+	out := os.Stdout
+	notifier := NewColoredProgressNotifier(out, false, nopWriteCloser{io.Discard}, opts)
+	notifier.SetPromptDetection(false)
+	notifier.SetUnitWait(0)
+
+	reader := bufio.NewReader(f)
+	prev := notifier.parserState()
+	for {
+		// FFmpeg ends stats lines with \r and everything else with \n
+		line, err := readLine(reader)
+		if len(line) > 0 {
+			notifier.Write(line)
+			cur := notifier.parserState()
+			printParseEvents(out, prev, cur)
+			prev = cur
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 1
+		}
+	}
+
+	notifier.Close()
+	printParseEvents(out, prev, notifier.parserState())
+	return 0
+}
+
+// readLine reads up to and including the next \r or \n.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return line, err
+		}
+		line = append(line, c)
+		if c == '\r' || c == '\n' {
+			return line, nil
+		}
+	}
+}
+
+// printParseEvents writes one "event: ..." line for every parsed field that
+// changed between two parser states.
+func printParseEvents(w io.Writer, prev, cur parserState) {
+	if cur.DurationUs != prev.DurationUs {
+		fmt.Fprintf(w, "event: duration %.2fs\n", float64(cur.DurationUs)/1000000)
+	}
+	if cur.Source != prev.Source {
+		fmt.Fprintf(w, "event: source %q\n", cur.Source)
+	}
+	if cur.Output != prev.Output {
+		fmt.Fprintf(w, "event: output %q\n", cur.Output)
+	}
+	if cur.FrameRate != prev.FrameRate {
+		fmt.Fprintf(w, "event: frame rate %d/%d (%.3f fps)\n", cur.FrameRate.Num, cur.FrameRate.Den, cur.FrameRate.Float())
+	}
+	if cur.FrameCount != prev.FrameCount {
+		fmt.Fprintf(w, "event: frame count %d\n", cur.FrameCount)
+	}
+	if cur.Segments != prev.Segments {
+		fmt.Fprintf(w, "event: segment %d\n", cur.Segments)
+	}
+	if cur.Current != prev.Current || cur.Total != prev.Total || cur.Unit != prev.Unit {
+		fmt.Fprintf(w, "event: progress %d/%d %s\n", cur.Current, cur.Total, cur.Unit)
+	}
+	if cur.Finished && !prev.Finished {
+		fmt.Fprintf(w, "event: finished\n")
+	}
+}
+
+// nopWriteCloser turns a Writer into a WriteCloser whose Close does nothing.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.WriteCloser.
+func (nopWriteCloser) Close() error {
+	return nil
+}
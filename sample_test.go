@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunSample(t *testing.T) {
+	var status int
+	out := captureStdout(t, func() {
+		status = runSample("testdata/sample.log", NewOptions())
+	})
+	if status != 0 {
+		t.Errorf("runSample returned %d", status)
+	}
+	want := []string{
+		`event: source "clip.mov"`,
+		"event: duration 10.00s",
+		"event: frame rate 30000/1001 (29.970 fps)",
+		`event: output "clip.mp4"`,
+		"clip.mov: 50.0% 150/300 frames",
+		"event: progress 150/300 frames",
+		"event: progress 300/300 frames",
+		"clip.mov: 100.0% 300/300 frames",
+		"event: finished",
+	}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(out, w)
+		if i < 0 || i < last {
+			t.Fatalf("output:\n%s\nwant %q after the previous events", out, w)
+		}
+		last = i
+	}
+	if strings.ContainsAny(out, "\r\033") {
+		t.Errorf("output %q isn't plain lines", out)
+	}
+
+	errOut := captureStderr(t, func() {
+		status = runSample("testdata/missing.log", NewOptions())
+	})
+	if status != 1 || !strings.Contains(errOut, "missing.log") {
+		t.Errorf("runSample of a missing file returned %d, stderr %q", status, errOut)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sdNotifyInterval is how often the progress is reported to systemd.
+const sdNotifyInterval = 5 * time.Second
+
+// sdNotifier reports the progress to systemd with the sd_notify protocol when
+// fpb runs in a Type=notify service: a STATUS= line, shown by `systemctl status`,
+// and EXTEND_TIMEOUT_USEC= (plus WATCHDOG=1 when the service has a watchdog) so
+// that a long encode isn't killed for taking too long. Each report is a datagram
+// sent to $NOTIFY_SOCKET. Send errors are ignored, as for the status file.
+type sdNotifier struct {
+	conn     *net.UnixConn // Connected to $NOTIFY_SOCKET
+	watchdog bool          // Whether the service expects WATCHDOG=1 keep-alives
+
+	mu     sync.Mutex // Guards latest
+	latest string     // Most recent status line
+
+	loop *periodic // Reports the latest status every sdNotifyInterval
+}
+
+// newSDNotifier starts reporting to systemd, or returns nil when fpb doesn't run
+// in a service expecting notifications ($NOTIFY_SOCKET unset) or the socket
+// can't be reached.
+func newSDNotifier() *sdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading "@" names an abstract socket, which the net package handles
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		debugf("sd_notify socket %s: %v", socket, err)
+		return nil
+	}
+	sn := &sdNotifier{conn: conn, watchdog: os.Getenv("WATCHDOG_USEC") != ""}
+	sn.loop = startPeriodic(sdNotifyInterval, sn.notify)
+	return sn
+}
+
+// Update records the latest status line, to be sent with the next report.
+func (sn *sdNotifier) Update(status string) {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	sn.latest = status
+}
+
+// Close stops reporting after a last report and closes the socket.
+func (sn *sdNotifier) Close() {
+	sn.loop.Stop()
+	sn.conn.Close()
+}
+
+// notify sends the latest status with a timeout extension covering the next
+// few reports, so systemd gives up only if fpb itself stops responding.
+func (sn *sdNotifier) notify() {
+	sn.mu.Lock()
+	status := sn.latest
+	sn.mu.Unlock()
+
+	var msg strings.Builder
+	if status != "" {
+		// A status is a single line
+		fmt.Fprintf(&msg, "STATUS=%s\n", strings.ReplaceAll(status, "\n", " "))
+	}
+	fmt.Fprintf(&msg, "EXTEND_TIMEOUT_USEC=%d\n", (3 * sdNotifyInterval).Microseconds())
+	if sn.watchdog {
+		msg.WriteString("WATCHDOG=1\n")
+	}
+	sn.conn.Write([]byte(msg.String()))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifySocket listens on a unixgram socket set as $NOTIFY_SOCKET, as
+// systemd does for a Type=notify service.
+func fakeNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram sockets")
+	}
+	// Socket paths are short, so not under t.TempDir
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("no unixgram sockets: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
+
+// readNotify returns the next datagram sent to the fake notify socket.
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no sd_notify message: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestSDNotifier(t *testing.T) {
+	conn := fakeNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	sn := newSDNotifier()
+	if sn == nil {
+		t.Fatal("no notifier with NOTIFY_SOCKET set")
+	}
+	sn.Update("in.mp4: 42% | 25fps | ETA 00:03")
+	sn.Close()
+	want := "STATUS=in.mp4: 42% | 25fps | ETA 00:03\nEXTEND_TIMEOUT_USEC=15000000\nWATCHDOG=1\n"
+	if got := readNotify(t, conn); got != want {
+		t.Errorf("message %q, want %q", got, want)
+	}
+
+	// Outside a notify service there is nothing to report to
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sn := newSDNotifier(); sn != nil {
+		sn.Close()
+		t.Error("notifier without NOTIFY_SOCKET")
+	}
+}
+
+func TestSDNotifyProgress(t *testing.T) {
+	conn := fakeNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "")
+	sn := newSDNotifier()
+	if sn == nil {
+		t.Fatal("no notifier with NOTIFY_SOCKET set")
+	}
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetSDNotifier(sn)
+	feed(cpn, strings.SplitAfter(fakeEncode, "speed=1x\r")[0])
+	sn.Close()
+	if got := readNotify(t, conn); !strings.HasPrefix(got, "STATUS=in.mp4: 50% | ") || strings.Contains(got, "WATCHDOG") {
+		t.Errorf("message %q, want the percentage in STATUS and no watchdog", got)
+	}
+}
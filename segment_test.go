@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIsSegment(t *testing.T) {
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"[hls @ 0x55d] Opening 'out003.ts' for writing", true},
+		{"[dash @ 0x55d] Opening 'chunk-stream0-00002.m4s' for writing", true},
+		{"[segment @ 0x55d] Opening 'clip007.mp4' for writing", true},
+		{"[hls @ 0x55d] Opening 'out.m3u8.tmp' for writing", false},
+		{"[dash @ 0x55d] Opening 'manifest.mpd.tmp' for writing", false},
+		{"[segment @ 0x55d] Opening 'list.csv' for writing", false},
+		{"Output #0, hls, to 'out.m3u8':", false},
+	}
+	for _, tt := range tests {
+		if got := cpn.isSegment(tt.line); got != tt.want {
+			t.Errorf("isSegment(%q) = %t, want %t", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentCount(t *testing.T) {
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n"+
+		"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n"+
+		"  Stream #0:0(und): Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr\n"+
+		"Output #0, segment, to 'out%03d.ts':\n"+
+		"[segment @ 0x55d] Opening 'out000.ts' for writing\n"+
+		"[segment @ 0x55d] Opening 'out001.ts' for writing\n"+
+		"[segment @ 0x55d] Opening 'list.m3u8.tmp' for writing\n"+
+		"[segment @ 0x55d] Opening 'out002.ts' for writing\n"+
+		"frame=   50 fps= 25 q=28.0 size=N/A time=00:00:02.00 bitrate=N/A speed=1x\n")
+	if !strings.Contains(stderr.String(), "fps 3 segments ETA") {
+		t.Errorf("output %q, want 3 segments", stderr.String())
+	}
+	if got := cpn.Output(); got != "out*.ts" {
+		t.Errorf("Output() = %q, want the pattern out*.ts", got)
+	}
+}
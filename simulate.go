@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Synthetic media encoded by --fpb-simulate.
+const (
+	simulateDuration  = 128 * time.Second      // Media duration
+	simulateFrameRate = 25                     // Media frame rate
+	simulateBitrate   = 4000                   // Output bitrate in kbit/s
+	simulateStep      = 100 * time.Millisecond // How often progress is reported
+)
+
+// runSimulate draws the progress of a synthetic encode lasting d instead of
+// running FFmpeg, for demos, screenshots and reproducing rendering problems.
+// The synthetic FFmpeg output goes through the same parser and bar as a real
+// encode's, so the mode, theme and layout flags apply as usual.
+func runSimulate(d time.Duration, opts *Options) int {
+	notifier := NewColoredProgressNotifier(os.Stderr, supportsColor(os.Stderr), nopWriteCloser{io.Discard}, opts)
+	notifier.SetPromptDetection(false)
+	defer notifier.ShowCursor()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ticks := time.NewTicker(simulateStep)
+	defer ticks.Stop()
+	ok := simulate(notifier, d, ticks.C, sigChan)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "\n%s\n", T(msgExiting))
+		return exitInterrupted
+	}
+	notifier.Close()
+	return 0
+}
+
+// simulate writes the output of a synthetic encode lasting d to w: a header
+// followed by a stats line on every tick, until the whole media is done.
+// Reports false if stop fires first.
+func simulate(w io.Writer, d time.Duration, ticks <-chan time.Time, stop <-chan os.Signal) bool {
+	fmt.Fprintf(w, "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'simulated.mp4':\n")
+	fmt.Fprintf(w, "  Duration: %s, start: 0.000000, bitrate: %d kb/s\n", ffmpegTime(simulateDuration), simulateBitrate)
+	fmt.Fprintf(w, "  Stream #0:0(und): Video: h264 (High), yuv420p, 1920x1080, %d kb/s, %d fps, %d tbr\n", simulateBitrate, simulateFrameRate, simulateFrameRate)
+	fmt.Fprintf(w, "Output #0, mp4, to 'simulated-out.mp4':\n")
+
+	speed := simulateDuration.Seconds() / d.Seconds()
+	start := time.Now()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-ticks:
+		}
+		elapsed := min(time.Since(start), d)
+		media := time.Duration(float64(simulateDuration) * elapsed.Seconds() / d.Seconds())
+		frame := int(media.Seconds() * simulateFrameRate)
+		fmt.Fprintf(w, "frame=%5d fps=%.0f q=28.0 size=%8dkB time=%s bitrate=%.1fkbits/s speed=%.3gx\r",
+			frame, speed*simulateFrameRate, int(media.Seconds()*simulateBitrate/8),
+			ffmpegTime(media), float64(simulateBitrate), speed)
+		if elapsed >= d {
+			return true
+		}
+	}
+}
+
+// ffmpegTime formats a duration the way FFmpeg logs times, e.g. "00:02:08.00".
+func ffmpegTime(d time.Duration) string {
+	cs := int(d / (10 * time.Millisecond))
+	return fmt.Sprintf("%02d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
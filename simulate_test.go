@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	opts := NewOptions()
+	opts.Mode = ModeLine
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+
+	// Every tick reports the progress made so far; the encode is over after 20ms
+	ticks := make(chan time.Time)
+	done := make(chan bool, 1)
+	go func() { done <- simulate(cpn, 20*time.Millisecond, ticks, nil) }()
+	ticks <- time.Now()
+	time.Sleep(30 * time.Millisecond)
+	ticks <- time.Now()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("simulate reported an interrupt")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("simulate didn't end once the time was up")
+	}
+	cpn.Close()
+
+	if cpn.pbar == nil || cpn.pbar.finishedAt.IsZero() || cpn.pbar.current != cpn.pbar.total {
+		t.Fatalf("bar %+v, want it finished", cpn.pbar)
+	}
+	if !strings.Contains(stderr.String(), "simulated.mp4: 100.0% 3200/3200 frames") {
+		t.Errorf("output %q, want the synthetic encode completed", stderr.String())
+	}
+}
+
+func TestSimulateInterrupted(t *testing.T) {
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	if simulate(io.Discard, time.Second, make(chan time.Time), stop) {
+		t.Error("simulate completed despite the interrupt")
+	}
+}
+
+func TestFFmpegTime(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                                 "00:00:00.00",
+		128 * time.Second:                 "00:02:08.00",
+		time.Hour + 1234*time.Millisecond: "01:00:01.23",
+	} {
+		if got := ffmpegTime(d); got != want {
+			t.Errorf("ffmpegTime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestSimulateHidden(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--fpb-simulate=2.5"})
+	if err != nil || opts.Simulate != 2500*time.Millisecond {
+		t.Errorf("--fpb-simulate=2.5: %v, error %v", opts.Simulate, err)
+	}
+	if _, _, err := parseArgs([]string{"--fpb-simulate=0"}); err == nil {
+		t.Error("--fpb-simulate=0 accepted")
+	}
+	var buf bytes.Buffer
+	printUsage(&buf, "fpb")
+	if strings.Contains(buf.String(), "simulate") {
+		t.Error("usage lists the hidden --fpb-simulate")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// spinnerInterval is how often the spinner advances to its next frame.
+const spinnerInterval = 100 * time.Millisecond
+
+// spinners holds the frame sets selectable with --fpb-spinner.
+var spinners = map[string][]string{
+	"dots":    {".  ", ".. ", "...", "   "},
+	"line":    {"-", "\\", "|", "/"},
+	"braille": {"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+}
+
+// StartSpinner animates the given spinner next to the description until the
+// progress bar appears, so there is feedback while FFmpeg probes the input.
+// The spinner pauses while a prompt waits for an answer. The returned stop
+// function ends it and erases the spinner line if the bar never replaced it;
+// it may be called more than once.
+// Only meaningful in ModeBar; with an unknown style it does nothing.
+func (cpn *ColoredProgressNotifier) StartSpinner(style string) (stop func()) {
+	frames := spinners[style]
+	if len(frames) == 0 || cpn.mode != ModeBar {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer recoverPanic()
+		defer close(stopped)
+
+		ticker := time.NewTicker(spinnerInterval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			if !cpn.drawSpinner(frames[frame%len(frames)]) {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			cpn.mu.Lock()
+			defer cpn.mu.Unlock()
+			if cpn.spinnerShown && cpn.pbar == nil {
+				fmt.Fprint(cpn.file, "\r\033[K")
+			}
+		})
+	}
+}
+
+// drawSpinner draws one spinner frame, unless a prompt is waiting for an answer
+// or a line of output is being received. Returns false once the progress bar
+// has taken over and the spinner should stop.
+func (cpn *ColoredProgressNotifier) drawSpinner(frame string) bool {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	if cpn.pbar != nil || cpn.finished {
+		return false
+	}
+	if cpn.waitingForInput || cpn.lineAcc.Len() > 0 {
+		return true
+	}
+
+	if cpn.useColors && cpn.colors != nil {
+		frame = cpn.colors.Green + frame + cpn.colors.Reset
+	}
+	fmt.Fprintf(cpn.file, "\r\033[K%s %s", frame, cpn.description())
+	cpn.spinnerShown = true
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spinnerOutput returns what the notifier has written so far.
+func spinnerOutput(cpn *ColoredProgressNotifier, out *bytes.Buffer) string {
+	cpn.mu.Lock()
+	defer cpn.mu.Unlock()
+	return out.String()
+}
+
+func TestSpinner(t *testing.T) {
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	opts.Placeholder = "Probing"
+	var out bytes.Buffer
+	cpn := NewColoredProgressNotifier(&out, false, nopWriteCloser{io.Discard}, opts)
+	stop := cpn.StartSpinner("line")
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(spinnerOutput(cpn, &out), "\r\033[K\\ Probing"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("output %q, want the spinner's second frame", spinnerOutput(cpn, &out))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	stop()
+	if got := out.String(); !strings.HasSuffix(got, "Probing\r\033[K") {
+		t.Errorf("output %q, want the spinner erased once when stopped", got)
+	}
+
+	// The bar replaces the spinner, which is then left alone
+	out.Reset()
+	cpn = NewColoredProgressNotifier(&out, false, nopWriteCloser{io.Discard}, opts)
+	stop = cpn.StartSpinner("dots")
+	feed(cpn, fakeEncode)
+	cpn.Close()
+	stop()
+	if got := out.String(); !strings.HasSuffix(got, "\n") || !strings.Contains(got, "100/100") {
+		t.Errorf("output %q, want the final bar last", got)
+	}
+}
+
+func TestSpinnerPausesForPrompt(t *testing.T) {
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	var out bytes.Buffer
+	cpn := NewColoredProgressNotifier(&out, false, nopWriteCloser{io.Discard}, opts)
+	input, answer := io.Pipe()
+	defer answer.Close()
+	cpn.SetInput(input)
+	if !cpn.drawSpinner("-") || !cpn.spinnerShown {
+		t.Fatal("the spinner wasn't drawn")
+	}
+
+	feed(cpn, "File 'out.mp4' already exists. Overwrite? [y/N] ")
+	if !strings.HasSuffix(out.String(), "\r\033[KFile 'out.mp4' already exists. Overwrite? [y/N] ") {
+		t.Errorf("output %q, want the prompt to replace the spinner", out.String())
+	}
+	out.Reset()
+	if !cpn.drawSpinner("\\") || out.Len() != 0 {
+		t.Errorf("spinner drew %q over the prompt", out.String())
+	}
+}
+
+func TestSpinnerDisabled(t *testing.T) {
+	var out bytes.Buffer
+	cpn := NewColoredProgressNotifier(&out, false, nopWriteCloser{io.Discard}, nil) // ModeLine
+	cpn.StartSpinner("braille")()
+	if _, _, err := parseArgs([]string{"--fpb-spinner=moon"}); err == nil {
+		t.Error("--fpb-spinner=moon accepted")
+	}
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	cpn = NewColoredProgressNotifier(&out, false, nopWriteCloser{io.Discard}, opts)
+	cpn.StartSpinner("none")()
+	if out.Len() != 0 {
+		t.Errorf("disabled spinner wrote %q", out.String())
+	}
+}
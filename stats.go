@@ -0,0 +1,244 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// statsLine holds the fields parsed from one FFmpeg stats line, e.g.
+// "frame=  240 fps= 48 q=28.0 size=  1024kB time=00:00:10.00 bitrate= 838.9kbits/s speed=1.92x"
+type statsLine struct {
+	Frame    int     // Frames processed so far
+	FPS      float64 // Current processing rate in frames per second
+	TimeUs   int64   // Output timestamp in microseconds (hundredths precision)
+	Speed    float64 // Processing speed relative to real time
+	HasFrame bool
+	HasFPS   bool
+	HasTime  bool
+	HasSpeed bool
+}
+
+// Regular expressions for the default stats line parser.
+var (
+	statsFrameRx = regexp.MustCompile(`frame=\s*(\d+)`)
+	statsFPSRx   = regexp.MustCompile(`fps=\s*(\d+(?:\.\d+)?)`)
+	statsTimeRx  = regexp.MustCompile(`time=(\d+):(\d{2}):(\d{2})\.(\d{2})`)
+	statsSpeedRx = regexp.MustCompile(`speed=\s*(\d+(?:\.\d+)?)x`)
+)
+
+// parseStatsRegex parses a stats line using regular expressions.
+// This is the default parser; scanStats is a faster equivalent.
+func parseStatsRegex(line string) statsLine {
+	var st statsLine
+	if m := statsFrameRx.FindStringSubmatch(line); m != nil {
+		st.Frame, _ = strconv.Atoi(m[1])
+		st.HasFrame = true
+	}
+	if m := statsFPSRx.FindStringSubmatch(line); m != nil {
+		st.FPS, _ = strconv.ParseFloat(m[1], 64)
+		st.HasFPS = true
+	}
+	if m := statsTimeRx.FindStringSubmatch(line); m != nil {
+		hundredths, _ := strconv.Atoi(m[4])
+		st.TimeUs = int64(seconds(m[1], m[2], m[3]))*1000000 + int64(hundredths)*10000
+		st.HasTime = true
+	}
+	if m := statsSpeedRx.FindStringSubmatch(line); m != nil {
+		st.Speed, _ = strconv.ParseFloat(m[1], 64)
+		st.HasSpeed = true
+	}
+	return st
+}
+
+// scanStats parses a stats line with a hand-written scanner, avoiding regexp
+// overhead for the line FFmpeg repeats several times per second. It accepts
+// exactly the same input as parseStatsRegex and produces identical values.
+func scanStats(line string) statsLine {
+	var st statsLine
+	scanEach(line, "frame=", true, func(v string) bool {
+		n, end := scanDigits(v)
+		if end == 0 {
+			return false
+		}
+		st.Frame, _ = strconv.Atoi(n)
+		st.HasFrame = true
+		return true
+	})
+	scanEach(line, "fps=", true, func(v string) bool {
+		n, end := scanDecimal(v)
+		if end == 0 {
+			return false
+		}
+		st.FPS, _ = strconv.ParseFloat(n, 64)
+		st.HasFPS = true
+		return true
+	})
+	scanEach(line, "time=", false, func(v string) bool {
+		st.TimeUs, st.HasTime = scanClock(v)
+		return st.HasTime
+	})
+	scanEach(line, "speed=", true, func(v string) bool {
+		n, end := scanDecimal(v)
+		if end == 0 || end >= len(v) || v[end] != 'x' {
+			return false
+		}
+		st.Speed, _ = strconv.ParseFloat(n, 64)
+		st.HasSpeed = true
+		return true
+	})
+	return st
+}
+
+// scanEach calls match with the text following each occurrence of key in line,
+// from left to right, until match accepts one. Leading whitespace is optionally
+// skipped, mirroring `\s*` in the regex parser, so the first acceptable
+// occurrence is the same one a regexp search would find.
+func scanEach(line, key string, skipSpace bool, match func(v string) bool) {
+	for i := strings.Index(line, key); i >= 0; {
+		v := line[i+len(key):]
+		if skipSpace {
+			v = strings.TrimLeft(v, " \t\n\f\r")
+		}
+		if match(v) {
+			return
+		}
+		next := strings.Index(line[i+1:], key)
+		if next < 0 {
+			return
+		}
+		i += 1 + next
+	}
+}
+
+// scanDigits returns the leading run of ASCII digits in s and its length.
+func scanDigits(s string) (string, int) {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end], end
+}
+
+// scanDecimal returns a leading decimal number ("12" or "12.5") in s and its length.
+// A trailing "." without digits is not part of the number.
+func scanDecimal(s string) (string, int) {
+	_, end := scanDigits(s)
+	if end == 0 {
+		return "", 0
+	}
+	if end+1 < len(s) && s[end] == '.' && s[end+1] >= '0' && s[end+1] <= '9' {
+		_, frac := scanDigits(s[end+1:])
+		end += 1 + frac
+	}
+	return s[:end], end
+}
+
+// scanClock parses a "HH:MM:SS.ff" timestamp prefix into microseconds, like the
+// regex parser requiring two digits per field except the hours, which have
+// three or more digits past 99 hours.
+func scanClock(s string) (int64, bool) {
+	hours, h := scanDigits(s)
+	if h == 0 {
+		return 0, false
+	}
+	s = s[h:]
+	if len(s) < 9 || s[0] != ':' || s[3] != ':' || s[6] != '.' {
+		return 0, false
+	}
+	for _, i := range []int{1, 2, 4, 5, 7, 8} {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	hundredths, _ := strconv.Atoi(s[7:9])
+	return int64(seconds(hours, s[1:3], s[4:6]))*1000000 + int64(hundredths)*10000, true
+}
+
+// parseOutTime parses the out_time value of FFmpeg's -progress output,
+// "HH:MM:SS.ffffff" with microsecond precision, into microseconds. The hours may
+// have more than two digits. Negative times (before the first packet) are rejected.
+func parseOutTime(s string) (int64, bool) {
+	clock, frac, _ := strings.Cut(s, ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	var secs int64
+	for _, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || part[0] == '-' || part[0] == '+' {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+
+	us := secs * 1000000
+	if frac != "" {
+		if len(frac) > 6 {
+			frac = frac[:6]
+		}
+		n, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil || frac[0] == '-' || frac[0] == '+' {
+			return 0, false
+		}
+		for i := len(frac); i < 6; i++ {
+			n *= 10
+		}
+		us += n
+	}
+	return us, true
+}
+
+// frameRate is a frame rate as an exact fraction, e.g. 30000/1001 for NTSC video,
+// so frame totals for long inputs don't drift as they would with a rounded rate.
+type frameRate struct {
+	Num int64 // Frames
+	Den int64 // Per this many seconds
+}
+
+// Float returns the frame rate in frames per second.
+func (fr frameRate) Float() float64 {
+	if fr.Den == 0 {
+		return 0
+	}
+	return float64(fr.Num) / float64(fr.Den)
+}
+
+// Frames returns the number of frames in the given media time in microseconds,
+// rounded to the nearest frame.
+func (fr frameRate) Frames(us int64) int64 {
+	if fr.Den == 0 {
+		return 0
+	}
+	div := fr.Den * 1000000
+	return (us*fr.Num + div/2) / div
+}
+
+// parseFrameRate parses a frame rate as printed by FFmpeg: a rational such as
+// "30000/1001", or a decimal such as "25" or "29.97". FFmpeg prints NTSC rates
+// rounded ("23.98", "29.97", "59.94"), so decimals within rounding distance of
+// N*1000/1001 are taken to be that exact rational.
+func parseFrameRate(s string) (frameRate, bool) {
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err1 := strconv.ParseInt(num, 10, 64)
+		d, err2 := strconv.ParseInt(den, 10, 64)
+		if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+			return frameRate{}, false
+		}
+		return frameRate{n, d}, true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return frameRate{}, false
+	}
+	if f == math.Trunc(f) {
+		return frameRate{int64(f), 1}, true
+	}
+	if ntsc := math.Round(f * 1.001); math.Abs(ntsc/1.001-f) < 0.006 {
+		return frameRate{int64(ntsc) * 1000, 1001}, true
+	}
+	return frameRate{int64(math.Round(f * 1000)), 1000}, true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// statsCorpus covers the stats line variants FFmpeg prints.
+var statsCorpus = []string{
+	// Video encodes
+	"frame=  240 fps= 48 q=28.0 size=    1024kB time=00:00:10.00 bitrate= 838.9kbits/s speed=1.92x",
+	"frame=   50 fps= 25 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x",
+	"frame=  100 fps= 25 q=-1.0 Lsize=     512KiB time=00:00:04.00 bitrate=1048.6kbits/s speed=1x",
+	"frame=12345 fps=123.4 q=-0.0 size= 1048576KiB time=01:23:45.67 bitrate=1711.2kbits/s dup=2 drop=5 speed=4.93x",
+	"frame=    0 fps=0.0 q=0.0 size=       0KiB time=00:00:00.00 bitrate=N/A speed=   0x",
+	"frame=    1 fps=0.0 q=0.0 size=       0KiB time=00:00:00.04 bitrate=N/A speed=0.0806x",
+	// Values not known yet
+	"frame=    0 fps=N/A q=0.0 size=       0KiB time=N/A bitrate=N/A speed=N/A",
+	"frame=   12 fps=0.0 q=0.0 size=N/A time=00:00:00.48 bitrate=N/A speed=N/A",
+	"size=N/A time=N/A bitrate=N/A speed=N/A",
+	// Audio-only encodes have no frame= or fps=
+	"size=     384kB time=00:00:24.52 bitrate= 128.3kbits/s speed=49.1x",
+	"size=    2048KiB time=00:02:11.07 bitrate= 128.0kbits/s speed= 102x",
+	"[out#0/mp3 @ 0x55d8c2a0] video:0KiB audio:2048KiB subtitle:0KiB other streams:0KiB global headers:0KiB muxing overhead: 0.015%\nsize=    2048KiB time=00:02:11.07 bitrate= 128.0kbits/s speed= 102x",
+	// Long and negative timestamps
+	"frame=9999999 fps= 30 q=28.0 size=99999999KiB time=99:59:59.99 bitrate=2274.9kbits/s speed=1.01x",
+	"frame=999999999 fps= 30 q=28.0 size=99999999KiB time=100:00:00.00 bitrate=2274.9kbits/s speed=1.01x",
+	"frame=13366380 fps= 30 q=28.0 size=99999999KiB time=123:45:06.00 bitrate=2274.9kbits/s speed=1.01x",
+	"frame=   10 fps=0.0 q=28.0 size=       0KiB time=-00:00:00.04 bitrate=N/A speed=N/A",
+	// Malformed values
+	"frame= fps=. q=28.0 size=1kB time=00:00:1.00 bitrate=1kbits/s speed=1.x",
+	"frame=x frame=7 fps=fast fps=12.5 time=bad time=00:00:03.50 speed=x speed=2.5x",
+	"time=00:00:05.0 time=00:00:06.00",
+	"speed=1.5",
+	"fps=\t 9.75 speed=\n3x",
+	// Log lines that aren't stats
+	"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s",
+	"  Stream #0:0(und): Video: h264, yuv420p, 1280x720, 25 fps, 25 tbr",
+	"Press [q] to stop, [?] for help",
+	"",
+}
+
+func TestScanStatsMatchesRegex(t *testing.T) {
+	for _, line := range statsCorpus {
+		if got, want := scanStats(line), parseStatsRegex(line); got != want {
+			t.Errorf("%q:\nscanStats       %+v\nparseStatsRegex %+v", line, got, want)
+		}
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	st := scanStats(statsCorpus[3])
+	want := statsLine{
+		Frame:    12345,
+		FPS:      123.4,
+		TimeUs:   (83*60+45)*1000000 + 670000,
+		Speed:    4.93,
+		HasFrame: true,
+		HasFPS:   true,
+		HasTime:  true,
+		HasSpeed: true,
+	}
+	if st != want {
+		t.Errorf("scanStats(%q) = %+v, want %+v", statsCorpus[3], st, want)
+	}
+
+	// Past 99 hours FFmpeg prints three digits of hours
+	for _, parse := range []func(string) statsLine{scanStats, parseStatsRegex} {
+		if st := parse("frame=13366380 fps= 30 time=123:45:06.00 speed=1.01x"); !st.HasTime || st.TimeUs != 445506*1000000 {
+			t.Errorf("time=123:45:06.00 parsed as %+v, want 445506s", st)
+		}
+	}
+
+	// N/A values leave their fields unset
+	st = scanStats("frame=    0 fps=N/A q=0.0 size=       0KiB time=N/A bitrate=N/A speed=N/A")
+	if !st.HasFrame || st.HasFPS || st.HasTime || st.HasSpeed {
+		t.Errorf("N/A line parsed as %+v", st)
+	}
+}
+
+func TestFastParseNotifier(t *testing.T) {
+	parse := func(fast bool) statsLine {
+		opts := NewOptions()
+		opts.FastParse = fast
+		cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, opts)
+		cpn.Write([]byte(fakeEncode))
+		return cpn.stats
+	}
+	if fast, regex := parse(true), parse(false); fast != regex || fast.Frame != 100 {
+		t.Errorf("--fpb-fast-parse kept %+v, the regexp parser %+v", fast, regex)
+	}
+}
+
+func BenchmarkScanStats(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		scanStats(statsCorpus[i%len(statsCorpus)])
+	}
+}
+
+func BenchmarkParseStatsRegex(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseStatsRegex(statsCorpus[i%len(statsCorpus)])
+	}
+}
+
+func TestFrameRate(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   frameRate
+		inHour int64 // Frames in an hour of media
+	}{
+		{"30000/1001", frameRate{30000, 1001}, 107892},
+		{"29.97", frameRate{30000, 1001}, 107892},
+		{"24000/1001", frameRate{24000, 1001}, 86314},
+		{"23.98", frameRate{24000, 1001}, 86314},
+		{"59.94", frameRate{60000, 1001}, 215784},
+		{"25", frameRate{25, 1}, 90000},
+		{"12.5", frameRate{12500, 1000}, 45000},
+	}
+	for _, tt := range tests {
+		fr, ok := parseFrameRate(tt.in)
+		if !ok || fr != tt.want {
+			t.Errorf("parseFrameRate(%q) = %v, %t; want %v", tt.in, fr, ok, tt.want)
+			continue
+		}
+		if got := fr.Frames(3600 * 1000000); got != tt.inHour {
+			t.Errorf("%s: %d frames in an hour, want %d", tt.in, got, tt.inHour)
+		}
+	}
+	for _, in := range []string{"0", "0/1", "1/0", "fast", "-25"} {
+		if fr, ok := parseFrameRate(in); ok {
+			t.Errorf("parseFrameRate(%q) = %v, want an error", in, fr)
+		}
+	}
+}
+
+func TestNTSCFrameTotal(t *testing.T) {
+	for _, tt := range []struct {
+		fps  string
+		want int
+	}{
+		{"29.97", 107892},
+		{"30000/1001", 107892},
+		{"23.98", 86314},
+	} {
+		cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+		cpn.Write([]byte("Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mov':\n" +
+			"  Duration: 01:00:00.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+			"  Stream #0:0: Video: h264, yuv420p, 1920x1080, " + tt.fps + " fps, " + tt.fps + " tbr\n" +
+			"frame=  100 fps= 25 q=28.0 size=     256KiB time=00:00:04.00 bitrate=1048.6kbits/s speed=1x\r"))
+		if cpn.pbar == nil || cpn.pbar.total != tt.want {
+			t.Errorf("%s fps: bar %+v, want %d frames in total", tt.fps, cpn.pbar, tt.want)
+		}
+	}
+}
+
+func TestOutputFrameRate(t *testing.T) {
+	tests := []struct {
+		args []string
+		want frameRate
+		ok   bool
+	}{
+		{[]string{"-i", "in.mp4", "-r", "30", "out.mp4"}, frameRate{30, 1}, true},
+		{[]string{"-i", "in.mp4", "-r:v", "30000/1001", "out.mp4"}, frameRate{30000, 1001}, true},
+		{[]string{"-r", "24", "-i", "frames/%04d.png", "out.mp4"}, frameRate{}, false}, // Input rate
+		{[]string{"-i", "in.mp4", "-c:v", "libx264", "out.mp4"}, frameRate{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := outputFrameRate(tt.args); got != tt.want || ok != tt.ok {
+			t.Errorf("outputFrameRate(%q) = %v, %t; want %v, %t", tt.args, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestForcedFrameRateTotal(t *testing.T) {
+	// A 60fps input converted with -r 30 yields 30 output frames per second
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	cpn.SetFrameRate(frameRate{30, 1})
+	cpn.Write([]byte("Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mov':\n" +
+		"  Duration: 00:00:04.00, start: 0.000000, bitrate: 1000 kb/s\n" +
+		"  Stream #0:0: Video: h264, yuv420p, 1920x1080, 60 fps, 60 tbr\n" +
+		"frame=   60 fps= 30 q=28.0 size=     256KiB time=00:00:02.00 bitrate=1048.6kbits/s speed=1x\r"))
+	if cpn.FPS() != 30 || cpn.pbar == nil || cpn.pbar.total != 120 || cpn.pbar.current != 60 {
+		t.Errorf("bar %+v at %d fps, want 60/120 frames", cpn.pbar, cpn.FPS())
+	}
+}
+
+func TestParseOutTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"00:00:05.000000", 5000000, true},
+		{"00:01:02.345678", 62345678, true},
+		{"00:00:00.5", 500000, true},
+		{"00:00:01.1234567", 1123456, true},
+		{"01:00:00", 3600000000, true},
+		{"123:45:06.000000", 445506000000, true},
+		{"-00:00:00.040000", 0, false},
+		{"00:-1:00.000000", 0, false},
+		{"00:00:01.-5", 0, false},
+		{"N/A", 0, false},
+		{"00:05.000000", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseOutTime(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseOutTime(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOutTimeProgress(t *testing.T) {
+	// Without out_time_us, out_time drives the bar with microsecond precision
+	cpn := NewColoredProgressNotifier(&bytes.Buffer{}, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")])
+	feed(cpn, "out_time=-00:00:00.040000\n")
+	if cpn.pbar != nil && cpn.pbar.fraction != 0 {
+		t.Errorf("negative out_time moved the bar to %v", cpn.pbar.fraction)
+	}
+	feed(cpn, "out_time=00:00:01.500000\n")
+	if cpn.pbar == nil || !cpn.pbar.hasFraction || cpn.pbar.fraction != 0.375 {
+		t.Fatalf("out_time=00:00:01.500000: bar %+v, want fraction 0.375", cpn.pbar)
+	}
+
+	// Once out_time_us is seen, out_time is ignored
+	feed(cpn, "out_time_us=2000000\nout_time=00:00:03.000000\n")
+	if cpn.pbar.fraction != 0.5 {
+		t.Errorf("fraction %v, want 0.5 from out_time_us", cpn.pbar.fraction)
+	}
+}
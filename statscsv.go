@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// csvFlushInterval is how often appended CSV rows are flushed to the file.
+const csvFlushInterval = 2 * time.Second
+
+// csvHeader names the columns of the --fpb-stats-to-file CSV file.
+var csvHeader = []string{"timestamp", "percent", "frame", "fps", "speed", "bitrate", "size"}
+
+// statsRow is one row of the --fpb-stats-to-file CSV file. Unknown values are
+// left empty in the file.
+type statsRow struct {
+	Time       time.Time
+	Percent    float64
+	Frame      int
+	HasFrame   bool
+	FPS        float64
+	HasFPS     bool
+	Speed      float64
+	HasSpeed   bool
+	Bitrate    float64 // In kbit/s
+	HasBitrate bool
+	Size       int64 // Output size in bytes
+	HasSize    bool
+}
+
+// csvWriter appends a row of encoding statistics per progress update to a CSV
+// file, for later analysis or plotting. Rows are buffered and flushed every
+// csvFlushInterval; a new or empty file gets the header first.
+type csvWriter struct {
+	file *os.File
+
+	mu  sync.Mutex  // Guards w
+	w   *csv.Writer // Buffered writer over file
+	err error       // First write error; later rows are dropped
+
+	loop *periodic // Flushes the buffered rows every csvFlushInterval
+}
+
+// newCSVWriter opens path for appending rows.
+func newCSVWriter(path string) (*csvWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	cw := &csvWriter{file: f, w: csv.NewWriter(f)}
+	if info.Size() == 0 {
+		cw.w.Write(csvHeader)
+	}
+	cw.loop = startPeriodic(csvFlushInterval, cw.flush)
+	return cw, nil
+}
+
+// Append adds a row, to be written with the next flush.
+func (cw *csvWriter) Append(r statsRow) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.err != nil {
+		return
+	}
+	record := []string{
+		r.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		strconv.FormatFloat(r.Percent, 'f', 2, 64),
+		"", "", "", "", "",
+	}
+	if r.HasFrame {
+		record[2] = strconv.Itoa(r.Frame)
+	}
+	if r.HasFPS {
+		record[3] = strconv.FormatFloat(r.FPS, 'f', -1, 64)
+	}
+	if r.HasSpeed {
+		record[4] = strconv.FormatFloat(r.Speed, 'f', -1, 64)
+	}
+	if r.HasBitrate {
+		record[5] = strconv.FormatFloat(r.Bitrate, 'f', -1, 64)
+	}
+	if r.HasSize {
+		record[6] = strconv.FormatInt(r.Size, 10)
+	}
+	cw.err = cw.w.Write(record)
+}
+
+// Close flushes the remaining rows and closes the file.
+func (cw *csvWriter) Close() {
+	cw.loop.Stop()
+	cw.file.Close()
+}
+
+// flush writes the buffered rows to the file.
+func (cw *csvWriter) flush() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.w.Flush()
+	if cw.err == nil {
+		cw.err = cw.w.Error()
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	const progress = "frame=50\nfps=25.00\nbitrate=1048.6kbits/s\ntotal_size=262144\n" +
+		"out_time_us=2000000\nspeed=1x\nprogress=continue\n"
+
+	// A second run appends to the same file, without another header
+	for run := 0; run < 2; run++ {
+		cw, err := newCSVWriter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+		cpn.SetStatsCSV(cw)
+		feed(cpn, fakeEncode[:strings.Index(fakeEncode, "frame=")]+progress)
+		cw.Close() // Flushes without waiting for the interval
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !slices.Equal(records[0], csvHeader) {
+		t.Fatalf("CSV %q, want the header and a row per run", records)
+	}
+	for _, row := range records[1:] {
+		if _, err := time.Parse(time.RFC3339, row[0]); err != nil {
+			t.Errorf("timestamp %q: %v", row[0], err)
+		}
+		// The speed comes after out_time_us in the -progress block, so it isn't known yet
+		if want := []string{"50.00", "50", "25", "", "1048.6", "262144"}; !slices.Equal(row[1:], want) {
+			t.Errorf("row %q, want %q after the timestamp", row, want)
+		}
+	}
+}
+
+func TestStatsCSVUnwritable(t *testing.T) {
+	if _, err := newCSVWriter(filepath.Join(t.TempDir(), "missing", "stats.csv")); err == nil {
+		t.Error("newCSVWriter in a missing directory succeeded")
+	}
+}
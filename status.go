@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// statusInterval is how often the status file is rewritten.
+const statusInterval = time.Second
+
+// statusWriter keeps a status file holding a single human-readable progress
+// line ("42% | 118fps | ETA 03:12"), for `watch cat FILE` style polling. Each
+// write replaces the file atomically. Write errors are ignored, as for checkpoints.
+type statusWriter struct {
+	path string // Status file
+
+	mu     sync.Mutex // Guards latest and dirty
+	latest string     // Most recent status line
+	dirty  bool       // Whether latest hasn't been written yet
+
+	loop *periodic // Writes new lines every statusInterval
+}
+
+// newStatusWriter starts writing status lines to path.
+func newStatusWriter(path string) *statusWriter {
+	sw := &statusWriter{path: path}
+	sw.loop = startPeriodic(statusInterval, sw.writeLatest)
+	return sw
+}
+
+// Update records the latest status line, to be written with the next refresh.
+func (sw *statusWriter) Update(line string) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.latest = line
+	sw.dirty = true
+}
+
+// Close stops refreshing the status file after writing the last line, if it is new.
+func (sw *statusWriter) Close() {
+	sw.loop.Stop()
+}
+
+// writeLatest writes the latest line if it hasn't been written yet.
+func (sw *statusWriter) writeLatest() {
+	sw.mu.Lock()
+	line, dirty := sw.latest, sw.dirty
+	sw.dirty = false
+	sw.mu.Unlock()
+
+	if dirty {
+		writeFileAtomic(sw.path, []byte(line+"\n"))
+	}
+}
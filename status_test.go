@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStatusFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewOptions()
+	opts.StatusFile = filepath.Join(dir, "status.txt")
+	status, stderr := runFake(t, newFakeProcess(fakeEncode), opts, strings.NewReader(""), "-i", "in.mp4", filepath.Join(dir, "out.mp4"))
+	if status != 0 {
+		t.Fatalf("status %d, stderr:\n%s", status, stderr)
+	}
+
+	data, err := os.ReadFile(opts.StatusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^100% \| \d+fps \| ETA 00:00\n$`).Match(data) {
+		t.Errorf("status file %q, want the final status on a single line", data)
+	}
+}
+
+func TestStatusWriterReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.txt")
+	sw := &statusWriter{path: path}
+	for _, line := range []string{"10% | 120fps | ETA 03:00", "42% | 118fps | ETA 01:56"} {
+		sw.Update(line)
+		sw.writeLatest()
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "42% | 118fps | ETA 01:56\n" {
+		t.Errorf("status file %q (%v), want only the latest line", data, err)
+	}
+
+	// Nothing new: the file is left alone
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	sw.writeLatest()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("status file rewritten without an update (%v)", err)
+	}
+}
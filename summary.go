@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// outputSummary describes the output after a successful encode, e.g.
+// "Output: movie.mp4 (12.3 MB, 02:08)", so the result can be checked without
+// running another command. The size is finalSize, the one FFmpeg reported at
+// the end, or the file's size when that is unknown (-1). The duration is
+// included when ffprobe can read the file, followed by the extra details, if any.
+// Returns "" when path is empty, or when it isn't a regular file (pipes, URLs)
+// and FFmpeg reported no size.
+func outputSummary(path string, finalSize int64, extra ...string) string {
+	if path == "" {
+		return ""
+	}
+	name, size := path, finalSize
+	info, err := os.Stat(path)
+	regular := err == nil && info.Mode().IsRegular()
+	if regular {
+		name = filepath.Base(path)
+		if size < 0 {
+			size = info.Size()
+		}
+	}
+	if size < 0 {
+		return ""
+	}
+
+	details := formatSize(size)
+	if regular {
+		if us, err := probeDuration(path); err == nil && us > 0 {
+			details += ", " + formatClock(time.Duration(us)*time.Microsecond)
+		}
+	}
+	for _, detail := range extra {
+		details += ", " + detail
+	}
+	return fmt.Sprintf(T(msgOutputSummary), name, details)
+}
+
+// summaryTarget returns the output to describe in the summary: the output file,
+// or else the pipe or URL FFmpeg writes to (e.g. "pipe:1"), which only FFmpeg's
+// reported size can describe. Returns "" when there is neither.
+func summaryTarget(args []string) string {
+	if path := outputPath(args); path != "" {
+		return path
+	}
+	if len(args) < 2 || args[len(args)-2] == "-i" {
+		return ""
+	}
+	target := args[len(args)-1]
+	if isStdoutTarget(target) || protocolRx.MatchString(target) {
+		return target
+	}
+	return ""
+}
+
+// formatSize formats a byte count with binary prefixes, e.g. "12.3 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatClock formats a duration as MM:SS, or H:MM:SS from one hour on.
+func formatClock(d time.Duration) string {
+	secs := int(d.Round(time.Second).Seconds())
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// Lines FFmpeg prints at the end of an encode run with -benchmark, e.g.
+// "bench: utime=1.234s stime=0.056s rtime=2.345s" and "bench: maxrss=123456KiB".
+var (
+	benchTimesRx = regexp.MustCompile(`bench: utime=(\d+(?:\.\d+)?)s stime=(\d+(?:\.\d+)?)s`)
+	benchRSSRx   = regexp.MustCompile(`bench: maxrss=(\d+)(?:kB|KiB)`)
+)
+
+// benchmark holds the resource usage FFmpeg reports with -benchmark.
+type benchmark struct {
+	UserSecs   float64 // User CPU time in seconds
+	SystemSecs float64 // System CPU time in seconds
+	MaxRSS     int64   // Peak resident set size in bytes
+	HasTimes   bool
+	HasRSS     bool
+}
+
+// parse records the values of a -benchmark line, reporting whether line was one.
+func (b *benchmark) parse(line string) bool {
+	if m := benchTimesRx.FindStringSubmatch(line); m != nil {
+		b.UserSecs, _ = strconv.ParseFloat(m[1], 64)
+		b.SystemSecs, _ = strconv.ParseFloat(m[2], 64)
+		b.HasTimes = true
+		return true
+	}
+	if m := benchRSSRx.FindStringSubmatch(line); m != nil {
+		kib, _ := strconv.ParseInt(m[1], 10, 64)
+		b.MaxRSS = kib * 1024
+		b.HasRSS = true
+		return true
+	}
+	return false
+}
+
+// details returns the summary details of the benchmark, e.g.
+// ["1.29s CPU time", "120.6 MB peak memory"].
+func (b benchmark) details() []string {
+	var details []string
+	if b.HasTimes {
+		details = append(details, fmt.Sprintf(T(msgBenchCPU), b.UserSecs+b.SystemSecs))
+	}
+	if b.HasRSS {
+		details = append(details, fmt.Sprintf(T(msgBenchRSS), formatSize(b.MaxRSS)))
+	}
+	return details
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputSummary(t *testing.T) {
+	fakeFFprobe(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// The fake ffprobe knows a.ts is 10.5s long, but not out.mp4
+	if err := os.WriteFile("a.ts", make([]byte, 3*1024*1024/2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("out.mp4", []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path      string
+		finalSize int64
+		want      string
+	}{
+		{"a.ts", -1, "Output: a.ts (1.5 MB, 00:11)"},
+		{"out.mp4", -1, "Output: out.mp4 (7 B)"},
+		{"missing.mp4", -1, ""},
+		{dir, -1, ""}, // Not a regular file
+		{"", -1, ""},
+		// FFmpeg's reported size wins over the file's, and describes pipes
+		{"a.ts", 2 * 1024 * 1024, "Output: a.ts (2.0 MB, 00:11)"},
+		{"out.mp4", 512 * 1024, "Output: out.mp4 (512.0 KB)"},
+		{"pipe:1", 512 * 1024, "Output: pipe:1 (512.0 KB)"},
+		{"pipe:1", -1, ""},
+		{"-", -1, ""}, // The null muxer reports Lsize=N/A
+		{"", 512 * 1024, ""},
+	}
+	for _, tt := range tests {
+		if got := outputSummary(tt.path, tt.finalSize); got != tt.want {
+			t.Errorf("outputSummary(%q, %d) = %q, want %q", filepath.Base(tt.path), tt.finalSize, got, tt.want)
+		}
+	}
+
+	want := "Output: a.ts (1.5 MB, 00:11, 1.29s CPU time, 120.6 MB peak memory)"
+	if got := outputSummary("a.ts", -1, "1.29s CPU time", "120.6 MB peak memory"); got != want {
+		t.Errorf("with extra details: %q, want %q", got, want)
+	}
+}
+
+func TestFinalSize(t *testing.T) {
+	tests := []struct {
+		log  string
+		want int64
+	}{
+		{fakeEncode, 512 * 1024},
+		// FFmpeg before 6.1 prints kB
+		{strings.Replace(fakeEncode, "Lsize=     512KiB", "Lsize=     300kB", 1), 300 * 1024},
+		// Only the last stats line has the final size
+		{strings.Replace(fakeEncode, "Lsize=     512KiB", "size=     512KiB", 1), -1},
+	}
+	for _, tt := range tests {
+		cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+		feed(cpn, tt.log)
+		if got := cpn.FinalSize(); got != tt.want {
+			t.Errorf("final size %d, want %d", got, tt.want)
+		}
+	}
+}
+
+func TestSummaryTarget(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-i", "in.mp4", "out.mp4"}, "out.mp4"},
+		{[]string{"-i", "in.mp4", "-f", "mpegts", "pipe:1"}, "pipe:1"},
+		{[]string{"-i", "in.mp4", "-f", "flv", "rtmp://example.com/live"}, "rtmp://example.com/live"},
+		{[]string{"-i", "in.mp4", "-f", "mpegts", "-"}, "-"},
+		{[]string{"-i", "pipe:0"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := summaryTarget(tt.args); got != tt.want {
+			t.Errorf("summaryTarget(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunSummaryFinalSize(t *testing.T) {
+	fakeFFprobe(t)
+	opts := NewOptions()
+	opts.Summary = true
+	_, stderr := runFake(t, newFakeProcess(fakeEncode), opts, strings.NewReader(""), "-i", "in.mp4", "-f", "mpegts", "pipe:1")
+	if want := "Output: pipe:1 (512.0 KB)\n"; !strings.HasSuffix(stderr, want) {
+		t.Errorf("stderr %q, want it to end in %q", stderr, want)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	cpn := NewColoredProgressNotifier(io.Discard, false, nopWriteCloser{io.Discard}, nil)
+	feed(cpn, fakeEncode+
+		"bench: utime=1.234s stime=0.056s rtime=2.345s\n"+
+		"bench: maxrss=123456KiB\n")
+	b := cpn.Benchmark()
+	if !b.HasTimes || b.UserSecs != 1.234 || b.SystemSecs != 0.056 || !b.HasRSS || b.MaxRSS != 123456*1024 {
+		t.Errorf("benchmark %+v, want the bench: lines' values", b)
+	}
+	if want := []string{"1.29s CPU time", "120.6 MB peak memory"}; !slices.Equal(b.details(), want) {
+		t.Errorf("details %q, want %q", b.details(), want)
+	}
+
+	// Older FFmpeg versions report kB; without -benchmark there are no details
+	var older benchmark
+	if !older.parse("bench: maxrss=2048kB") || older.MaxRSS != 2048*1024 || older.HasTimes {
+		t.Errorf("benchmark %+v, want 2 MB peak memory only", older)
+	}
+	if older.parse("frame=  100 fps= 25") {
+		t.Error("a stats line parsed as a benchmark")
+	}
+	if details := (benchmark{}).details(); len(details) != 0 {
+		t.Errorf("details %q without -benchmark", details)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KB"},
+		{12*1024*1024 + 300*1024, "12.3 MB"},
+		{5 << 40, "5.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00"},
+		{128 * time.Second, "02:08"},
+		{59*time.Minute + 59600*time.Millisecond, "1:00:00"},
+		{26*time.Hour + 3*time.Second, "26:00:03"},
+	}
+	for _, tt := range tests {
+		if got := formatClock(tt.d); got != tt.want {
+			t.Errorf("formatClock(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
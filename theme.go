@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Color themes, chosen with --fpb-theme-auto from the terminal background.
+const (
+	ThemeDark  = "dark"  // Bright colors for dark backgrounds (the default)
+	ThemeLight = "light" // Darker colors that stay readable on light backgrounds
+)
+
+// activeTheme is the theme NewColors builds colors for.
+var activeTheme = ThemeDark
+
+// osc11Timeout is how long to wait for the terminal to answer the background color query.
+const osc11Timeout = 200 * time.Millisecond
+
+// osc11Rx matches the terminal's answer to the OSC 11 background color query,
+// e.g. "\033]11;rgb:ffff/ffff/dddd\033\\".
+var osc11Rx = regexp.MustCompile(`\]11;rgba?:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+
+// parseOSC11 returns the relative luminance (0 black to 1 white) of the
+// background color reported in an OSC 11 response.
+func parseOSC11(resp string) (float64, bool) {
+	m := osc11Rx.FindStringSubmatch(resp)
+	if m == nil {
+		return 0, false
+	}
+	var rgb [3]float64
+	for i, hex := range m[1:4] {
+		v, err := strconv.ParseUint(hex, 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		// Components have 1 to 4 hex digits; scale each to 0-1
+		rgb[i] = float64(v) / float64(uint64(1)<<(4*len(hex))-1)
+	}
+	return 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2], true
+}
+
+// themeForLuminance picks the theme suited to a background of the given luminance.
+func themeForLuminance(luminance float64) string {
+	if luminance > 0.5 {
+		return ThemeLight
+	}
+	return ThemeDark
+}
+
+// detectTheme queries the terminal's background color and returns the matching
+// theme, falling back to the dark theme when the terminal doesn't answer in time.
+func detectTheme() string {
+	resp, ok := queryTerminal("\033]11;?\033\\", osc11Timeout)
+	if !ok {
+		return ThemeDark
+	}
+	luminance, ok := parseOSC11(resp)
+	if !ok {
+		return ThemeDark
+	}
+	return themeForLuminance(luminance)
+}
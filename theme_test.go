@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseOSC11(t *testing.T) {
+	tests := []struct {
+		resp      string
+		luminance float64
+		theme     string
+	}{
+		{"\033]11;rgb:0000/0000/0000\033\\", 0, ThemeDark},
+		{"\033]11;rgb:ffff/ffff/ffff\033\\", 1, ThemeLight},
+		{"\033]11;rgb:ff/ff/ff\007", 1, ThemeLight},             // Two-digit components, BEL terminated
+		{"\033]11;rgb:1e1e/1e1e/2e2e\033\\", 0.1224, ThemeDark}, // Dark blue-grey
+		{"\033]11;rgb:fdfd/f6f6/e3e3\033\\", 0.9652, ThemeLight},
+		{"\033]11;rgba:ffff/0000/0000/ffff\033\\", 0.2126, ThemeDark}, // Pure red is dark
+	}
+	for _, tt := range tests {
+		luminance, ok := parseOSC11(tt.resp)
+		if !ok || math.Abs(luminance-tt.luminance) > 0.001 {
+			t.Errorf("parseOSC11(%q) = %.4f, %t, want %.4f", tt.resp, luminance, ok, tt.luminance)
+		}
+		if got := themeForLuminance(luminance); got != tt.theme {
+			t.Errorf("theme for %q = %s, want %s", tt.resp, got, tt.theme)
+		}
+	}
+
+	for _, resp := range []string{"", "\033[?62;c", "\033]11;rgb:zz/00/00\033\\", "\033]10;rgb:ffff/ffff/ffff\033\\"} {
+		if _, ok := parseOSC11(resp); ok {
+			t.Errorf("parseOSC11(%q) reported a color", resp)
+		}
+	}
+}
+
+func TestLightThemeColors(t *testing.T) {
+	defer func() { activeTheme = ThemeDark }()
+	activeTheme = ThemeLight
+	c := NewColors()
+	if c.BrightYellow == "\033[93m" || c.BrightRed == "\033[91m" || c.Yellow == "\033[33m" {
+		t.Errorf("light theme colors %q, want no bright or yellow tones", []string{c.Yellow, c.BrightRed, c.BrightYellow})
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// queryTerminal writes an escape sequence query to the controlling terminal and
+// returns its answer, read in raw mode until the string terminator (ST or BEL).
+// Reports false if there is no terminal or it doesn't answer within timeout.
+func queryTerminal(query string, timeout time.Duration) (string, bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false
+	}
+	defer tty.Close()
+
+	// Fd() would switch the file to blocking mode, disabling read deadlines,
+	// so the descriptor is only borrowed through the raw connection
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return "", false
+	}
+	var fd int
+	conn.Control(func(f uintptr) { fd = int(f) })
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", false
+	}
+	defer term.Restore(fd, state)
+
+	// Without a deadline an unanswered query would block forever
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", false
+	}
+	if _, err := tty.WriteString(query); err != nil {
+		return "", false
+	}
+
+	var resp strings.Builder
+	buf := make([]byte, 64)
+	for {
+		n, err := tty.Read(buf)
+		resp.Write(buf[:n])
+		s := resp.String()
+		if strings.HasSuffix(s, "\033\\") || strings.HasSuffix(s, "\007") {
+			return s, true
+		}
+		if err != nil {
+			return "", false
+		}
+	}
+}
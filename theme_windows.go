@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "time"
+
+// queryTerminal is not supported on Windows; the default theme is used.
+func queryTerminal(query string, timeout time.Duration) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// inTmux reports whether fpb is running inside a tmux session.
+func inTmux() bool {
+	return os.Getenv("TMUX") != ""
+}
+
+// tmuxTitle builds the sequence that sets the pane title (tmux's #T) to the
+// progress, e.g. "movie.mp4 42%". It is sent to tmux itself, not passed through
+// to the outer terminal, so tmux updates the title shown in its status bar.
+func tmuxTitle(desc string, percentage float64) string {
+	return fmt.Sprintf("\033]2;%s %.0f%%\007", desc, percentage)
+}
+
+// tmuxTitleReset clears the pane title set by tmuxTitle once fpb is done.
+const tmuxTitleReset = "\033]2;\007"
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTmuxTitle(t *testing.T) {
+	if got := tmuxTitle("in.mp4", 42.4); got != "\033]2;in.mp4 42%\007" {
+		t.Errorf("tmuxTitle = %q, want a plain OSC 2 sequence", got)
+	}
+
+	opts := NewOptions()
+	opts.Tmux = true
+	opts.Mode = ModeBar
+	run := func(finish bool) string {
+		var stderr bytes.Buffer
+		cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+		feed(cpn, fakeEncode[:strings.Index(fakeEncode, "\r")+1])
+		if finish {
+			cpn.Close()
+		}
+		// Errors and Ctrl+C reset the title without finishing the bar
+		cpn.ShowCursor()
+		cpn.ShowCursor()
+		return stderr.String()
+	}
+
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1234,0")
+	for _, finish := range []bool{true, false} {
+		out := run(finish)
+		if !strings.Contains(out, "\033]2;in.mp4 50%\007") {
+			t.Errorf("output %q, want the title set to the progress", out)
+		}
+		if strings.Contains(out, "\033]2;in.mp4 100%\007") {
+			t.Errorf("output %q, want the final bar drawn without a title", out)
+		}
+		if n := strings.Count(out, tmuxTitleReset); n != 1 || !strings.HasSuffix(strings.TrimSuffix(out, "\n"), tmuxTitleReset) {
+			t.Errorf("finish %t: output %q, want the title reset once at the end", finish, out)
+		}
+	}
+
+	t.Setenv("TMUX", "")
+	if out := run(true); strings.Contains(out, "\033]2;") {
+		t.Errorf("output %q sets a title outside tmux", out)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=1.2.3 -X main.commit=abc1234".
+var (
+	version = "dev"
+	commit  = "" // Falls back to the revision Go records when building from a git checkout
+)
+
+// printVersion writes fpb's version and commit and the version of the FFmpeg
+// fpb runs, for --fpb-version, e.g.
+//
+//	fpb 1.2.3 (commit abc1234)
+//	ffmpeg 6.1.1
+func printVersion(w io.Writer) {
+	line := "fpb " + version
+	if c := buildCommit(); c != "" {
+		line += " (commit " + c + ")"
+	}
+	fmt.Fprintln(w, line)
+	if v, ok := ffmpegVersion(); ok {
+		fmt.Fprintf(w, "ffmpeg %s\n", v)
+	} else {
+		fmt.Fprintln(w, "ffmpeg not found")
+	}
+}
+
+// buildCommit returns the commit fpb was built from: the one set with -ldflags,
+// or the VCS revision recorded by the Go toolchain ("" if neither is known).
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, dirty string
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision":
+			revision = s.Value
+		case s.Key == "vcs.modified" && s.Value == "true":
+			dirty = "-dirty"
+		}
+	}
+	if revision == "" {
+		return ""
+	}
+	return revision[:min(len(revision), 7)] + dirty
+}
+
+// ffmpegVersion returns the version of the ffmpeg on the PATH, read from the
+// first line of "ffmpeg -version" ("ffmpeg version 6.1.1 Copyright ...").
+func ffmpegVersion() (string, bool) {
+	out, err := exec.Command("ffmpeg", "-version").Output()
+	if err != nil {
+		return "", false
+	}
+	first, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(first)
+	if len(fields) < 3 || fields[0] != "ffmpeg" || fields[1] != "version" {
+		return "", false
+	}
+	return fields[2], true
+}
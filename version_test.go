@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	argsLog := filepath.Join(dir, "args.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$*\" >> '" + argsLog + "'\n" +
+		"echo 'ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers'\n" +
+		"echo 'built with gcc 13'\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	defer func(v, c string) { version, commit = v, c }(version, commit)
+	version, commit = "1.2.3", "abc1234"
+
+	var status int
+	stdout := captureStdout(t, func() { status = Run([]string{"--fpb-version"}) })
+	if want := "fpb 1.2.3 (commit abc1234)\nffmpeg 6.1.1\n"; status != 0 || stdout != want {
+		t.Errorf("status %d, stdout %q; want %q", status, stdout, want)
+	}
+	// FFmpeg is only asked for its version, never run on media
+	if args, _ := os.ReadFile(argsLog); string(args) != "-version\n" {
+		t.Errorf("FFmpeg ran with %q, want only -version", args)
+	}
+
+	// Without FFmpeg fpb still prints its own version
+	t.Setenv("PATH", t.TempDir())
+	stdout = captureStdout(t, func() { status = Run([]string{"--fpb-version"}) })
+	if status != 0 || !strings.HasPrefix(stdout, "fpb 1.2.3 (commit abc1234)\n") || !strings.HasSuffix(stdout, "ffmpeg not found\n") {
+		t.Errorf("without ffmpeg: status %d, stdout %q", status, stdout)
+	}
+}
+
+func TestBuildCommit(t *testing.T) {
+	defer func(c string) { commit = c }(commit)
+	commit = "abc1234"
+	if got := buildCommit(); got != "abc1234" {
+		t.Errorf("buildCommit() = %q, want the -ldflags commit", got)
+	}
+}
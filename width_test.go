@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+	}{
+		{"clip.mp4", 8},
+		{"vidéo.mp4", 9},
+		{"vidéo.mp4", 9}, // Combining acute accent
+		{"日本語.mp4", 10},
+		{"한국어.mkv", 10},
+		{"🎬 final.mov", 12},
+	}
+	for _, tt := range tests {
+		if got := textWidth(tt.s); got != tt.width {
+			t.Errorf("textWidth(%q) = %d, want %d", tt.s, got, tt.width)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"clip.mp4", 4, "clip"},
+		{"日本語.mp4", 4, "日本"},
+		{"日本語.mp4", 5, "日本"}, // Half of 語 doesn't fit
+		{"日本語.mp4", 20, "日本語.mp4"},
+	}
+	for _, tt := range tests {
+		if got := truncateWidth(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestWideFilename(t *testing.T) {
+	name := "a" + strings.Repeat("日本語", 6) + ".mp4"
+	pb := NewProgressBar(name, 100, "frames", false, io.Discard)
+	got := pb.handleFilename(name)
+	if !utf8.ValidString(got) || textWidth(got) > 30 || !strings.HasSuffix(got, "...") {
+		t.Errorf("handleFilename(%q) = %q (width %d), want at most 30 columns of valid UTF-8", name, got, textWidth(got))
+	}
+
+	// A name whose characters are split across Write calls is parsed intact
+	header := strings.Replace(fakeEncode, "in.mp4", name, 1)
+	var stderr bytes.Buffer
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, nil)
+	for data := []byte(header); len(data) > 0; {
+		n := min(len(data), 7)
+		cpn.Write(data[:n])
+		data = data[n:]
+	}
+	cpn.Close()
+	if cpn.Source() != name {
+		t.Errorf("Source() = %q, want %q", cpn.Source(), name)
+	}
+	if !utf8.ValidString(stderr.String()) {
+		t.Errorf("output %q isn't valid UTF-8", stderr.String())
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"in.mp4", "in.mp4"},
+		{"日本語.mp4", "日本語.mp4"},
+		{"\x1b[31mred\x1b[0m.mp4", `\x1b[31mred\x1b[0m.mp4`},
+		{"tab\there\r.mp4", `tab\there\r.mp4`},
+		{"bell\a\u0085.mp4", `bell\a\u0085.mp4`},
+	}
+	for _, tt := range tests {
+		if got := sanitizeText(tt.in); got != tt.want {
+			t.Errorf("sanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapedFilename(t *testing.T) {
+	var stderr bytes.Buffer
+	opts := NewOptions()
+	opts.Mode = ModeBar
+	cpn := NewColoredProgressNotifier(&stderr, false, nopWriteCloser{io.Discard}, opts)
+	feed(cpn, strings.Replace(fakeEncode, "'in.mp4'", "'\x1b[2J\x1b[31mevil.mp4'", 1))
+	cpn.Close()
+
+	// The only escape sequences left are the bar's own
+	out := strings.ReplaceAll(stderr.String(), "\r\033[K", "")
+	if strings.Contains(out, "\x1b") || !strings.Contains(out, `\x1b[2J\x1b[31mevil.mp4`) {
+		t.Errorf("output %q, want the filename's escape sequences neutralized", stderr.String())
+	}
+}